@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+	pkgJSONSchema "dynamodb.data-migration/internal/jsonschema"
+	pkgValidator "dynamodb.data-migration/internal/validator"
+)
+
+// command - CLI command.
+type command struct {
+	name    string
+	args    []string
+	summary string
+	// aws - the command needs AWS access.
+	aws bool
+	// runFlags - the command accepts target, steps and allow-failed flags.
+	runFlags bool
+	// setup - registers command specific flags.
+	setup func(fs *flag.FlagSet, options *commandOptions)
+	// run - runs the command, returns the result printed in the json output mode.
+	run func(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error)
+}
+
+// commandOptions - values of command specific flags.
+type commandOptions struct {
+	version    string
+	dropTables bool
+	minor      bool
+	major      bool
+	timestamp  bool
+}
+
+func (cmd *command) usage() string {
+	usage := cmd.name
+	for _, arg := range cmd.args {
+		usage += " <" + arg + ">"
+	}
+	return usage
+}
+
+var commands = []*command{
+	{
+		name:     "up",
+		summary:  "apply pending migrations (default command)",
+		aws:      true,
+		runFlags: true,
+		run:      runUp,
+	},
+	{
+		name:     "down",
+		summary:  "revert applied migrations in the reverse order, deletes written items",
+		aws:      true,
+		runFlags: true,
+		setup: func(fs *flag.FlagSet, options *commandOptions) {
+			fs.BoolVar(&options.dropTables, "drop-tables", false, "also delete tables created by the reverted migrations")
+		},
+		run: runDown,
+	},
+	{
+		name:     "plan",
+		summary:  "print pending migrations that up will apply",
+		aws:      true,
+		runFlags: true,
+		run:      runPlan,
+	},
+	{
+		name:    "status",
+		summary: "print migration files and records",
+		aws:     true,
+		run:     runStatus,
+	},
+	{
+		name:    "validate",
+		summary: "check migration files without AWS access",
+		run:     runValidate,
+	},
+	{
+		name:    "schema",
+		summary: "print the JSON Schema of migration files",
+		run:     runSchema,
+	},
+	{
+		name:    "create",
+		args:    []string{"title"},
+		summary: "create a new migration file with the next version, bumps the patch version by default",
+		setup: func(fs *flag.FlagSet, options *commandOptions) {
+			fs.BoolVar(&options.minor, "minor", false, "bump the minor version")
+			fs.BoolVar(&options.major, "major", false, "bump the major version")
+			fs.BoolVar(&options.timestamp, "timestamp", false, "use the current UTC date and time as version, e.g. 20210901.143005.0")
+		},
+		run: runCreate,
+	},
+	{
+		name:    "baseline",
+		summary: "mark migrations up to the version as applied without executing them",
+		aws:     true,
+		setup: func(fs *flag.FlagSet, options *commandOptions) {
+			fs.StringVar(&options.version, "version", "", "last version to mark as applied, e.g. 1.2.0")
+		},
+		run: runBaseline,
+	},
+	{
+		name:    "mark-applied",
+		args:    []string{"version"},
+		summary: "create or fix the migration record without executing the migration",
+		aws:     true,
+		run:     runMarkApplied,
+	},
+	{
+		name:    "unmark",
+		args:    []string{"version"},
+		summary: "remove the migration record, the migration will be applied again",
+		aws:     true,
+		run:     runUnmark,
+	},
+	{
+		name:    "repair",
+		summary: "recompute checksums and remove failed migration records",
+		aws:     true,
+		run:     runRepair,
+	},
+	{
+		name:    "unlock",
+		summary: "remove the migrations lock left by a crashed run",
+		aws:     true,
+		run:     runUnlock,
+	},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func runUp(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	migrateOptions, err := env.migrationContext.MigrateOptions()
+	if err != nil {
+		return nil, err
+	}
+	env.logger.Info("Migration started")
+	report, err := env.service.Migrate(ctx, migrateOptions)
+	if env.isTextOutput() {
+		printReport(report)
+	}
+	if err != nil {
+		return report, err
+	}
+	env.logger.Info("Done", pkgDomain.F("applied", report.Count(pkgDomain.MigrationStatusSucceeded)))
+	return report, nil
+}
+
+func runDown(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	rollbackOptions, err := env.migrationContext.RollbackOptions(options.dropTables)
+	if err != nil {
+		return nil, err
+	}
+	env.logger.Info("Rollback started")
+	report, err := env.service.Rollback(ctx, rollbackOptions)
+	if env.isTextOutput() {
+		printReport(report)
+	}
+	if err != nil {
+		return report, err
+	}
+	env.logger.Info("Done", pkgDomain.F("reverted", report.Count(pkgDomain.MigrationStatusReverted)))
+	return report, nil
+}
+
+func runPlan(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	migrateOptions, err := env.migrationContext.MigrateOptions()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := env.service.Plan(ctx, migrateOptions)
+	if err != nil {
+		return nil, err
+	}
+	if env.isTextOutput() {
+		printPlan(plan)
+	}
+	return plan, nil
+}
+
+func runStatus(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	infos, err := env.service.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if env.isTextOutput() {
+		printStatus(infos)
+	}
+	return infos, nil
+}
+
+func runValidate(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	migrations, err := env.storage.GetExecutableMigrations()
+	if err != nil {
+		return nil, err
+	}
+	result := &validateResult{
+		Migrations: len(migrations),
+		Issues:     pkgValidator.NewMigrationValidator(env.queryParser).Validate(migrations),
+	}
+	if result.Issues == nil {
+		result.Issues = []*pkgDomain.ValidationIssue{}
+	}
+	for _, issue := range result.Issues {
+		env.logger.Error(issue.Message, pkgDomain.F("migration", issue.Migration), pkgDomain.F("path", issue.Path))
+	}
+	if len(result.Issues) > 0 {
+		return result, pkgDomain.Errorf(pkgDomain.ErrorKindValidation, "Validation failed, issues: %d", len(result.Issues))
+	}
+	env.logger.Info("Migrations are valid", pkgDomain.F("migrations", len(migrations)))
+	return result, nil
+}
+
+func runSchema(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	content, err := pkgJSONSchema.MigrationFile()
+	if err != nil {
+		return nil, err
+	}
+	if env.isTextOutput() {
+		_, err = os.Stdout.Write(content)
+	}
+	return json.RawMessage(content), err
+}
+
+// migrationSkeleton - content of a new migration file.
+const migrationSkeleton = `[
+    {
+        "table_name": "table_name",
+        "schema": [
+            {
+                "attribute_definitions": [
+                    {
+                        "name": "id",
+                        "type": "S"
+                    }
+                ],
+                "key_schema": [
+                    {
+                        "name": "id",
+                        "type": "HASH"
+                    }
+                ]
+            }
+        ],
+        "data": [
+            {
+                "id": "1"
+            }
+        ]
+    }
+]
+`
+
+// versionBump - returns the version bump selected by the create flags.
+func (options *commandOptions) versionBump() (pkgDomain.VersionBump, error) {
+	bump := pkgDomain.VersionBumpPatch
+	selected := 0
+	for flagBump, set := range map[pkgDomain.VersionBump]bool{
+		pkgDomain.VersionBumpMinor:     options.minor,
+		pkgDomain.VersionBumpMajor:     options.major,
+		pkgDomain.VersionBumpTimestamp: options.timestamp,
+	} {
+		if set {
+			bump = flagBump
+			selected++
+		}
+	}
+	if selected > 1 {
+		return "", pkgDomain.Errorf(pkgDomain.ErrorKindUsage, "Only one of -minor, -major and -timestamp can be set")
+	}
+	return bump, nil
+}
+
+var titleReplacer = regexp.MustCompile(`[^a-z0-9]+`)
+
+func runCreate(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	title := strings.Trim(titleReplacer.ReplaceAllString(strings.ToLower(args[0]), "_"), "_")
+	if len(title) == 0 {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindUsage, errors.New("Title must contain letters or digits"))
+	}
+	bump, err := options.versionBump()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := env.storage.GetExecutableMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var latest *pkgDomain.Version
+	for _, migration := range migrations {
+		if latest == nil || latest.Less(migration.Version) {
+			latest = &migration.Version
+		}
+	}
+	next, err := pkgDomain.NextVersion(latest, bump, time.Now())
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindValidation, err)
+	}
+	path, err := env.storage.CreateMigration(next.String()+"_"+title+".json", []byte(migrationSkeleton))
+	if err != nil {
+		return nil, err
+	}
+	env.logger.Info("Migration created", pkgDomain.F("path", path), pkgDomain.F("version", next))
+	return &createResult{Version: next, Path: path}, nil
+}
+
+func runBaseline(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	ver, err := pkgDomain.ParseVersion(options.version)
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindUsage, err)
+	}
+	env.logger.Info("Baseline started", pkgDomain.F("version", ver))
+	baselined, err := env.service.Baseline(ctx, ver)
+	result := &baselineResult{Baselined: baselined}
+	if err != nil {
+		return result, err
+	}
+	env.logger.Info("Done", pkgDomain.F("baselined", baselined))
+	return result, nil
+}
+
+func runMarkApplied(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	ver, err := pkgDomain.ParseVersion(args[0])
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindUsage, err)
+	}
+	if err := env.service.MarkApplied(ctx, ver); err != nil {
+		return nil, err
+	}
+	env.logger.Info("Done", pkgDomain.F("version", ver))
+	return &versionResult{Version: ver}, nil
+}
+
+func runUnmark(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	ver, err := pkgDomain.ParseVersion(args[0])
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindUsage, err)
+	}
+	if err := env.service.Unmark(ctx, ver); err != nil {
+		return nil, err
+	}
+	env.logger.Info("Done", pkgDomain.F("version", ver))
+	return &versionResult{Version: ver}, nil
+}
+
+func runRepair(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	env.logger.Info("Repair started")
+	repaired, err := env.service.Repair(ctx)
+	result := &repairResult{Repaired: repaired}
+	if err != nil {
+		return result, err
+	}
+	env.logger.Info("Done", pkgDomain.F("repaired", repaired))
+	return result, nil
+}
+
+func runUnlock(ctx context.Context, env *environment, options *commandOptions, args []string) (interface{}, error) {
+	lock, err := env.service.Unlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		env.logger.Warn("Migrations lock does not exist")
+	}
+	env.logger.Info("Done")
+	return &unlockResult{Lock: lock}, nil
+}
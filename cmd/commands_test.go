@@ -0,0 +1,184 @@
+package main
+
+import (
+	"testing"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+	pkgLogging "dynamodb.data-migration/internal/logging"
+)
+
+func TestFindCommand(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name          string
+		command       string
+		expectedUsage string
+		expectNil     bool
+	}{
+		{
+			name:          "Success: command without arguments",
+			command:       "up",
+			expectedUsage: "up",
+		},
+		{
+			name:          "Success: command with an argument",
+			command:       "mark-applied",
+			expectedUsage: "mark-applied <version>",
+		},
+		{
+			name:          "Success: create with a title",
+			command:       "create",
+			expectedUsage: "create <title>",
+		},
+		{
+			name:      "Fail: unknown command",
+			command:   "migrate",
+			expectNil: true,
+		},
+		{
+			name:      "Fail: command names are case sensitive",
+			command:   "UP",
+			expectNil: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := findCommand(test.command)
+			if test.expectNil {
+				if cmd != nil {
+					t.Errorf("expected no command but got: %s", cmd.name)
+				}
+				return
+			}
+			if cmd == nil {
+				t.Fatalf("command %s not found", test.command)
+			}
+			if usage := cmd.usage(); usage != test.expectedUsage {
+				t.Errorf("actual usage: %q does not match expected: %q", usage, test.expectedUsage)
+			}
+		})
+	}
+}
+
+func TestVersionBump(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name         string
+		options      commandOptions
+		expectedBump pkgDomain.VersionBump
+		expectError  bool
+	}{
+		{
+			name:         "Success: patch by default",
+			expectedBump: pkgDomain.VersionBumpPatch,
+		},
+		{
+			name:         "Success: minor",
+			options:      commandOptions{minor: true},
+			expectedBump: pkgDomain.VersionBumpMinor,
+		},
+		{
+			name:         "Success: major",
+			options:      commandOptions{major: true},
+			expectedBump: pkgDomain.VersionBumpMajor,
+		},
+		{
+			name:         "Success: timestamp",
+			options:      commandOptions{timestamp: true},
+			expectedBump: pkgDomain.VersionBumpTimestamp,
+		},
+		{
+			name:        "Fail: minor and major",
+			options:     commandOptions{minor: true, major: true},
+			expectError: true,
+		},
+		{
+			name:        "Fail: major and timestamp",
+			options:     commandOptions{major: true, timestamp: true},
+			expectError: true,
+		},
+		{
+			name:        "Fail: all bumps",
+			options:     commandOptions{minor: true, major: true, timestamp: true},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bump, err := test.options.versionBump()
+			if test.expectError {
+				if kind := pkgDomain.ErrorKindOf(err); kind != pkgDomain.ErrorKindUsage {
+					t.Errorf("expected usage error but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if bump != test.expectedBump {
+				t.Errorf("actual bump: %s does not match expected: %s", bump, test.expectedBump)
+			}
+		})
+	}
+}
+
+func TestCommandArguments(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name        string
+		args        []string
+		expectError bool
+	}{
+		{
+			name: "Success: command without arguments",
+			args: []string{"schema"},
+		},
+		{
+			name:        "Fail: missing argument",
+			args:        []string{"create"},
+			expectError: true,
+		},
+		{
+			name:        "Fail: missing argument after flags",
+			args:        []string{"create", "-minor"},
+			expectError: true,
+		},
+		{
+			name:        "Fail: extra argument",
+			args:        []string{"mark-applied", "1.0.0", "1.0.1"},
+			expectError: true,
+		},
+		{
+			name:        "Fail: argument of a command without arguments",
+			args:        []string{"unlock", "now"},
+			expectError: true,
+		},
+		{
+			name:        "Fail: unknown command",
+			args:        []string{"migrate"},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exec := &execution{
+				output:    outputText,
+				logLevel:  pkgDomain.LogLevelError.String(),
+				logFormat: string(pkgLogging.FormatText),
+			}
+			err := execute(test.args, exec)
+			if test.expectError {
+				if kind := pkgDomain.ErrorKindOf(err); kind != pkgDomain.ErrorKindUsage {
+					t.Errorf("expected usage error but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
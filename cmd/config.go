@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+
+	pkgBackup "dynamodb.data-migration/internal/backup"
+	pkgConfig "dynamodb.data-migration/internal/config"
+	pkgDomain "dynamodb.data-migration/internal/domain"
+	pkgDynamodb "dynamodb.data-migration/internal/dynamodb"
+	pkgStorage "dynamodb.data-migration/internal/filestorage"
+	pkgHooks "dynamodb.data-migration/internal/hooks"
+	pkgIdentity "dynamodb.data-migration/internal/identity"
+	pkgLogging "dynamodb.data-migration/internal/logging"
+	pkgMigration "dynamodb.data-migration/internal/migration"
+	pkgNotifier "dynamodb.data-migration/internal/notifier"
+	pkgParser "dynamodb.data-migration/internal/parser"
+	pkgTracing "dynamodb.data-migration/internal/tracing"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// loadMigrationContext - returns the migration context with defaults overridden by the config file profile
+// and environment variables. The config file and profile are looked up in args before the flags are parsed,
+// since the profile provides defaults of the other flags.
+func loadMigrationContext(args []string) (*pkgDomain.MigrationContext, error) {
+	migrationContext := pkgDomain.NewMigrationContext()
+	migrationContext.MigrationsDir = "migrations"
+	migrationContext.MigrationsTable = "x_migrations"
+	migrationContext.LockTTL = pkgDomain.DefaultLockTTL
+	migrationContext.Retry = pkgDomain.DefaultRetryPolicy()
+	migrationContext.UnitPrices = pkgDomain.DefaultUnitPrices()
+	migrationContext.Metrics.Job = pkgDomain.DefaultMetricsJob
+	migrationContext.Tracing.ServiceName = pkgDomain.DefaultTracingServiceName
+
+	configPath, isConfigSet := lookupFlag(args, "config")
+	if !isConfigSet {
+		configPath, isConfigSet = lookupEnv("MIGRATIONS_CONFIG")
+	}
+	if !isConfigSet {
+		configPath = pkgConfig.DefaultPath
+	}
+	profile, isProfileSet := lookupFlag(args, "profile")
+	if !isProfileSet {
+		profile, isProfileSet = lookupEnv("MIGRATIONS_PROFILE")
+	}
+	configFile, err := pkgConfig.Load(configPath, !isConfigSet)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case configFile != nil:
+		if err := configFile.Apply(profile, migrationContext); err != nil {
+			return nil, err
+		}
+	case isProfileSet && len(profile) > 0:
+		return nil, pkgDomain.Errorf(pkgDomain.ErrorKindConfig, "Profile %q requires the config file %s", profile, configPath)
+	}
+
+	setFromEnv(&migrationContext.MigrationsDir, "MIGRATIONS_DIR")
+	setFromEnv(&migrationContext.MigrationsTable, "MIGRATIONS_TABLE_NAME")
+	setFromEnv(&migrationContext.TablePrefix, "MIGRATIONS_TABLE_PREFIX")
+	setFromEnv(&migrationContext.Runner, "MIGRATIONS_RUNNER")
+	setFromEnv(&migrationContext.BackupEndpoint, "MIGRATIONS_BACKUP_ENDPOINT")
+	setFromEnv(&migrationContext.Metrics.PushURL, "MIGRATIONS_METRICS_PUSH_URL")
+	setFromEnv(&migrationContext.Tracing.Endpoint, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	setFromEnv(&migrationContext.Tracing.ServiceName, "OTEL_SERVICE_NAME")
+	if url, ok := lookupEnv("MIGRATIONS_WEBHOOK_URL"); ok {
+		migrationContext.Webhooks = append(migrationContext.Webhooks, newSlackWebhook(url))
+	}
+	setFromEnv(&migrationContext.Region, "AWS_REGION")
+	// Don't use mock server in production otherwise it will override the real endpoint.
+	setFromEnv(&migrationContext.Endpoint, "AWS_MOCK_SERVER_ADDRESS")
+	return migrationContext, nil
+}
+
+// registerCommonFlags - registers flags shared by all commands, current values are used as defaults.
+func registerCommonFlags(fs *flag.FlagSet, migrationContext *pkgDomain.MigrationContext) {
+	// Config and profile are read by loadMigrationContext, they are registered to be accepted and listed in usage.
+	fs.String("config", pkgConfig.DefaultPath, "config file with profiles, env: MIGRATIONS_CONFIG")
+	fs.String("profile", migrationContext.Profile, "config file profile, e.g. dev, staging or prod, env: MIGRATIONS_PROFILE")
+	fs.StringVar(&migrationContext.MigrationsDir, "migrations", migrationContext.MigrationsDir, "directory where the migration files are located")
+	fs.StringVar(&migrationContext.MigrationsTable, "x-migrations-table", migrationContext.MigrationsTable, "name of the migrations table")
+	fs.StringVar(&migrationContext.TablePrefix, "table-prefix", migrationContext.TablePrefix, "prefix added to the table names of migration files")
+	fs.StringVar(&migrationContext.Region, "region", migrationContext.Region, "AWS region")
+	fs.StringVar(&migrationContext.Endpoint, "endpoint", migrationContext.Endpoint, "AWS endpoint, e.g. of a local DynamoDB")
+	fs.StringVar(&migrationContext.BackupEndpoint, "backup-endpoint", migrationContext.BackupEndpoint, "AWS endpoint of backup requests, e.g. of a local stand-in without backups (default: the endpoint), env: MIGRATIONS_BACKUP_ENDPOINT")
+	fs.StringVar(&migrationContext.Runner, "runner", migrationContext.Runner, "runner identity recorded in migration records (default: the caller ARN from STS, the local user if it cannot be resolved)")
+	fs.DurationVar(&migrationContext.LockTTL, "lock-ttl", migrationContext.LockTTL, "time after which the lock of a crashed run expires")
+	fs.IntVar(&migrationContext.Retry.MaxAttempts, "retry-max-attempts", migrationContext.Retry.MaxAttempts, "maximum attempts of throttled or conflicting AWS requests, 1 disables retries")
+	fs.DurationVar(&migrationContext.Retry.BaseDelay, "retry-base-delay", migrationContext.Retry.BaseDelay, "delay before the first retry, doubled for every next retry")
+	fs.DurationVar(&migrationContext.Retry.MaxDelay, "retry-max-delay", migrationContext.Retry.MaxDelay, "maximum delay between retries")
+	fs.Float64Var(&migrationContext.Retry.Jitter, "retry-jitter", migrationContext.Retry.Jitter, "randomized fraction of the retry delay, from 0 to 1")
+	fs.Float64Var(&migrationContext.RateLimits.WriteUnits, "write-capacity", migrationContext.RateLimits.WriteUnits, "write capacity units per second migrations may consume in all tables (default: no limit)")
+	fs.Float64Var(&migrationContext.RateLimits.ReadUnits, "read-capacity", migrationContext.RateLimits.ReadUnits, "read capacity units per second migrations may consume in all tables (default: no limit)")
+	fs.Float64Var(&migrationContext.UnitPrices.ReadPerMillion, "read-unit-price", migrationContext.UnitPrices.ReadPerMillion, "price in USD per million read request units, used to estimate the cost")
+	fs.Float64Var(&migrationContext.UnitPrices.WritePerMillion, "write-unit-price", migrationContext.UnitPrices.WritePerMillion, "price in USD per million write request units, used to estimate the cost")
+	fs.StringVar(&migrationContext.Metrics.Addr, "metrics-addr", migrationContext.Metrics.Addr, "address of the Prometheus metrics endpoint served while the command runs, e.g. :9090")
+	fs.StringVar(&migrationContext.Metrics.PushURL, "metrics-push-url", migrationContext.Metrics.PushURL, "Pushgateway URL the metrics are pushed to when the command ends, env: MIGRATIONS_METRICS_PUSH_URL")
+	fs.StringVar(&migrationContext.Metrics.Job, "metrics-job", migrationContext.Metrics.Job, "job label of the pushed metrics")
+	fs.StringVar(&migrationContext.Tracing.Endpoint, "otlp-endpoint", migrationContext.Tracing.Endpoint, "URL of the OTLP/HTTP collector receiving traces, e.g. http://localhost:4318, env: OTEL_EXPORTER_OTLP_ENDPOINT")
+	fs.Var(&webhookFlag{migrationContext: migrationContext}, "webhook-url", "Slack-compatible webhook notified when up or down starts, succeeds or fails, may be repeated, env: MIGRATIONS_WEBHOOK_URL")
+	fs.DurationVar(&migrationContext.Timeout, "timeout", migrationContext.Timeout, "maximum duration of the command, e.g. 10m (default: no limit)")
+}
+
+// webhookFlag - adds a webhook to the migration context every time the flag is set.
+type webhookFlag struct {
+	migrationContext *pkgDomain.MigrationContext
+}
+
+func (f *webhookFlag) String() string {
+	return ""
+}
+
+func (f *webhookFlag) Set(url string) error {
+	f.migrationContext.Webhooks = append(f.migrationContext.Webhooks, newSlackWebhook(url))
+	return nil
+}
+
+// newSlackWebhook - webhook of the CLI flag or env, the config file sets other formats and events.
+func newSlackWebhook(url string) pkgDomain.Webhook {
+	return pkgDomain.Webhook{URL: url, Format: pkgDomain.WebhookFormatSlack}
+}
+
+// Output formats.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// registerOutputFlag - registers the output format flag.
+func registerOutputFlag(fs *flag.FlagSet, output *string) {
+	fs.StringVar(output, "output", *output, "output format: text or json, json prints the result document to stdout")
+}
+
+// registerLogFlags - registers the log level and format flags, logs are written to stderr.
+func registerLogFlags(fs *flag.FlagSet, exec *execution) {
+	fs.StringVar(&exec.logLevel, "log-level", exec.logLevel, "minimal level of logged entries: debug, info, warn or error")
+	fs.StringVar(&exec.logFormat, "log-format", exec.logFormat, "format of log entries: text or json")
+}
+
+// newLogger - constructs the stderr logger selected by the log flags.
+func newLogger(level, format string) (pkgDomain.Logger, error) {
+	logLevel, err := pkgDomain.ParseLogLevel(level)
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindUsage, err)
+	}
+	logFormat, err := pkgLogging.ParseFormat(format)
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindUsage, err)
+	}
+	return pkgLogging.NewLogger(os.Stderr, logLevel, logFormat), nil
+}
+
+// registerRunFlags - registers flags of commands that select migrations to apply or revert.
+func registerRunFlags(fs *flag.FlagSet, migrationContext *pkgDomain.MigrationContext) {
+	fs.StringVar(&migrationContext.TargetVersion, "target", migrationContext.TargetVersion, "last version to apply or keep applied, e.g. 1.2.0")
+	fs.IntVar(&migrationContext.Steps, "steps", migrationContext.Steps, "number of migrations to apply or revert (default: no limit for up, 1 for down)")
+	fs.BoolVar(&migrationContext.AllowFailed, "allow-failed", migrationContext.AllowFailed, "retry failed or in-progress migrations instead of stopping at them")
+}
+
+// newEnvironment - builds the layers of the service "onion" from the inside out.
+func newEnvironment(ctx context.Context, migrationContext *pkgDomain.MigrationContext, withAWS bool, logger pkgDomain.Logger, metrics pkgDomain.Metrics, tracer trace.Tracer) (*environment, error) {
+	env := &environment{
+		migrationContext: migrationContext,
+		logger:           logger,
+		storage:          pkgStorage.NewMigrationStorage(migrationContext.MigrationsDir),
+		queryParser:      pkgParser.NewPrefixedQueryParser(pkgParser.NewQueryParser(), migrationContext.TablePrefix),
+	}
+	if !withAWS {
+		return env, nil
+	}
+	awsSession, err := getAwsSession(migrationContext)
+	if err != nil {
+		return nil, pkgDomain.NewError(pkgDomain.ErrorKindConfig, err)
+	}
+	pkgTracing.InstrumentSession(awsSession, tracer)
+	notifier, err := newNotifier(migrationContext, logger)
+	if err != nil {
+		return nil, err
+	}
+	migrationRepository, err := pkgDynamodb.NewMigrationRepository(ctx, awsSession, migrationContext.MigrationsTable,
+		pkgDynamodb.WithLogger(logger), pkgDynamodb.WithRetryPolicy(migrationContext.Retry),
+		pkgDynamodb.WithRateLimits(migrationContext.RateLimits), pkgDynamodb.WithMetrics(metrics),
+		pkgDynamodb.WithTracer(tracer))
+	if err != nil {
+		return nil, err
+	}
+	backuper, err := newBackuper(migrationContext, awsSession, logger, metrics, tracer)
+	if err != nil {
+		return nil, err
+	}
+	options := []pkgMigration.Option{
+		pkgMigration.WithRunner(pkgIdentity.GetRunner(ctx, awsSession, AppVersion, migrationContext.Runner, logger)),
+		pkgMigration.WithLockTTL(migrationContext.LockTTL),
+		pkgMigration.WithLogger(logger),
+		pkgMigration.WithUnitPrices(migrationContext.UnitPrices),
+		pkgMigration.WithMetrics(metrics),
+		pkgMigration.WithTracer(tracer),
+		pkgMigration.WithNotifier(notifier),
+		pkgMigration.WithBackuper(backuper),
+	}
+	// Output of hook commands goes to stderr, stdout is kept for the command result.
+	for _, hook := range migrationContext.Hooks {
+		options = append(options, pkgMigration.WithHook(hook.Stage, pkgHooks.NewCommandHook(hook, os.Stderr)))
+	}
+	env.service = pkgMigration.NewMigrationService(
+		migrationRepository,
+		env.storage,
+		env.queryParser,
+		options...,
+	)
+	return env, nil
+}
+
+// newBackuper - constructs the backuper of the tables listed in backup_before, backup requests use
+// a session of the backup endpoint if it is set, e.g. as local DynamoDB has no backups.
+func newBackuper(migrationContext *pkgDomain.MigrationContext, awsSession *session.Session, logger pkgDomain.Logger, metrics pkgDomain.Metrics, tracer trace.Tracer) (pkgDomain.TableBackuper, error) {
+	if len(migrationContext.BackupEndpoint) > 0 {
+		backupContext := *migrationContext
+		backupContext.Endpoint = migrationContext.BackupEndpoint
+		var err error
+		if awsSession, err = getAwsSession(&backupContext); err != nil {
+			return nil, pkgDomain.NewError(pkgDomain.ErrorKindConfig, err)
+		}
+		pkgTracing.InstrumentSession(awsSession, tracer)
+	}
+	return pkgBackup.NewTableBackuper(awsSession,
+		pkgBackup.WithLogger(logger), pkgBackup.WithRetryPolicy(migrationContext.Retry),
+		pkgBackup.WithMetrics(metrics)), nil
+}
+
+// newNotifier - constructs the notifier of the webhooks, notifications carry the profile as the environment.
+func newNotifier(migrationContext *pkgDomain.MigrationContext, logger pkgDomain.Logger) (pkgDomain.Notifier, error) {
+	if len(migrationContext.Webhooks) == 0 {
+		return pkgNotifier.NewNopNotifier(), nil
+	}
+	environment := migrationContext.Profile
+	if len(environment) == 0 {
+		environment = "default"
+	}
+	return pkgNotifier.NewNotifier(migrationContext.Webhooks, environment, &http.Client{}, logger)
+}
+
+func getAwsSession(migrationContext *pkgDomain.MigrationContext) (*session.Session, error) {
+	// Requests are retried by the retry policy of the migration context, the retries of the AWS SDK would multiply its attempts.
+	config := &aws.Config{MaxRetries: aws.Int(0)}
+	if len(migrationContext.Region) > 0 {
+		config.Region = aws.String(migrationContext.Region)
+	}
+	if len(migrationContext.Endpoint) > 0 {
+		config.Endpoint = aws.String(migrationContext.Endpoint)
+		config.S3ForcePathStyle = aws.Bool(true) // always must be true for mock servers
+	}
+	return session.NewSession(config)
+}
+
+// setFromEnv - overrides the value if the environment variable is set and not empty.
+func setFromEnv(target *string, key string) {
+	if value, ok := lookupEnv(key); ok {
+		*target = value
+	}
+}
+
+// lookupEnv - returns the value of the environment variable, empty variables are treated as unset,
+// e.g. a variable passed to a container from an unset shell variable.
+func lookupEnv(key string) (string, bool) {
+	value := os.Getenv(key)
+	return value, len(value) > 0
+}
+
+// lookupFlag - returns the value of a string flag before the flags are parsed.
+func lookupFlag(args []string, name string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		trimmed := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if trimmed == arg {
+			continue
+		}
+		if trimmed == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if strings.HasPrefix(trimmed, name+"=") {
+			return trimmed[len(name)+1:], true
+		}
+	}
+	return "", false
+}
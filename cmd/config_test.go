@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setEnv - sets the environment variables, unset for nil values, returns a function restoring them.
+func setEnv(t *testing.T, env map[string]*string) func() {
+	previous := make(map[string]*string, len(env))
+	for key, value := range env {
+		if old, ok := os.LookupEnv(key); ok {
+			previous[key] = &old
+		} else {
+			previous[key] = nil
+		}
+		if value == nil {
+			_ = os.Unsetenv(key)
+		} else if err := os.Setenv(key, *value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for key, value := range previous {
+			if value == nil {
+				_ = os.Unsetenv(key)
+			} else {
+				_ = os.Setenv(key, *value)
+			}
+		}
+	}
+}
+
+func TestLookupFlag(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name          string
+		args          []string
+		flag          string
+		expectedValue string
+		expectFound   bool
+	}{
+		{
+			name:          "Success: separate value",
+			args:          []string{"-profile", "prod", "up"},
+			flag:          "profile",
+			expectedValue: "prod",
+			expectFound:   true,
+		},
+		{
+			name:          "Success: double dash and equals sign",
+			args:          []string{"up", "--profile=prod"},
+			flag:          "profile",
+			expectedValue: "prod",
+			expectFound:   true,
+		},
+		{
+			name:          "Success: empty value",
+			args:          []string{"-config="},
+			flag:          "config",
+			expectedValue: "",
+			expectFound:   true,
+		},
+		{
+			name:          "Success: first occurrence",
+			args:          []string{"-profile", "dev", "up", "-profile", "prod"},
+			flag:          "profile",
+			expectedValue: "dev",
+			expectFound:   true,
+		},
+		{
+			name: "Fail: flag not set",
+			args: []string{"-config", "migrations.yml", "up"},
+			flag: "profile",
+		},
+		{
+			name: "Fail: flag with a longer name",
+			args: []string{"-profiles=prod"},
+			flag: "profile",
+		},
+		{
+			name: "Fail: argument without dash",
+			args: []string{"create", "profile"},
+			flag: "profile",
+		},
+		{
+			name: "Fail: flag without value",
+			args: []string{"up", "-profile"},
+			flag: "profile",
+		},
+		{
+			name: "Fail: flag after terminator",
+			args: []string{"create", "--", "-profile", "prod"},
+			flag: "profile",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, found := lookupFlag(test.args, test.flag)
+			if found != test.expectFound {
+				t.Errorf("actual found: %t does not match expected: %t", found, test.expectFound)
+			}
+			if value != test.expectedValue {
+				t.Errorf("actual value: %q does not match expected: %q", value, test.expectedValue)
+			}
+		})
+	}
+}
+
+func TestMigrationContextPrecedence(t *testing.T) {
+	var (
+		empty   = ""
+		fromEnv = "env_migrations"
+		config  = "profiles:\n  dev:\n    migrations_table: config_migrations\n"
+	)
+
+	// Test.
+	tests := []struct {
+		name  string
+		env   *string
+		flags []string
+		// withConfig - the dev profile of the config file is selected.
+		withConfig    bool
+		expectedTable string
+	}{
+		{
+			name:          "Success: default",
+			expectedTable: "x_migrations",
+		},
+		{
+			name:          "Success: config file overrides the default",
+			withConfig:    true,
+			expectedTable: "config_migrations",
+		},
+		{
+			name:          "Success: environment variable overrides the config file",
+			env:           &fromEnv,
+			withConfig:    true,
+			expectedTable: "env_migrations",
+		},
+		{
+			name:          "Success: empty environment variable keeps the config file",
+			env:           &empty,
+			withConfig:    true,
+			expectedTable: "config_migrations",
+		},
+		{
+			name:          "Success: empty environment variable keeps the default",
+			env:           &empty,
+			expectedTable: "x_migrations",
+		},
+		{
+			name:          "Success: flag overrides the environment variable",
+			env:           &fromEnv,
+			flags:         []string{"-x-migrations-table", "flag_migrations"},
+			withConfig:    true,
+			expectedTable: "flag_migrations",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			restore := setEnv(t, map[string]*string{
+				"MIGRATIONS_TABLE_NAME": test.env,
+				"MIGRATIONS_CONFIG":     nil,
+				"MIGRATIONS_PROFILE":    nil,
+			})
+			defer restore()
+			var args []string
+			if test.withConfig {
+				path := filepath.Join(t.TempDir(), "migrations.yaml")
+				if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "-config", path, "-profile", "dev")
+			}
+			args = append(args, test.flags...)
+
+			migrationContext, err := loadMigrationContext(args)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			registerCommonFlags(fs, migrationContext)
+			if err := fs.Parse(args); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if migrationContext.MigrationsTable != test.expectedTable {
+				t.Errorf("actual migrations table: %q does not match expected: %q", migrationContext.MigrationsTable, test.expectedTable)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+)
+
+// newCommandContext - returns the command context canceled by SIGINT, SIGTERM or after the timeout, 0 means no timeout.
+// The first signal stops a run between migrations, the second one terminates the process.
+func newCommandContext(timeout time.Duration, logger pkgDomain.Logger) (context.Context, context.CancelFunc) {
+	var (
+		baseCtx    context.Context
+		cancelBase context.CancelFunc
+	)
+	if timeout > 0 {
+		baseCtx, cancelBase = context.WithTimeout(context.Background(), timeout)
+	} else {
+		baseCtx, cancelBase = context.WithCancel(context.Background())
+	}
+	ctx, stopSignals := signal.NotifyContext(baseCtx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		switch baseCtx.Err() {
+		case nil:
+			logger.Warn("Interrupted, stopping after the running migration, interrupt again to exit immediately")
+		case context.DeadlineExceeded:
+			logger.Warn("Timeout exceeded, stopping", pkgDomain.F("timeout", timeout))
+		}
+		// Restore the default behavior, the next signal terminates the process.
+		stopSignals()
+	}()
+	return ctx, func() {
+		cancelBase()
+		stopSignals()
+	}
+}
@@ -1,42 +1,86 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	pkgDomain "dynamodb.data-migration/internal/domain"
-	pkgDynamodb "dynamodb.data-migration/internal/dynamodb"
-	pkgStorage "dynamodb.data-migration/internal/filestorage"
-	pkgMigration "dynamodb.data-migration/internal/migration"
-	pkgParser "dynamodb.data-migration/internal/parser"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	pkgLogging "dynamodb.data-migration/internal/logging"
 )
 
 // AppVersion - application version.
 var AppVersion string = "unversioned"
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	// Define our flags.
-	//
-	migrationContext := pkgDomain.NewMigrationContext()
-	flag.StringVar(&migrationContext.MigrationsDir, "migrations", "migrations", "directory where the migration files are located")
-	flag.StringVar(&migrationContext.MigrationsTable, "x-migrations-table", "x_migrations", "name of the migrations table")
-	help := flag.Bool("help", false, "Display usage")
-	version := flag.Bool("version", false, "Print version & exit")
+// run - executes the command and prints the summary, returns the exit code.
+func run(args []string) int {
+	startTime := time.Now()
+	exec := &execution{
+		output:    outputText,
+		logLevel:  pkgDomain.LogLevelInfo.String(),
+		logFormat: string(pkgLogging.FormatText),
+	}
+	err := execute(args, exec)
+	if len(exec.command) == 0 && err == nil {
+		// Help or version was printed.
+		return exitOK
+	}
+	if err != nil {
+		logger := exec.logger
+		if logger == nil {
+			// Flags are not parsed yet.
+			logger = pkgLogging.NewLogger(os.Stderr, pkgDomain.LogLevelInfo, pkgLogging.FormatText)
+		}
+		logger.Error(err.Error(), pkgDomain.F("kind", pkgDomain.ErrorKindOf(err)))
+	}
+	code := exitCodeOf(err)
+	s := newSummary(exec.command, err, code, startTime)
+	s.Result = exec.result
+	printSummary(s, exec.output)
+	return code
+}
+
+// execution - command being executed and its result.
+type execution struct {
+	command   string
+	output    string
+	logLevel  string
+	logFormat string
+	// logger - logger configured by the log flags, nil until the flags are parsed.
+	logger pkgDomain.Logger
+	result interface{}
+}
 
-	flag.Usage = usageFor(os.Args[0] + " [flags]")
-	flag.CommandLine.SetOutput(os.Stdout)
-	flag.Parse()
+// execute - parses flags and runs the command, fills the execution.
+func execute(args []string, exec *execution) error {
 
+	// Load the migration context: defaults, environment variables, global flags.
+	//
+	migrationContext, err := loadMigrationContext(args)
+	if err != nil {
+		return err
+	}
+	globalFlags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	registerCommonFlags(globalFlags, migrationContext)
+	registerOutputFlag(globalFlags, &exec.output)
+	registerLogFlags(globalFlags, exec)
+	registerRunFlags(globalFlags, migrationContext)
+	help := globalFlags.Bool("help", false, "Display usage")
+	version := globalFlags.Bool("version", false, "Print version & exit")
+	globalFlags.Usage = usageFor(globalFlags)
+	if err := globalFlags.Parse(args); err != nil {
+		return parseError(err)
+	}
 	if *help {
-		fmt.Fprintf(os.Stdout, "Usage:\n")
-		flag.PrintDefaults()
-		return
+		globalFlags.Usage()
+		return nil
 	}
 	if *version {
 		appVersion := AppVersion
@@ -44,64 +88,168 @@ func main() {
 			appVersion = "unversioned"
 		}
 		fmt.Println(appVersion)
-		return
+		return nil
 	}
 
-	// Check migration context.
+	// Find the command, migrations are applied if it is omitted.
 	//
-	if err := migrationContext.Validate(); err != nil {
-		flag.Usage()
-		log.Fatal(err)
+	name := globalFlags.Arg(0)
+	if len(name) == 0 {
+		name = "up"
+	}
+	exec.command = name
+	cmd := findCommand(name)
+	if cmd == nil {
+		globalFlags.Usage()
+		return pkgDomain.Errorf(pkgDomain.ErrorKindUsage, "Unknown command: %s", name)
 	}
 
-	// Setup AWS session.
+	// Parse command flags, they override global flags.
 	//
-	awsSession := getAwsSession()
+	commandFlags := flag.NewFlagSet(name, flag.ContinueOnError)
+	registerCommonFlags(commandFlags, migrationContext)
+	registerOutputFlag(commandFlags, &exec.output)
+	registerLogFlags(commandFlags, exec)
+	if cmd.runFlags {
+		registerRunFlags(commandFlags, migrationContext)
+	}
+	options := &commandOptions{}
+	if cmd.setup != nil {
+		cmd.setup(commandFlags, options)
+	}
+	commandFlags.Usage = commandUsageFor(cmd, commandFlags)
+	var commandArgs []string
+	if globalFlags.NArg() > 0 {
+		commandArgs = globalFlags.Args()[1:]
+	}
+	if err := commandFlags.Parse(commandArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			exec.command = ""
+		}
+		return parseError(err)
+	}
+	if exec.output != outputText && exec.output != outputJSON {
+		commandFlags.Usage()
+		return pkgDomain.Errorf(pkgDomain.ErrorKindUsage, "Unknown output format: %s", exec.output)
+	}
+	exec.logger, err = newLogger(exec.logLevel, exec.logFormat)
+	if err != nil {
+		commandFlags.Usage()
+		return err
+	}
+	if commandFlags.NArg() != len(cmd.args) {
+		commandFlags.Usage()
+		return pkgDomain.Errorf(pkgDomain.ErrorKindUsage,
+			"Command %s expects %d argument(s), got %d", cmd.name, len(cmd.args), commandFlags.NArg())
+	}
 
-	// Build the layers of the service "onion" from the inside out.
+	// Check migration context.
 	//
-	migrationStorage := pkgStorage.NewMigrationStorage(migrationContext.MigrationsDir)
-	migrationRepository := pkgDynamodb.NewMigrationRepository(awsSession, migrationContext.MigrationsTable)
-	migrationService := pkgMigration.NewMigrationService(migrationRepository, migrationStorage, pkgParser.NewQueryParser())
+	if err := migrationContext.Validate(); err != nil {
+		commandFlags.Usage()
+		return err
+	}
 
-	// Run migrations.
+	// Run the command, it is stopped by SIGINT, SIGTERM or the timeout.
 	//
-	log.Println("Migration started")
-	applied, err := migrationService.Migrate()
+	ctx, cancel := newCommandContext(migrationContext.Timeout, exec.logger)
+	defer cancel()
+	metrics, stopMetrics, err := startMetrics(migrationContext.Metrics, exec.logger)
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
+	ctx, tracer, endTracing, err := startTracing(ctx, migrationContext.Tracing, name, exec.logger)
 	if err != nil {
-		log.Println(err.Error())
+		return err
+	}
+	env, err := newEnvironment(ctx, migrationContext, cmd.aws, exec.logger, metrics, tracer)
+	if err == nil {
+		env.output = exec.output
+		exec.result, err = cmd.run(ctx, env, options, commandFlags.Args())
+	}
+	endTracing(err)
+	if pkgDomain.ErrorKindOf(err) == pkgDomain.ErrorKindUsage {
+		commandFlags.Usage()
+	}
+	return err
+}
+
+// parseError - flag package prints the error and usage itself.
+func parseError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
 	}
-	log.Println("Done", applied)
+	return pkgDomain.NewError(pkgDomain.ErrorKindUsage, err)
 }
 
-func usageFor(short string) func() {
+func usageFor(fs *flag.FlagSet) func() {
 	return func() {
 		_, _ = fmt.Fprintf(os.Stderr, "USAGE\n")
-		_, _ = fmt.Fprintf(os.Stderr, "  %s\n", short)
+		_, _ = fmt.Fprintf(os.Stderr, "  %s [flags] <command> [command flags] [arguments]\n", os.Args[0])
 		_, _ = fmt.Fprintf(os.Stderr, "\n")
 		_, _ = fmt.Fprintf(os.Stderr, "INFO\n")
 		_, _ = fmt.Fprintf(os.Stderr, "  version:  %s\n", AppVersion)
 		_, _ = fmt.Fprintf(os.Stderr, "\n")
-		_, _ = fmt.Fprintf(os.Stderr, "FLAGS\n")
+		_, _ = fmt.Fprintf(os.Stderr, "COMMANDS\n")
 		w := tabwriter.NewWriter(os.Stderr, 0, 2, 2, ' ', 0)
-
-		flag.VisitAll(func(f *flag.Flag) {
-			_, _ = fmt.Fprintf(w, "\t-%s\t%s\t%s\n", f.Name, f.DefValue, f.Usage)
-		})
-
+		for _, cmd := range commands {
+			_, _ = fmt.Fprintf(w, "\t%s\t%s\n", cmd.usage(), cmd.summary)
+		}
 		_ = w.Flush()
 		_, _ = fmt.Fprintf(os.Stderr, "\n")
+		_, _ = fmt.Fprintf(os.Stderr, "FLAGS\n")
+		printFlags(fs)
+		printExitCodes()
 	}
 }
 
-func getAwsSession() *session.Session {
-	// Don't use mock server in production otherwise it will override the real s3 endpoint.
-	mockServerAddress := os.Getenv("AWS_MOCK_SERVER_ADDRESS")
-	if len(mockServerAddress) > 0 {
-		return session.Must(session.NewSession(&aws.Config{
-			Endpoint:         aws.String(mockServerAddress),
-			S3ForcePathStyle: aws.Bool(true), // always must be true for mock servers
-		}))
+func commandUsageFor(cmd *command, fs *flag.FlagSet) func() {
+	return func() {
+		_, _ = fmt.Fprintf(os.Stderr, "USAGE\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s [flags] %s\n", os.Args[0], cmd.usage())
+		_, _ = fmt.Fprintf(os.Stderr, "\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s\n", cmd.summary)
+		_, _ = fmt.Fprintf(os.Stderr, "\n")
+		_, _ = fmt.Fprintf(os.Stderr, "FLAGS\n")
+		printFlags(fs)
+		printExitCodes()
 	}
-	return session.Must(session.NewSession())
+}
+
+func printFlags(fs *flag.FlagSet) {
+	w := tabwriter.NewWriter(os.Stderr, 0, 2, 2, ' ', 0)
+	fs.VisitAll(func(f *flag.Flag) {
+		_, _ = fmt.Fprintf(w, "\t-%s\t%s\t%s\n", f.Name, f.DefValue, f.Usage)
+	})
+	_ = w.Flush()
+	_, _ = fmt.Fprintf(os.Stderr, "\n")
+}
+
+func printExitCodes() {
+	_, _ = fmt.Fprintf(os.Stderr, "EXIT CODES\n")
+	w := tabwriter.NewWriter(os.Stderr, 0, 2, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "\t%d\t%s\t%s\n", exitOK, "ok", "command succeeded")
+	for _, exitCode := range exitCodes {
+		_, _ = fmt.Fprintf(w, "\t%d\t%s\t%s\n", exitCode.code, exitCode.kind, exitCode.description)
+	}
+	_ = w.Flush()
+	_, _ = fmt.Fprintf(os.Stderr, "\n")
+}
+
+// environment - dependencies shared by commands.
+type environment struct {
+	migrationContext *pkgDomain.MigrationContext
+	storage          pkgDomain.MigrationStorage
+	queryParser      pkgDomain.QueryParser
+	// service - migration service, nil for commands without AWS access.
+	service pkgDomain.MigrationService
+	// output - output format, text or json.
+	output string
+	logger pkgDomain.Logger
+}
+
+// isTextOutput - checks if commands print text, in the json mode the result document is printed instead.
+func (env *environment) isTextOutput() bool {
+	return env.output == outputText
 }
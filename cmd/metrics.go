@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+	pkgMetrics "dynamodb.data-migration/internal/metrics"
+)
+
+// metricsTimeout - time to push metrics and stop the endpoint when the command ends, also after an interrupt.
+const metricsTimeout = 10 * time.Second
+
+// startMetrics - returns the registry of the command metrics, the endpoint is started if its address is set.
+// The returned stop function pushes the metrics if the push URL is set and stops the endpoint.
+func startMetrics(export pkgDomain.MetricsExport, logger pkgDomain.Logger) (*pkgMetrics.Registry, func(), error) {
+	registry := pkgMetrics.NewRegistry()
+	var server *pkgMetrics.Server
+	if len(export.Addr) > 0 {
+		var err error
+		if server, err = pkgMetrics.Serve(export.Addr, registry, logger); err != nil {
+			return nil, nil, err
+		}
+	}
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+		defer cancel()
+		if len(export.PushURL) > 0 {
+			client := &http.Client{Timeout: metricsTimeout}
+			if err := pkgMetrics.Push(ctx, client, export.PushURL, export.Job, registry); err != nil {
+				logger.Warn("Cannot push metrics", pkgDomain.F("error", err))
+			} else {
+				logger.Debug("Metrics pushed", pkgDomain.F("url", export.PushURL), pkgDomain.F("job", export.Job))
+			}
+		}
+		if server != nil {
+			_ = server.Shutdown(ctx)
+		}
+	}
+	return registry, stop, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+)
+
+// validateResult - result of the validate command.
+type validateResult struct {
+	Migrations int                          `json:"migrations"`
+	Issues     []*pkgDomain.ValidationIssue `json:"issues"`
+}
+
+// createResult - result of the create command.
+type createResult struct {
+	Version pkgDomain.Version `json:"version"`
+	Path    string            `json:"path"`
+}
+
+// baselineResult - result of the baseline command.
+type baselineResult struct {
+	Baselined int `json:"baselined"`
+}
+
+// versionResult - result of commands changing a single migration record.
+type versionResult struct {
+	Version pkgDomain.Version `json:"version"`
+}
+
+// repairResult - result of the repair command.
+type repairResult struct {
+	Repaired int `json:"repaired"`
+}
+
+// unlockResult - result of the unlock command.
+type unlockResult struct {
+	// Lock - removed lock, nil if there was no lock.
+	Lock *pkgDomain.Lock `json:"lock"`
+}
+
+func printReport(report *pkgDomain.RunReport) {
+	if report == nil || len(report.Migrations) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tDURATION\tTABLES\tITEMS\tRCU\tWCU\tCOST")
+	for _, result := range report.Migrations {
+		tables, items := result.TablesCreated, result.ItemsWritten
+		if result.Status == pkgDomain.MigrationStatusReverted {
+			tables, items = result.TablesDeleted, result.ItemsDeleted
+		}
+		total := result.ConsumedCapacity.Total()
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%dms\t%d\t%d\t%g\t%g\t%s\n",
+			result.Version, result.Name, result.Status, result.DurationMs, tables, items,
+			total.ReadUnits, total.WriteUnits, formatCost(result.EstimatedCost))
+		for _, backup := range result.Backups {
+			_, _ = fmt.Fprintf(w, "\t  backup: %s %s\n", backup.TableName, backup.BackupArn)
+		}
+		for _, stream := range result.Streams {
+			_, _ = fmt.Fprintf(w, "\t  stream: %s %s\n", stream.TableName, stream.StreamArn)
+		}
+		if len(result.Error) > 0 {
+			_, _ = fmt.Fprintf(w, "\t  error: %s\n", result.Error)
+		}
+	}
+	_ = w.Flush()
+	printCapacity(report.ConsumedCapacity, report.EstimatedCost)
+}
+
+// printCapacity - prints the capacity consumed by table and the estimated cost of the run.
+func printCapacity(consumed pkgDomain.ConsumedCapacity, cost float64) {
+	if len(consumed) == 0 {
+		return
+	}
+	tables := make([]string, 0, len(consumed))
+	for table := range consumed {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TABLE\tRCU\tWCU")
+	for _, table := range tables {
+		_, _ = fmt.Fprintf(w, "%s\t%g\t%g\n", table, consumed[table].ReadUnits, consumed[table].WriteUnits)
+	}
+	total := consumed.Total()
+	_, _ = fmt.Fprintf(w, "total\t%g\t%g\n", total.ReadUnits, total.WriteUnits)
+	_ = w.Flush()
+	fmt.Printf("Estimated on-demand cost: %s\n", formatCost(cost))
+}
+
+// formatCost - formats a cost in USD, small costs keep significant digits.
+func formatCost(cost float64) string {
+	return "$" + strconv.FormatFloat(cost, 'g', 4, 64)
+}
+
+func printPlan(plan []*pkgDomain.PlannedMigration) {
+	if len(plan) == 0 {
+		fmt.Println("No pending migrations")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tACTION\tTABLES\tITEMS\tBACKUPS")
+	for _, planned := range plan {
+		action := "apply"
+		if planned.Retry {
+			action = "retry"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			planned.Version, planned.Name, action, orDash(strings.Join(planned.Tables, ",")), planned.Items,
+			orDash(strings.Join(planned.Backups, ",")))
+	}
+	_ = w.Flush()
+}
+
+func printStatus(infos []*pkgDomain.MigrationInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tSTARTED AT\tDURATION\tTABLES\tITEMS\tCOST\tATTEMPTS\tRUNNER\tHOSTNAME\tAPP VERSION\tCHECKSUM")
+	for _, info := range infos {
+		record := info.Record
+		if record == nil {
+			_, _ = fmt.Fprintf(w, "%s\t%s\tpending\t-\t-\t-\t-\t-\t-\t-\t-\t-\t%s\n", info.Version, info.Name, shortChecksum(info.FileChecksum))
+			continue
+		}
+		status := string(record.GetStatus())
+		if record.Baselined {
+			status += " (baselined)"
+		}
+		checksum := shortChecksum(record.Checksum)
+		switch {
+		case len(info.FileChecksum) == 0:
+			checksum += " (file missing)"
+		case info.IsChecksumMismatch():
+			checksum += " (file changed)"
+		}
+		startTime := time.Unix(record.Metadata.StartTime, 0)
+		if record.Metadata.StartTimeMs > 0 {
+			startTime = time.Unix(0, record.Metadata.StartTimeMs*int64(time.Millisecond))
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%dms\t%d\t%d\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			info.Version, info.Name, status, startTime.UTC().Format(time.RFC3339), record.Metadata.DurationMs,
+			record.Metadata.TablesCreated, record.Metadata.ItemsWritten, formatCost(record.Metadata.EstimatedCost), record.Attempts,
+			orDash(record.Metadata.Runner), orDash(record.Metadata.Hostname), orDash(record.Metadata.AppVersion), checksum)
+		if len(record.Error) > 0 {
+			_, _ = fmt.Fprintf(w, "\t  error: %s\n", record.Error)
+		}
+		for _, hint := range info.RestoreHints {
+			_, _ = fmt.Fprintf(w, "\t  restore: %s\n", hint)
+		}
+	}
+	_ = w.Flush()
+}
+
+func shortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return orDash(checksum)
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
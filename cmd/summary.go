@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+)
+
+// exitOK - command succeeded.
+const exitOK = 0
+
+// exitCodes - exit codes of failed commands by error kind.
+var exitCodes = []struct {
+	kind        pkgDomain.ErrorKind
+	code        int
+	description string
+}{
+	{pkgDomain.ErrorKindInternal, 1, "unexpected error"},
+	{pkgDomain.ErrorKindUsage, 2, "invalid command, flags or arguments"},
+	{pkgDomain.ErrorKindConfig, 3, "invalid configuration"},
+	{pkgDomain.ErrorKindStorage, 4, "migration files cannot be read or written"},
+	{pkgDomain.ErrorKindParse, 5, "migration file cannot be parsed"},
+	{pkgDomain.ErrorKindValidation, 6, "migration file is invalid"},
+	{pkgDomain.ErrorKindAWS, 7, "AWS request failed"},
+	{pkgDomain.ErrorKindTransactionConflict, 8, "transaction conflicted with another request"},
+	{pkgDomain.ErrorKindLockHeld, 9, "migrations lock is held by another run"},
+	{pkgDomain.ErrorKindMigrationState, 10, "migration record state does not allow the command"},
+	{pkgDomain.ErrorKindCanceled, 11, "command was interrupted or timed out"},
+	{pkgDomain.ErrorKindHook, 12, "hook of the run failed"},
+}
+
+// exitCodeOf - returns the exit code for the error.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	kind := pkgDomain.ErrorKindOf(err)
+	for _, exitCode := range exitCodes {
+		if exitCode.kind == kind {
+			return exitCode.code
+		}
+	}
+	return exitCodes[0].code
+}
+
+// summary - machine-readable result of a command, printed as the last line.
+type summary struct {
+	Command    string        `json:"command"`
+	Status     string        `json:"status"`
+	ExitCode   int           `json:"exit_code"`
+	DurationMs int64         `json:"duration_ms"`
+	Error      *summaryError `json:"error,omitempty"`
+	// Result - command result, printed in the json output mode only.
+	Result interface{} `json:"result,omitempty"`
+}
+
+// summaryError - error of a failed command.
+type summaryError struct {
+	Kind    pkgDomain.ErrorKind `json:"kind"`
+	Message string              `json:"message"`
+}
+
+func newSummary(command string, err error, exitCode int, startTime time.Time) *summary {
+	s := &summary{
+		Command:    command,
+		Status:     "succeeded",
+		ExitCode:   exitCode,
+		DurationMs: time.Since(startTime).Milliseconds(),
+	}
+	if err != nil {
+		s.Status = "failed"
+		s.Error = &summaryError{
+			Kind:    pkgDomain.ErrorKindOf(err),
+			Message: err.Error(),
+		}
+	}
+	return s
+}
+
+// printSummary - prints the summary line to stderr, in the json output mode the result document to stdout.
+func printSummary(s *summary, output string) {
+	w := os.Stdout
+	if output != outputJSON {
+		w = os.Stderr
+		s.Result = nil
+	}
+	content, err := json.Marshal(s)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Cannot print summary:", err)
+		return
+	}
+	_, _ = fmt.Fprintln(w, string(content))
+}
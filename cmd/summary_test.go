@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+)
+
+func TestExitCodeOf(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{
+			name:         "Success: no error",
+			expectedCode: exitOK,
+		},
+		{
+			name:         "Success: error without kind is internal",
+			err:          errors.New("unexpected"),
+			expectedCode: 1,
+		},
+		{
+			name:         "Success: unknown kind is internal",
+			err:          pkgDomain.Errorf(pkgDomain.ErrorKind("unknown"), "unexpected"),
+			expectedCode: 1,
+		},
+		{
+			name:         "Success: wrapped error kind",
+			err:          fmt.Errorf("Run failed: %w", pkgDomain.Errorf(pkgDomain.ErrorKindValidation, "invalid")),
+			expectedCode: 6,
+		},
+		{
+			name:         "Success: wrapped lock held error",
+			err:          fmt.Errorf("Run failed: %w", &pkgDomain.LockHeldError{Lock: pkgDomain.Lock{Owner: "another"}}),
+			expectedCode: 9,
+		},
+		{
+			name:         "Success: wrapped context cancellation",
+			err:          fmt.Errorf("Query failed: %w", context.Canceled),
+			expectedCode: 11,
+		},
+		{
+			name:         "Success: outermost kind of nested errors",
+			err:          pkgDomain.NewError(pkgDomain.ErrorKindValidation, pkgDomain.Errorf(pkgDomain.ErrorKindAWS, "throttled")),
+			expectedCode: 6,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := exitCodeOf(test.err); code != test.expectedCode {
+				t.Errorf("actual exit code: %d does not match expected: %d", code, test.expectedCode)
+			}
+		})
+	}
+	t.Run("Success: every error kind", func(t *testing.T) {
+		for _, exitCode := range exitCodes {
+			if code := exitCodeOf(pkgDomain.Errorf(exitCode.kind, "failed")); code != exitCode.code {
+				t.Errorf("actual exit code of %s: %d does not match expected: %d", exitCode.kind, code, exitCode.code)
+			}
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	pkgDomain "dynamodb.data-migration/internal/domain"
+	pkgTracing "dynamodb.data-migration/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingShutdownTimeout - time to flush spans when the command ends, also after an interrupt.
+const tracingShutdownTimeout = 10 * time.Second
+
+// startTracing - starts the root span of the command, a child of the TRACEPARENT span if it is set.
+// The returned end function ends the span with the command error and flushes the spans.
+func startTracing(ctx context.Context, export pkgDomain.TracingExport, command string, logger pkgDomain.Logger) (context.Context, trace.Tracer, func(error), error) {
+	provider, shutdown, err := pkgTracing.NewTracerProvider(ctx, export, AppVersion)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tracer := provider.Tracer(pkgTracing.InstrumentationName)
+	ctx, span := tracer.Start(pkgTracing.WithParentFromEnv(ctx), command,
+		trace.WithAttributes(attribute.String("migration.command", command)))
+	end := func(err error) {
+		pkgTracing.End(span, err)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdown(shutdownCtx); err != nil {
+			logger.Warn("Cannot export traces", pkgDomain.F("error", err))
+		}
+	}
+	return ctx, tracer, end, nil
+}
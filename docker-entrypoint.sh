@@ -1,6 +1,6 @@
 #!/bin/bash
 set -e
 
-exec /bin/main \
- --migrations=$MIGRATIONS_DIR \
- --x-migrations-table=$MIGRATIONS_TABLE_NAME
+# MIGRATIONS_DIR and MIGRATIONS_TABLE_NAME are read by the binary,
+# the container arguments select the command (default: up).
+exec /bin/main "$@"
@@ -0,0 +1,200 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/logging"
+	"dynamodb.data-migration/internal/metrics"
+	"dynamodb.data-migration/internal/retry"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsSession "github.com/aws/aws-sdk-go/aws/session"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// defaultPollInterval - time between checks of the backup status.
+const defaultPollInterval = 5 * time.Second
+
+// maxBackupNameLength - maximum length of DynamoDB backup names.
+const maxBackupNameLength = 255
+
+type backuper struct {
+	db           *awsDynamodb.DynamoDB
+	logger       domain.Logger
+	metrics      domain.Metrics
+	retryPolicy  domain.RetryPolicy
+	retryer      *retry.Retryer
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+// Option - configures optional backuper dependencies.
+type Option func(b *backuper)
+
+// WithLogger - sets the logger, by default info entries are written to stderr as text.
+func WithLogger(logger domain.Logger) Option {
+	return func(b *backuper) {
+		b.logger = logger
+	}
+}
+
+// WithMetrics - sets the metrics of retried and throttled requests, by default they are discarded.
+func WithMetrics(metrics domain.Metrics) Option {
+	return func(b *backuper) {
+		b.metrics = metrics
+	}
+}
+
+// WithRetryPolicy - sets the retry policy of throttled requests, by default domain.DefaultRetryPolicy.
+// Requests exceeding the limit of concurrent backups are retried as well.
+func WithRetryPolicy(policy domain.RetryPolicy) Option {
+	return func(b *backuper) {
+		b.retryPolicy = policy
+	}
+}
+
+// NewTableBackuper - constructs a backuper creating on-demand backups with the session.
+func NewTableBackuper(session *awsSession.Session, options ...Option) domain.TableBackuper {
+	b := &backuper{
+		// The retries of the AWS SDK are disabled, so the retry policy is the only retry layer.
+		db:           awsDynamodb.New(session, aws.NewConfig().WithMaxRetries(0)),
+		logger:       logging.NewLogger(os.Stderr, domain.LogLevelInfo, logging.FormatText),
+		metrics:      metrics.NewNopMetrics(),
+		retryPolicy:  domain.DefaultRetryPolicy(),
+		pollInterval: defaultPollInterval,
+		now:          time.Now,
+	}
+	for _, option := range options {
+		option(b)
+	}
+	policy := b.retryPolicy
+	policy.RetryableCodes = append([]string{awsDynamodb.ErrCodeLimitExceededException}, policy.RetryableCodes...)
+	b.retryer = retry.NewRetryer(policy, errorCodes, b.logger, b.metrics)
+	return b
+}
+
+// BackupTables - starts backups of all tables, so DynamoDB creates them in parallel, then waits for each of them.
+// Backups created before an error are returned, so they can be recorded.
+func (b *backuper) BackupTables(ctx context.Context, tables []string, version domain.Version) ([]domain.TableBackup, error) {
+	var (
+		backups   = make([]domain.TableBackup, 0, len(tables))
+		available = make([]bool, 0, len(tables))
+	)
+	for _, table := range tables {
+		backup, isAvailable, err := b.createBackup(ctx, table, version)
+		if err != nil {
+			return backups, wrapError(err)
+		}
+		b.logger.Info("Backup started", domain.F("table", table), domain.F("backup_arn", backup.BackupArn))
+		backups = append(backups, backup)
+		available = append(available, isAvailable)
+	}
+	for i, backup := range backups {
+		if !available[i] {
+			if err := b.waitUntilAvailable(ctx, backup); err != nil {
+				return backups, wrapError(err)
+			}
+		}
+		b.logger.Info("Backup available", domain.F("table", backup.TableName), domain.F("backup_arn", backup.BackupArn))
+	}
+	return backups, nil
+}
+
+// createBackup - creates the backup, returns true if it is already available.
+func (b *backuper) createBackup(ctx context.Context, table string, version domain.Version) (domain.TableBackup, bool, error) {
+	backup := domain.TableBackup{
+		TableName:  table,
+		BackupName: backupName(table, version, b.now()),
+	}
+	var output *awsDynamodb.CreateBackupOutput
+	err := b.retryer.Do(ctx, "CreateBackup", func() (err error) {
+		output, err = b.db.CreateBackupWithContext(ctx, &awsDynamodb.CreateBackupInput{
+			TableName:  aws.String(backup.TableName),
+			BackupName: aws.String(backup.BackupName),
+		})
+		return err
+	})
+	if err != nil {
+		return backup, false, err
+	}
+	if output.BackupDetails == nil {
+		return backup, false, fmt.Errorf("Backup of table %s has no details", table)
+	}
+	backup.BackupArn = aws.StringValue(output.BackupDetails.BackupArn)
+	return backup, aws.StringValue(output.BackupDetails.BackupStatus) == awsDynamodb.BackupStatusAvailable, nil
+}
+
+// waitUntilAvailable - polls the backup status until the backup is available.
+func (b *backuper) waitUntilAvailable(ctx context.Context, backup domain.TableBackup) error {
+	for {
+		var output *awsDynamodb.DescribeBackupOutput
+		err := b.retryer.Do(ctx, "DescribeBackup", func() (err error) {
+			output, err = b.db.DescribeBackupWithContext(ctx, &awsDynamodb.DescribeBackupInput{
+				BackupArn: aws.String(backup.BackupArn),
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		var status string
+		if output.BackupDescription != nil && output.BackupDescription.BackupDetails != nil {
+			status = aws.StringValue(output.BackupDescription.BackupDetails.BackupStatus)
+		}
+		switch status {
+		case awsDynamodb.BackupStatusAvailable:
+			return nil
+		case awsDynamodb.BackupStatusDeleted:
+			return fmt.Errorf("Backup %s of table %s was deleted before it became available", backup.BackupArn, backup.TableName)
+		}
+		b.logger.Debug("Waiting for backup", domain.F("table", backup.TableName), domain.F("status", status))
+		timer := time.NewTimer(b.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backupName - returns the backup name with the table, the migration version and the UTC time,
+// the table is shortened to fit the name length limit.
+func backupName(table string, version domain.Version, now time.Time) string {
+	suffix := fmt.Sprintf("-%s-%s", version, now.UTC().Format("20060102T150405Z"))
+	if len(table)+len(suffix) > maxBackupNameLength {
+		table = table[:maxBackupNameLength-len(suffix)]
+	}
+	return table + suffix
+}
+
+// errorCodes - returns the AWS error code.
+func errorCodes(err error) []string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return []string{aerr.Code()}
+	}
+	return nil
+}
+
+// wrapError - classifies AWS and context errors, other errors are returned as is.
+func wrapError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return domain.NewError(domain.ErrorKindCanceled, err)
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		if aerr.Code() == request.CanceledErrorCode {
+			return domain.NewError(domain.ErrorKindCanceled, err)
+		}
+		return domain.NewError(domain.ErrorKindAWS, err)
+	}
+	return err
+}
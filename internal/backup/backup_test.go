@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// standIn - local stand-in of the DynamoDB backup API, backups become available after a number of status checks.
+type standIn struct {
+	mu sync.Mutex
+	// tables - existing tables.
+	tables map[string]bool
+	// pendingChecks - status checks until a backup is available.
+	pendingChecks int
+	// limitExceeded - number of CreateBackup requests rejected by the concurrent backups limit.
+	limitExceeded int
+	// throttled - number of CreateBackup requests rejected by throttling, which the AWS SDK would retry itself.
+	throttled int
+	// requests - number of CreateBackup requests.
+	requests int
+	// deleted - backups are deleted while they are created.
+	deleted bool
+	backups map[string]int
+	created []string
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var input map[string]string
+	_ = json.NewDecoder(r.Body).Decode(&input)
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	switch operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."); operation {
+	case "CreateBackup":
+		s.requests++
+		if s.throttled > 0 {
+			s.throttled--
+			writeError(w, "ThrottlingException", "Rate exceeded")
+			return
+		}
+		if s.limitExceeded > 0 {
+			s.limitExceeded--
+			writeError(w, "LimitExceededException", "Too many concurrent backups")
+			return
+		}
+		if !s.tables[input["TableName"]] {
+			writeError(w, "TableNotFoundException", "Table not found: "+input["TableName"])
+			return
+		}
+		arn := fmt.Sprintf("arn:aws:dynamodb:eu-west-1:123456789012:table/%s/backup/%d", input["TableName"], len(s.created))
+		s.backups[arn] = s.pendingChecks
+		s.created = append(s.created, input["BackupName"])
+		writeBackup(w, "BackupDetails", arn, s.status(arn))
+	case "DescribeBackup":
+		arn := input["BackupArn"]
+		if s.backups[arn] > 0 {
+			s.backups[arn]--
+		}
+		writeBackup(w, "BackupDescription", arn, s.status(arn))
+	default:
+		writeError(w, "UnknownOperationException", operation)
+	}
+}
+
+func (s *standIn) status(arn string) string {
+	switch {
+	case s.backups[arn] > 0:
+		return "CREATING"
+	case s.deleted:
+		return "DELETED"
+	default:
+		return "AVAILABLE"
+	}
+}
+
+func writeBackup(w http.ResponseWriter, field, arn, status string) {
+	details := map[string]interface{}{"BackupArn": arn, "BackupStatus": status}
+	if field == "BackupDescription" {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{field: map[string]interface{}{"BackupDetails": details}})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{field: details})
+}
+
+func writeError(w http.ResponseWriter, code, message string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.dynamodb.v20120810#" + code, "message": message})
+}
+
+func TestBackupTables(t *testing.T) {
+	version := domain.Version{Major: 1, Minor: 2}
+	now := time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	// Test.
+	tests := []struct {
+		name            string
+		standIn         *standIn
+		policy          domain.RetryPolicy
+		tables          []string
+		expectedBackups []domain.TableBackup
+		expectedKind    domain.ErrorKind
+		// expectedRequests - number of CreateBackup requests, 0 to skip the check.
+		expectedRequests int
+	}{
+		{
+			name:    "Success: backups available after checks",
+			standIn: &standIn{tables: map[string]bool{"users": true, "roles": true}, pendingChecks: 2},
+			tables:  []string{"users", "roles"},
+			expectedBackups: []domain.TableBackup{
+				{TableName: "users", BackupName: "users-1.2.0-20210901T100000Z", BackupArn: "arn:aws:dynamodb:eu-west-1:123456789012:table/users/backup/0"},
+				{TableName: "roles", BackupName: "roles-1.2.0-20210901T100000Z", BackupArn: "arn:aws:dynamodb:eu-west-1:123456789012:table/roles/backup/1"},
+			},
+		},
+		{
+			name:    "Success: concurrent backups limit retried",
+			standIn: &standIn{tables: map[string]bool{"users": true}, limitExceeded: 1},
+			tables:  []string{"users"},
+			expectedBackups: []domain.TableBackup{
+				{TableName: "users", BackupName: "users-1.2.0-20210901T100000Z", BackupArn: "arn:aws:dynamodb:eu-west-1:123456789012:table/users/backup/0"},
+			},
+			expectedRequests: 2,
+		},
+		{
+			name:             "Fail: retries disabled by the policy",
+			standIn:          &standIn{tables: map[string]bool{"users": true}, throttled: 1},
+			policy:           domain.RetryPolicy{MaxAttempts: 1},
+			tables:           []string{"users"},
+			expectedBackups:  []domain.TableBackup{},
+			expectedKind:     domain.ErrorKindAWS,
+			expectedRequests: 1,
+		},
+		{
+			name:    "Fail: table not found",
+			standIn: &standIn{tables: map[string]bool{"users": true}},
+			tables:  []string{"users", "roles"},
+			expectedBackups: []domain.TableBackup{
+				{TableName: "users", BackupName: "users-1.2.0-20210901T100000Z", BackupArn: "arn:aws:dynamodb:eu-west-1:123456789012:table/users/backup/0"},
+			},
+			expectedKind: domain.ErrorKindAWS,
+		},
+		{
+			name:    "Fail: backup deleted",
+			standIn: &standIn{tables: map[string]bool{"users": true}, pendingChecks: 1, deleted: true},
+			tables:  []string{"users"},
+			expectedBackups: []domain.TableBackup{
+				{TableName: "users", BackupName: "users-1.2.0-20210901T100000Z", BackupArn: "arn:aws:dynamodb:eu-west-1:123456789012:table/users/backup/0"},
+			},
+			expectedKind: domain.ErrorKindInternal,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.standIn.backups = make(map[string]int)
+			server := httptest.NewServer(test.standIn)
+			defer server.Close()
+			sess := session.Must(session.NewSession(&aws.Config{
+				Region:      aws.String("eu-west-1"),
+				Endpoint:    aws.String(server.URL),
+				Credentials: credentials.NewStaticCredentials("key", "secret", ""),
+			}))
+			policy := test.policy
+			if policy.MaxAttempts == 0 {
+				policy = domain.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+			}
+			b := NewTableBackuper(sess, WithRetryPolicy(policy)).(*backuper)
+			b.pollInterval = time.Millisecond
+			b.now = func() time.Time { return now }
+
+			backups, err := b.BackupTables(context.Background(), test.tables, version)
+			if kind := domain.ErrorKindOf(err); kind != test.expectedKind {
+				t.Errorf("actual error kind: %q does not match expected: %q, error: %v", kind, test.expectedKind, err)
+			}
+			if !reflect.DeepEqual(backups, test.expectedBackups) {
+				t.Errorf("actual backups: %+v do not match expected: %+v", backups, test.expectedBackups)
+			}
+			if test.expectedRequests > 0 && test.standIn.requests != test.expectedRequests {
+				t.Errorf("actual requests: %d do not match expected: %d", test.standIn.requests, test.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestBackupName(t *testing.T) {
+	now := time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)
+	name := backupName(strings.Repeat("t", 255), domain.Version{Major: 1}, now)
+	if len(name) != maxBackupNameLength || !strings.HasSuffix(name, "-1.0.0-20210901T100000Z") {
+		t.Errorf("actual name: %s must be shortened to %d characters", name, maxBackupNameLength)
+	}
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath - config file loaded when no path is given, it is optional.
+const DefaultPath = "migrations.yaml"
+
+// File - config file with named profiles.
+type File struct {
+	// DefaultProfile - profile used when none is selected.
+	DefaultProfile string              `yaml:"default_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles"`
+}
+
+// Profile - settings of an environment, empty values keep the defaults.
+type Profile struct {
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+	// BackupEndpoint - AWS endpoint of backup requests, empty for the endpoint.
+	BackupEndpoint  string   `yaml:"backup_endpoint"`
+	TablePrefix     string   `yaml:"table_prefix"`
+	MigrationsDir   string   `yaml:"migrations_dir"`
+	MigrationsTable string   `yaml:"migrations_table"`
+	Runner          string   `yaml:"runner"`
+	Lock            Lock     `yaml:"lock"`
+	Policies        Policies `yaml:"policies"`
+	// Timeout - maximum duration of a command.
+	Timeout time.Duration `yaml:"timeout"`
+	Retry   Retry         `yaml:"retry"`
+	// RateLimits - capacity units per second consumed by migrations.
+	RateLimits RateLimits `yaml:"rate_limits"`
+	// UnitPrices - on-demand prices used to estimate the cost of migrations.
+	UnitPrices UnitPrices `yaml:"unit_prices"`
+	Metrics    Metrics    `yaml:"metrics"`
+	Tracing    Tracing    `yaml:"tracing"`
+	// Webhooks - endpoints notified when up or down starts, succeeds or fails.
+	Webhooks []Webhook `yaml:"webhooks"`
+	// Hooks - shell commands run before and after migrations of up.
+	Hooks []Hook `yaml:"hooks"`
+}
+
+// Lock - lock settings of a profile.
+type Lock struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// Retry - retry policy of throttled and conflicting AWS requests.
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+	Jitter      *float64      `yaml:"jitter"`
+	// Codes - retryable AWS error codes, replace the default codes.
+	Codes []string `yaml:"codes"`
+}
+
+// CapacityLimit - capacity units per second.
+type CapacityLimit struct {
+	ReadUnits  float64 `yaml:"read_units"`
+	WriteUnits float64 `yaml:"write_units"`
+}
+
+// RateLimits - capacity limits of all tables together and of single tables.
+type RateLimits struct {
+	CapacityLimit `yaml:",inline"`
+	// Tables - limits by the table name including the table prefix.
+	Tables map[string]CapacityLimit `yaml:"tables"`
+}
+
+// UnitPrices - prices in USD per million request units, missing prices keep the defaults.
+type UnitPrices struct {
+	ReadPerMillion  *float64 `yaml:"read_per_million"`
+	WritePerMillion *float64 `yaml:"write_per_million"`
+}
+
+// Metrics - export of run metrics.
+type Metrics struct {
+	Addr    string `yaml:"addr"`
+	PushURL string `yaml:"push_url"`
+	Job     string `yaml:"job"`
+}
+
+// Tracing - OTLP export of run traces.
+type Tracing struct {
+	Endpoint    string            `yaml:"endpoint"`
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"service_name"`
+}
+
+// Webhook - notified endpoint, the format defaults to slack.
+type Webhook struct {
+	URL      string   `yaml:"url"`
+	Format   string   `yaml:"format"`
+	Events   []string `yaml:"events"`
+	Template string   `yaml:"template"`
+}
+
+// Hook - shell command run at a stage of up runs, for all migrations if versions are empty.
+type Hook struct {
+	Stage    string        `yaml:"stage"`
+	Command  string        `yaml:"command"`
+	Versions []string      `yaml:"versions"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Policies - safety policies of a profile.
+type Policies struct {
+	DenyRollback    bool `yaml:"deny_rollback"`
+	DenyDropTables  bool `yaml:"deny_drop_tables"`
+	DenyAllowFailed bool `yaml:"deny_allow_failed"`
+}
+
+// Load - reads the config file, unknown fields are rejected to catch typos.
+// Returns nil if the optional file does not exist.
+func Load(path string, optional bool) (*File, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, domain.NewError(domain.ErrorKindConfig, err)
+	}
+	file := &File{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, domain.Errorf(domain.ErrorKindConfig, "Invalid config file %s: %v", path, err)
+	}
+	return file, nil
+}
+
+// Apply - copies settings of the profile to the migration context, the default profile is used if name is empty.
+func (f *File) Apply(name string, migrationContext *domain.MigrationContext) error {
+	if len(name) == 0 {
+		name = f.DefaultProfile
+	}
+	if len(name) == 0 {
+		return nil
+	}
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return domain.Errorf(domain.ErrorKindConfig, "Profile %q is not defined, profiles: %s", name, strings.Join(f.profileNames(), ", "))
+	}
+	if profile == nil {
+		profile = &Profile{}
+	}
+	migrationContext.Profile = name
+	setString(&migrationContext.Region, profile.Region)
+	setString(&migrationContext.Endpoint, profile.Endpoint)
+	setString(&migrationContext.BackupEndpoint, profile.BackupEndpoint)
+	setString(&migrationContext.TablePrefix, profile.TablePrefix)
+	setString(&migrationContext.MigrationsDir, profile.MigrationsDir)
+	setString(&migrationContext.MigrationsTable, profile.MigrationsTable)
+	setString(&migrationContext.Runner, profile.Runner)
+	setString(&migrationContext.Metrics.Addr, profile.Metrics.Addr)
+	setString(&migrationContext.Metrics.PushURL, profile.Metrics.PushURL)
+	setString(&migrationContext.Metrics.Job, profile.Metrics.Job)
+	setString(&migrationContext.Tracing.Endpoint, profile.Tracing.Endpoint)
+	setString(&migrationContext.Tracing.ServiceName, profile.Tracing.ServiceName)
+	if len(profile.Tracing.Headers) > 0 {
+		migrationContext.Tracing.Headers = profile.Tracing.Headers
+	}
+	for _, webhook := range profile.Webhooks {
+		migrationContext.Webhooks = append(migrationContext.Webhooks, webhook.toDomain())
+	}
+	for _, hook := range profile.Hooks {
+		command, err := hook.toDomain()
+		if err != nil {
+			return err
+		}
+		migrationContext.Hooks = append(migrationContext.Hooks, command)
+	}
+	if profile.Lock.TTL != 0 {
+		migrationContext.LockTTL = profile.Lock.TTL
+	}
+	if profile.Timeout != 0 {
+		migrationContext.Timeout = profile.Timeout
+	}
+	profile.Retry.apply(&migrationContext.Retry)
+	profile.RateLimits.apply(&migrationContext.RateLimits)
+	profile.UnitPrices.apply(&migrationContext.UnitPrices)
+	migrationContext.Policy = domain.SafetyPolicy{
+		DenyRollback:    profile.Policies.DenyRollback,
+		DenyDropTables:  profile.Policies.DenyDropTables,
+		DenyAllowFailed: profile.Policies.DenyAllowFailed,
+	}
+	return nil
+}
+
+func (r Retry) apply(policy *domain.RetryPolicy) {
+	if r.MaxAttempts != 0 {
+		policy.MaxAttempts = r.MaxAttempts
+	}
+	if r.BaseDelay != 0 {
+		policy.BaseDelay = r.BaseDelay
+	}
+	if r.MaxDelay != 0 {
+		policy.MaxDelay = r.MaxDelay
+	}
+	if r.Jitter != nil {
+		policy.Jitter = *r.Jitter
+	}
+	if len(r.Codes) > 0 {
+		policy.RetryableCodes = r.Codes
+	}
+}
+
+func (l RateLimits) apply(limits *domain.RateLimits) {
+	if l.ReadUnits != 0 {
+		limits.ReadUnits = l.ReadUnits
+	}
+	if l.WriteUnits != 0 {
+		limits.WriteUnits = l.WriteUnits
+	}
+	if len(l.Tables) > 0 {
+		limits.Tables = make(map[string]domain.CapacityLimit, len(l.Tables))
+		for table, limit := range l.Tables {
+			limits.Tables[table] = domain.CapacityLimit(limit)
+		}
+	}
+}
+
+func (p UnitPrices) apply(prices *domain.UnitPrices) {
+	if p.ReadPerMillion != nil {
+		prices.ReadPerMillion = *p.ReadPerMillion
+	}
+	if p.WritePerMillion != nil {
+		prices.WritePerMillion = *p.WritePerMillion
+	}
+}
+
+func (w Webhook) toDomain() domain.Webhook {
+	webhook := domain.Webhook{
+		URL:      w.URL,
+		Format:   domain.WebhookFormat(w.Format),
+		Template: w.Template,
+	}
+	if len(webhook.Format) == 0 {
+		webhook.Format = domain.WebhookFormatSlack
+	}
+	for _, event := range w.Events {
+		webhook.Events = append(webhook.Events, domain.RunEvent(event))
+	}
+	return webhook
+}
+
+func (h Hook) toDomain() (domain.HookCommand, error) {
+	command := domain.HookCommand{
+		Stage:   domain.HookStage(h.Stage),
+		Command: h.Command,
+		Timeout: h.Timeout,
+	}
+	for _, value := range h.Versions {
+		version, err := domain.ParseVersion(value)
+		if err != nil {
+			return command, domain.Errorf(domain.ErrorKindConfig, "Invalid version of the %s hook: %v", h.Stage, err)
+		}
+		command.Versions = append(command.Versions, version)
+	}
+	return command, nil
+}
+
+func (f *File) profileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func setString(target *string, value string) {
+	if len(value) > 0 {
+		*target = value
+	}
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+func TestLoadAndApply(t *testing.T) {
+	validConfig := `
+default_profile: dev
+profiles:
+  dev:
+    endpoint: http://localhost:4566
+    backup_endpoint: http://localhost:4567
+    table_prefix: dev_
+  prod:
+    region: eu-west-1
+    migrations_table: prod_migrations
+    lock:
+      ttl: 15m
+    rate_limits:
+      write_units: 100
+      tables:
+        prod_users:
+          write_units: 10
+    unit_prices:
+      write_per_million: 1.4
+    metrics:
+      push_url: http://pushgateway:9091
+    tracing:
+      endpoint: http://otel-collector:4318
+      headers:
+        x-api-key: secret
+    webhooks:
+      - url: https://hooks.slack.com/services/T000/B000/XXXX
+        events: [started, failed]
+      - url: https://ops.example.com/migrations
+        format: json
+    hooks:
+      - stage: before_each
+        command: ./scripts/backup.sh
+        versions: ["1.2.0"]
+        timeout: 30m
+    retry:
+      max_attempts: 8
+      base_delay: 200ms
+      jitter: 0.2
+    policies:
+      deny_rollback: true
+      deny_allow_failed: true
+`
+	defaults := domain.MigrationContext{
+		MigrationsDir:   "migrations",
+		MigrationsTable: "x_migrations",
+		LockTTL:         time.Hour,
+	}
+
+	// Test.
+	tests := []struct {
+		name        string
+		content     string
+		profile     string
+		expected    domain.MigrationContext
+		expectError bool
+	}{
+		{
+			name:    "Success: default profile",
+			content: validConfig,
+			expected: domain.MigrationContext{
+				MigrationsDir:   "migrations",
+				MigrationsTable: "x_migrations",
+				LockTTL:         time.Hour,
+				Profile:         "dev",
+				Endpoint:        "http://localhost:4566",
+				BackupEndpoint:  "http://localhost:4567",
+				TablePrefix:     "dev_",
+			},
+		},
+		{
+			name:    "Success: selected profile",
+			content: validConfig,
+			profile: "prod",
+			expected: domain.MigrationContext{
+				MigrationsDir:   "migrations",
+				MigrationsTable: "prod_migrations",
+				LockTTL:         15 * time.Minute,
+				Profile:         "prod",
+				Region:          "eu-west-1",
+				RateLimits: domain.RateLimits{
+					CapacityLimit: domain.CapacityLimit{WriteUnits: 100},
+					Tables:        map[string]domain.CapacityLimit{"prod_users": {WriteUnits: 10}},
+				},
+				UnitPrices: domain.UnitPrices{WritePerMillion: 1.4},
+				Metrics:    domain.MetricsExport{PushURL: "http://pushgateway:9091"},
+				Tracing: domain.TracingExport{
+					Endpoint: "http://otel-collector:4318",
+					Headers:  map[string]string{"x-api-key": "secret"},
+				},
+				Webhooks: []domain.Webhook{
+					{
+						URL:    "https://hooks.slack.com/services/T000/B000/XXXX",
+						Format: domain.WebhookFormatSlack,
+						Events: []domain.RunEvent{domain.RunEventStarted, domain.RunEventFailed},
+					},
+					{URL: "https://ops.example.com/migrations", Format: domain.WebhookFormatJSON},
+				},
+				Hooks: []domain.HookCommand{{
+					Stage:    domain.HookStageBeforeEach,
+					Command:  "./scripts/backup.sh",
+					Versions: []domain.Version{mustParseVersion(t, "1.2.0")},
+					Timeout:  30 * time.Minute,
+				}},
+				Retry: domain.RetryPolicy{
+					MaxAttempts: 8,
+					BaseDelay:   200 * time.Millisecond,
+					Jitter:      0.2,
+				},
+				Policy: domain.SafetyPolicy{
+					DenyRollback:    true,
+					DenyAllowFailed: true,
+				},
+			},
+		},
+		{
+			name:     "Success: no profile selected",
+			content:  "profiles:\n  dev:\n    region: eu-west-1\n",
+			expected: defaults,
+		},
+		{
+			name:        "Fail: unknown profile",
+			content:     validConfig,
+			profile:     "staging",
+			expectError: true,
+		},
+		{
+			name:        "Fail: invalid hook version",
+			content:     "default_profile: dev\nprofiles:\n  dev:\n    hooks:\n      - stage: before_all\n        command: backup.sh\n        versions: [v1]\n",
+			expectError: true,
+		},
+		{
+			name:        "Fail: unknown field",
+			content:     "profiles:\n  dev:\n    regoin: eu-west-1\n",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "migrations.yaml")
+			if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			migrationContext := defaults
+			file, err := Load(path, false)
+			if err == nil {
+				err = file.Apply(test.profile, &migrationContext)
+			}
+			if test.expectError {
+				if err == nil {
+					t.Error("expected error but got nothing")
+				}
+				if kind := domain.ErrorKindOf(err); kind != domain.ErrorKindConfig {
+					t.Errorf("expected %s error, got %s", domain.ErrorKindConfig, kind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(migrationContext, test.expected) {
+				t.Errorf("Apply() = %+v, expected %+v", migrationContext, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadOptional(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	if file, err := Load(path, true); file != nil || err != nil {
+		t.Errorf("Load() = %v, %v, expected no file and no error", file, err)
+	}
+	if _, err := Load(path, false); err == nil {
+		t.Error("expected error but got nothing")
+	}
+}
+
+func mustParseVersion(t *testing.T, s string) domain.Version {
+	version, err := domain.ParseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// TableBackup - on-demand backup of a table taken before a migration.
+type TableBackup struct {
+	TableName  string `json:"table_name"`
+	BackupName string `json:"backup_name"`
+	BackupArn  string `json:"backup_arn"`
+}
+
+// RestoreHint - returns the AWS CLI command restoring the backup, a backup is restored to a new table.
+func (b TableBackup) RestoreHint() string {
+	return fmt.Sprintf("aws dynamodb restore-table-from-backup --target-table-name %s-restored --backup-arn %s", b.TableName, b.BackupArn)
+}
+
+// TableBackuper - takes on-demand backups of tables.
+type TableBackuper interface {
+
+	// BackupTables - creates backups of the tables before the migration and waits until they are available.
+	BackupTables(ctx context.Context, tables []string, version Version) ([]TableBackup, error)
+}
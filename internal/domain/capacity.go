@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// CapacityLimit - capacity units per second migrations may consume, 0 means no limit.
+type CapacityLimit struct {
+	ReadUnits  float64 `json:"read_units"`
+	WriteUnits float64 `json:"write_units"`
+}
+
+// RateLimits - capacity limits of all tables together and of single tables.
+type RateLimits struct {
+	CapacityLimit
+	// Tables - limits by the table name including the table prefix.
+	Tables map[string]CapacityLimit `json:"tables,omitempty"`
+}
+
+// Validate - checks if the limits are not negative.
+func (l RateLimits) Validate() error {
+	limits := []CapacityLimit{l.CapacityLimit}
+	for _, limit := range l.Tables {
+		limits = append(limits, limit)
+	}
+	for _, limit := range limits {
+		if limit.ReadUnits < 0 || limit.WriteUnits < 0 {
+			return NewError(ErrorKindConfig, errors.New("Capacity limits cannot be negative"))
+		}
+	}
+	return nil
+}
+
+// TableReadUnits - returns read limits of tables.
+func (l RateLimits) TableReadUnits() map[string]float64 {
+	units := make(map[string]float64, len(l.Tables))
+	for table, limit := range l.Tables {
+		units[table] = limit.ReadUnits
+	}
+	return units
+}
+
+// TableWriteUnits - returns write limits of tables.
+func (l RateLimits) TableWriteUnits() map[string]float64 {
+	units := make(map[string]float64, len(l.Tables))
+	for table, limit := range l.Tables {
+		units[table] = limit.WriteUnits
+	}
+	return units
+}
+
+// TableCapacity - capacity units consumed in a table.
+type TableCapacity struct {
+	ReadUnits  float64 `json:"read_units"`
+	WriteUnits float64 `json:"write_units"`
+}
+
+// ConsumedCapacity - capacity units consumed by table.
+type ConsumedCapacity map[string]TableCapacity
+
+// Add - adds capacity consumed in the tables.
+func (c ConsumedCapacity) Add(other ConsumedCapacity) {
+	for table, capacity := range other {
+		total := c[table]
+		total.ReadUnits += capacity.ReadUnits
+		total.WriteUnits += capacity.WriteUnits
+		c[table] = total
+	}
+}
+
+// Total - returns capacity consumed in all tables.
+func (c ConsumedCapacity) Total() TableCapacity {
+	var total TableCapacity
+	for _, capacity := range c {
+		total.ReadUnits += capacity.ReadUnits
+		total.WriteUnits += capacity.WriteUnits
+	}
+	return total
+}
+
+// UnitPrices - on-demand prices in USD per million request units.
+type UnitPrices struct {
+	ReadPerMillion  float64 `json:"read_per_million"`
+	WritePerMillion float64 `json:"write_per_million"`
+}
+
+// DefaultUnitPrices - returns on-demand prices of the us-east-1 region.
+func DefaultUnitPrices() UnitPrices {
+	return UnitPrices{
+		ReadPerMillion:  0.25,
+		WritePerMillion: 1.25,
+	}
+}
+
+// Validate - checks that prices are not negative.
+func (p UnitPrices) Validate() error {
+	if p.ReadPerMillion < 0 || p.WritePerMillion < 0 {
+		return NewError(ErrorKindConfig, errors.New("Unit prices cannot be negative"))
+	}
+	return nil
+}
+
+// Cost - returns the estimated on-demand cost of the consumed capacity in USD.
+func (p UnitPrices) Cost(capacity TableCapacity) float64 {
+	return (capacity.ReadUnits*p.ReadPerMillion + capacity.WriteUnits*p.WritePerMillion) / 1e6
+}
+
+// CapacityMeter - collects capacity consumed by requests, e.g. of a single migration.
+// Methods of a nil meter do nothing.
+type CapacityMeter struct {
+	mu       sync.Mutex
+	consumed ConsumedCapacity
+}
+
+// NewCapacityMeter - constructs an empty meter.
+func NewCapacityMeter() *CapacityMeter {
+	return &CapacityMeter{
+		consumed: make(ConsumedCapacity),
+	}
+}
+
+// Add - adds read and write units consumed in the table.
+func (m *CapacityMeter) Add(table string, capacity TableCapacity) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.consumed.Add(ConsumedCapacity{table: capacity})
+}
+
+// Consumed - returns a copy of the collected capacity.
+func (m *CapacityMeter) Consumed() ConsumedCapacity {
+	consumed := make(ConsumedCapacity)
+	if m == nil {
+		return consumed
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	consumed.Add(m.consumed)
+	return consumed
+}
+
+type capacityMeterKey struct{}
+
+// WithCapacityMeter - returns a context collecting the consumed capacity of requests into the meter.
+func WithCapacityMeter(ctx context.Context, meter *CapacityMeter) context.Context {
+	return context.WithValue(ctx, capacityMeterKey{}, meter)
+}
+
+// CapacityMeterFrom - returns the meter of the context, nil if there is none.
+func CapacityMeterFrom(ctx context.Context) *CapacityMeter {
+	meter, _ := ctx.Value(capacityMeterKey{}).(*CapacityMeter)
+	return meter
+}
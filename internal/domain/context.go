@@ -1,11 +1,53 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 // MigrationContext - describes migration context.
 type MigrationContext struct {
 	MigrationsDir   string
 	MigrationsTable string
+	TargetVersion   string
+	Steps           int
+	AllowFailed     bool
+	Runner          string
+	LockTTL         time.Duration
+	// Profile - name of the config file profile, empty if no profile is used.
+	Profile string
+	// Region - AWS region, empty for the SDK default.
+	Region string
+	// Endpoint - AWS endpoint, e.g. of a local DynamoDB, empty for the SDK default.
+	Endpoint string
+	// BackupEndpoint - AWS endpoint of backup requests, e.g. of a local stand-in, empty for Endpoint.
+	BackupEndpoint string
+	// TablePrefix - prefix added to the table names of migration files.
+	TablePrefix string
+	Policy      SafetyPolicy
+	// Timeout - maximum duration of a command, 0 means no limit.
+	Timeout time.Duration
+	Retry   RetryPolicy
+	// RateLimits - capacity units per second consumed by migrations.
+	RateLimits RateLimits
+	// UnitPrices - prices used to estimate the cost of migrations.
+	UnitPrices UnitPrices
+	Metrics    MetricsExport
+	Tracing    TracingExport
+	// Webhooks - endpoints notified when up or down starts, succeeds or fails.
+	Webhooks []Webhook
+	// Hooks - commands run before and after migrations of up.
+	Hooks []HookCommand
+}
+
+// SafetyPolicy - restricts destructive commands, e.g. in the production profile.
+type SafetyPolicy struct {
+	// DenyRollback - down is refused.
+	DenyRollback bool
+	// DenyDropTables - down --drop-tables is refused.
+	DenyDropTables bool
+	// DenyAllowFailed - --allow-failed is refused.
+	DenyAllowFailed bool
 }
 
 // NewMigrationContext - constructs a new migration context.
@@ -16,10 +58,92 @@ func NewMigrationContext() *MigrationContext {
 // Validate - checks if the migration context properties are valid.
 func (m MigrationContext) Validate() error {
 	if len(m.MigrationsDir) == 0 {
-		return errors.New("Migrations directory required")
+		return NewError(ErrorKindConfig, errors.New("Migrations directory required"))
 	}
 	if len(m.MigrationsTable) == 0 {
-		return errors.New("Migrations table name required")
+		return NewError(ErrorKindConfig, errors.New("Migrations table name required"))
+	}
+	if len(m.TargetVersion) > 0 {
+		if _, err := ParseVersion(m.TargetVersion); err != nil {
+			return NewError(ErrorKindConfig, err)
+		}
+	}
+	if m.Steps < 0 {
+		return NewError(ErrorKindConfig, errors.New("Number of steps cannot be negative"))
+	}
+	if m.Timeout < 0 {
+		return NewError(ErrorKindConfig, errors.New("Timeout cannot be negative"))
+	}
+	if m.LockTTL <= 0 {
+		return NewError(ErrorKindConfig, errors.New("Lock TTL must be positive"))
+	}
+	if err := m.RateLimits.Validate(); err != nil {
+		return err
 	}
-	return nil
+	if err := m.UnitPrices.Validate(); err != nil {
+		return err
+	}
+	if err := m.Metrics.Validate(); err != nil {
+		return err
+	}
+	if err := m.Tracing.Validate(); err != nil {
+		return err
+	}
+	for _, webhook := range m.Webhooks {
+		if err := webhook.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, hook := range m.Hooks {
+		if err := hook.Validate(); err != nil {
+			return err
+		}
+	}
+	return m.Retry.Validate()
+}
+
+// RollbackOptions - returns options of a rollback run, one migration is reverted by default.
+func (m MigrationContext) RollbackOptions(dropTables bool) (RollbackOptions, error) {
+	migrateOptions, err := m.MigrateOptions()
+	if err != nil {
+		return RollbackOptions{}, err
+	}
+	switch {
+	case m.Policy.DenyRollback:
+		return RollbackOptions{}, m.policyError("Rollback")
+	case dropTables && m.Policy.DenyDropTables:
+		return RollbackOptions{}, m.policyError("Dropping tables")
+	}
+	options := RollbackOptions{
+		Target:     migrateOptions.Target,
+		Steps:      migrateOptions.Steps,
+		DropTables: dropTables,
+	}
+	if options.Target == nil && options.Steps == 0 {
+		options.Steps = 1
+	}
+	return options, nil
+}
+
+// MigrateOptions - returns options of a migration run.
+func (m MigrationContext) MigrateOptions() (MigrateOptions, error) {
+	if m.AllowFailed && m.Policy.DenyAllowFailed {
+		return MigrateOptions{}, m.policyError("Retrying failed migrations")
+	}
+	options := MigrateOptions{
+		Steps:       m.Steps,
+		AllowFailed: m.AllowFailed,
+	}
+	if len(m.TargetVersion) > 0 {
+		target, err := ParseVersion(m.TargetVersion)
+		if err != nil {
+			return options, NewError(ErrorKindUsage, err)
+		}
+		options.Target = &target
+	}
+	return options, nil
+}
+
+func (m MigrationContext) policyError(action string) error {
+	return Errorf(ErrorKindConfig, "%s is denied by the policy of the profile %q", action, m.Profile)
 }
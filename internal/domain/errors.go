@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrorKind - kind of an error, used to report why a command failed.
+type ErrorKind string
+
+// Error kinds.
+const (
+	ErrorKindInternal            ErrorKind = "internal"
+	ErrorKindUsage               ErrorKind = "usage"
+	ErrorKindConfig              ErrorKind = "config"
+	ErrorKindStorage             ErrorKind = "storage"
+	ErrorKindParse               ErrorKind = "parse"
+	ErrorKindValidation          ErrorKind = "validation"
+	ErrorKindAWS                 ErrorKind = "aws"
+	ErrorKindTransactionConflict ErrorKind = "transaction_conflict"
+	ErrorKindLockHeld            ErrorKind = "lock_held"
+	ErrorKindMigrationState      ErrorKind = "migration_state"
+	ErrorKindCanceled            ErrorKind = "canceled"
+	ErrorKindHook                ErrorKind = "hook"
+)
+
+// Error - error of a known kind.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+// NewError - wraps the error with the kind, nil errors stay nil.
+func NewError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+// Errorf - formats an error of the kind.
+func Errorf(kind ErrorKind, format string, args ...interface{}) error {
+	return NewError(kind, fmt.Errorf(format, args...))
+}
+
+// Error - returns an error message.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap - returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKindOf - returns the kind of the outermost known error in the chain.
+func ErrorKindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var lockErr *LockHeldError
+	if errors.As(err, &lockErr) {
+		return ErrorKindLockHeld
+	}
+	var kindErr *Error
+	if errors.As(err, &kindErr) {
+		return kindErr.Kind
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindCanceled
+	}
+	return ErrorKindInternal
+}
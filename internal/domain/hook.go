@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HookStage - point of an up run where hooks run.
+type HookStage string
+
+// Hook stages.
+const (
+	HookStageBeforeAll  HookStage = "before_all"
+	HookStageBeforeEach HookStage = "before_each"
+	HookStageAfterEach  HookStage = "after_each"
+	HookStageAfterAll   HookStage = "after_all"
+	HookStageOnError    HookStage = "on_error"
+)
+
+// HookStages - stages in the order they run.
+var HookStages = []HookStage{HookStageBeforeAll, HookStageBeforeEach, HookStageAfterEach, HookStageAfterAll, HookStageOnError}
+
+// Validate - checks if the stage is known.
+func (s HookStage) Validate() error {
+	for _, stage := range HookStages {
+		if s == stage {
+			return nil
+		}
+	}
+	return Errorf(ErrorKindConfig, "Invalid hook stage %q, expected before_all, before_each, after_each, after_all or on_error", s)
+}
+
+// HookEvent - metadata of the run passed to hooks.
+type HookEvent struct {
+	Stage    HookStage `json:"stage"`
+	Runner   string    `json:"runner"`
+	Hostname string    `json:"hostname"`
+	// Pending - migrations the run applies, set for before_all and on_error hooks.
+	Pending []*MigrationSummary `json:"pending,omitempty"`
+	// Migration - migration of before_each and after_each hooks, the failed migration of on_error hooks.
+	Migration *MigrationSummary `json:"migration,omitempty"`
+	// Result - result of the migration of after_each hooks, the failed result of on_error hooks.
+	Result *MigrationResult `json:"result,omitempty"`
+	// Migrations - results of the migrations run so far, set for after_all and on_error hooks.
+	Migrations []*MigrationResult `json:"migrations,omitempty"`
+	// Error - error of the failed run, set for on_error hooks.
+	Error string `json:"error,omitempty"`
+}
+
+// Versions - returns versions of the migrations the event is about: the migration of the event,
+// otherwise the pending migrations, otherwise the migrations run.
+func (e *HookEvent) Versions() []Version {
+	var versions []Version
+	switch {
+	case e.Migration != nil:
+		versions = append(versions, e.Migration.Version)
+	case len(e.Pending) > 0:
+		for _, pending := range e.Pending {
+			versions = append(versions, pending.Version)
+		}
+	default:
+		for _, result := range e.Migrations {
+			versions = append(versions, result.Version)
+		}
+	}
+	return versions
+}
+
+// Hook - runs at a stage of an up run, e.g. to back up a table or pause a consumer before a migration.
+// An error of a hook fails the run, except errors of on_error hooks that are only logged.
+type Hook func(ctx context.Context, event *HookEvent) error
+
+// HookCommand - shell command run as a hook by the CLI.
+type HookCommand struct {
+	Stage   HookStage
+	Command string
+	// Versions - migrations the hook runs for, all migrations if empty.
+	Versions []Version
+	// Timeout - maximum duration of the command, 0 means no limit.
+	Timeout time.Duration
+}
+
+// Validate - checks the stage, the command and the timeout.
+func (h HookCommand) Validate() error {
+	if err := h.Stage.Validate(); err != nil {
+		return err
+	}
+	if len(h.Command) == 0 {
+		return NewError(ErrorKindConfig, errors.New("Hook command required"))
+	}
+	if h.Timeout < 0 {
+		return NewError(ErrorKindConfig, errors.New("Hook timeout cannot be negative"))
+	}
+	return nil
+}
+
+// IsRunFor - checks if the hook runs for the event, i.e. the event is about one of the hook versions.
+func (h HookCommand) IsRunFor(event *HookEvent) bool {
+	if len(h.Versions) == 0 {
+		return true
+	}
+	for _, version := range event.Versions() {
+		for _, hookVersion := range h.Versions {
+			if version.Compare(hookVersion) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lock consts.
+const (
+	DefaultLockTTL = time.Hour
+)
+
+// Lock - migrations lock, prevents concurrent runs against the same migrations table.
+type Lock struct {
+	Owner      string `json:"owner"`
+	AcquiredAt int64  `json:"acquired_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// NewLock - constructs a new lock that expires after the ttl.
+func NewLock(owner string, now time.Time, ttl time.Duration) Lock {
+	return Lock{
+		Owner:      owner,
+		AcquiredAt: now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+	}
+}
+
+// String - returns a string representation.
+func (l Lock) String() string {
+	return fmt.Sprintf("owner: %s, acquired at: %s, expires at: %s",
+		l.Owner,
+		time.Unix(l.AcquiredAt, 0).UTC().Format(time.RFC3339),
+		time.Unix(l.ExpiresAt, 0).UTC().Format(time.RFC3339),
+	)
+}
+
+// LockHeldError - returned when the lock is held by another owner.
+type LockHeldError struct {
+	Lock Lock
+}
+
+// Error - returns an error message.
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("Migrations lock is held by another run (%s), wait for it or run unlock", e.Lock)
+}
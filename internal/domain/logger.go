@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LogLevel - severity of a log entry.
+type LogLevel int
+
+// Log levels.
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// String - returns a string representation.
+func (l LogLevel) String() string {
+	if l < LogLevelDebug || l > LogLevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel - parses a log level name: debug, info, warn or error.
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogLevelInfo, fmt.Errorf("Invalid log level %q, expected one of: %s", s, strings.Join(logLevelNames, ", "))
+}
+
+// LogField - key-value pair attached to a log entry, e.g. the migration version or table.
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// F - constructs a log field.
+func F(key string, value interface{}) LogField {
+	return LogField{
+		Key:   key,
+		Value: value,
+	}
+}
+
+// Logger - leveled structured logger, implement it to route logs into zap, slog etc.
+type Logger interface {
+
+	// Debug - logs details useful for troubleshooting.
+	Debug(msg string, fields ...LogField)
+
+	// Info - logs progress of a command.
+	Info(msg string, fields ...LogField)
+
+	// Warn - logs problems that do not stop a command.
+	Warn(msg string, fields ...LogField)
+
+	// Error - logs problems that stop a command.
+	Error(msg string, fields ...LogField)
+
+	// With - returns a logger adding the fields to every entry.
+	With(fields ...LogField) Logger
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// Metrics - records measurements of migration runs, e.g. for Prometheus.
+type Metrics interface {
+	// MigrationFinished - records an applied, failed or reverted migration.
+	MigrationFinished(result *MigrationResult)
+	// MigrationsSkipped - records pending migrations that were not run because the run stopped before them.
+	MigrationsSkipped(count int)
+	// RequestRetried - records a retry of the AWS operation failed with the error code.
+	RequestRetried(operation, code string)
+	// RequestThrottled - records an AWS request rejected by throttling.
+	RequestThrottled(operation string)
+	// LockWaited - records the time spent acquiring the migrations lock.
+	LockWaited(d time.Duration)
+}
+
+// MetricsExport - where metrics of a run are exported.
+type MetricsExport struct {
+	// Addr - address of the HTTP endpoint serving metrics while the command runs, empty disables it.
+	Addr string
+	// PushURL - Pushgateway URL metrics are pushed to when the command ends, empty disables the push.
+	PushURL string
+	// Job - job label of pushed metrics.
+	Job string
+}
+
+// Validate - checks the push URL and the job label.
+func (e MetricsExport) Validate() error {
+	if len(e.PushURL) == 0 {
+		return nil
+	}
+	if u, err := url.Parse(e.PushURL); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return Errorf(ErrorKindConfig, "Invalid metrics push URL: %s", e.PushURL)
+	}
+	if len(e.Job) == 0 {
+		return NewError(ErrorKindConfig, errors.New("Metrics job required to push metrics"))
+	}
+	return nil
+}
+
+// DefaultMetricsJob - job label of pushed metrics.
+const DefaultMetricsJob = "dynamodb_migrations"
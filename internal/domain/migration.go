@@ -1,6 +1,12 @@
 package domain
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"strconv"
 	"time"
 )
@@ -8,8 +14,11 @@ import (
 // Migration consts.
 const (
 	MigrationFilePattern = `(.*\/)?((\d+).(\d+).(\d+).*\.json)$`
+	VersionPattern       = `^(\d+)\.(\d+)\.(\d+)$`
 )
 
+var versionRegexp = regexp.MustCompile(VersionPattern)
+
 // Version - version struct.
 type Version struct {
 	Major int
@@ -22,22 +31,228 @@ func (ver Version) String() string {
 	return strconv.Itoa(ver.Major) + "." + strconv.Itoa(ver.Minor) + "." + strconv.Itoa(ver.Patch)
 }
 
+// MarshalJSON - marshals the version as a string, e.g. "1.2.0".
+func (ver Version) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ver.String())
+}
+
+// UnmarshalJSON - unmarshals the version from a string.
+func (ver *Version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseVersion(s)
+	if err != nil {
+		return err
+	}
+	*ver = parsed
+	return nil
+}
+
 // ID - returns a string representation of ID.
 func (ver Version) ID() string {
 	return strconv.Itoa(ver.Major) + "." + strconv.Itoa(ver.Minor) + "." + strconv.Itoa(ver.Patch)
 }
 
+// Compare - compares two versions, returns -1, 0 or 1.
+func (ver Version) Compare(other Version) int {
+	switch {
+	case ver.Major != other.Major:
+		return compareInt(ver.Major, other.Major)
+	case ver.Minor != other.Minor:
+		return compareInt(ver.Minor, other.Minor)
+	default:
+		return compareInt(ver.Patch, other.Patch)
+	}
+}
+
+// Less - checks if the version is lower than the other one.
+func (ver Version) Less(other Version) bool {
+	return ver.Compare(other) < 0
+}
+
+// ParseVersion - parses a version in the major.minor.patch format.
+func ParseVersion(s string) (Version, error) {
+	match := versionRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return Version{}, fmt.Errorf("Invalid version %q, expected major.minor.patch", s)
+	}
+	var (
+		parts = make([]int, 3)
+		err   error
+	)
+	for i := range parts {
+		if parts[i], err = strconv.Atoi(match[i+1]); err != nil {
+			return Version{}, fmt.Errorf("Invalid version %q: %v", s, err)
+		}
+	}
+	return Version{
+		Major: parts[0],
+		Minor: parts[1],
+		Patch: parts[2],
+	}, nil
+}
+
+func compareInt(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// VersionBump - part of the version incremented for a new migration.
+type VersionBump string
+
+// Version bumps.
+const (
+	VersionBumpPatch     VersionBump = "patch"
+	VersionBumpMinor     VersionBump = "minor"
+	VersionBumpMajor     VersionBump = "major"
+	VersionBumpTimestamp VersionBump = "timestamp"
+)
+
+// NextVersion - returns the version of a new migration, latest is nil when there are no migrations.
+// The timestamp bump uses the date as major and the time as minor version, e.g. 20210901.143005.0.
+func NextVersion(latest *Version, bump VersionBump, now time.Time) (Version, error) {
+	if bump == VersionBumpTimestamp {
+		now = now.UTC()
+		next := Version{
+			Major: now.Year()*10000 + int(now.Month())*100 + now.Day(),
+			Minor: now.Hour()*10000 + now.Minute()*100 + now.Second(),
+		}
+		if latest != nil && !latest.Less(next) {
+			return Version{}, fmt.Errorf("Timestamp version %s must be greater than the latest version %s", next, latest)
+		}
+		return next, nil
+	}
+	if latest == nil {
+		return Version{Major: 1}, nil
+	}
+	switch bump {
+	case VersionBumpMajor:
+		return Version{Major: latest.Major + 1}, nil
+	case VersionBumpMinor:
+		return Version{Major: latest.Major, Minor: latest.Minor + 1}, nil
+	case VersionBumpPatch:
+		return Version{Major: latest.Major, Minor: latest.Minor, Patch: latest.Patch + 1}, nil
+	default:
+		return Version{}, fmt.Errorf("Unknown version bump: %s", bump)
+	}
+}
+
 // Metadata - migrations metadata.
 type Metadata struct {
-	StartTime     int64
-	ExecutionTime int64
+	StartTime     int64  `json:"start_time"`
+	ExecutionTime int64  `json:"execution_time"`
+	StartTimeMs   int64  `json:"start_time_ms"`
+	DurationMs    int64  `json:"duration_ms"`
+	AppVersion    string `json:"app_version"`
+	Hostname      string `json:"hostname"`
+	Runner        string `json:"runner"`
+	TablesCreated int    `json:"tables_created"`
+	ItemsWritten  int    `json:"items_written"`
+	// ConsumedCapacity - capacity units consumed by the migration requests by table.
+	ConsumedCapacity ConsumedCapacity `json:"consumed_capacity,omitempty"`
+	// EstimatedCost - estimated on-demand cost of the consumed capacity in USD.
+	EstimatedCost float64 `json:"estimated_cost"`
+	// Backups - on-demand backups of tables taken before the queries were executed.
+	Backups []TableBackup `json:"backups,omitempty"`
 }
 
+// Runner - describes who runs the migrations.
+type Runner struct {
+	// AppVersion - version of the migration tool.
+	AppVersion string
+	// Hostname - host where the migration tool runs.
+	Hostname string
+	// Identity - runner identity, e.g. the caller ARN.
+	Identity string
+}
+
+// ExecutionResult - result of executed migration queries.
+type ExecutionResult struct {
+	TablesCreated int `json:"tables_created"`
+	ItemsWritten  int `json:"items_written"`
+	TablesDeleted int `json:"tables_deleted"`
+	ItemsDeleted  int `json:"items_deleted"`
+	// ConsumedCapacity - capacity units consumed by the migration requests by table.
+	ConsumedCapacity ConsumedCapacity `json:"consumed_capacity"`
+	// EstimatedCost - estimated on-demand cost of the consumed capacity in USD.
+	EstimatedCost float64 `json:"estimated_cost"`
+	// Streams - enabled streams of tables with a stream specification.
+	Streams []TableStream `json:"streams,omitempty"`
+}
+
+// TableStream - stream of a table, e.g. consumed by a Lambda function.
+type TableStream struct {
+	TableName string `json:"table_name"`
+	StreamArn string `json:"stream_arn"`
+}
+
+// PlannedMigration - pending migration that will be applied by the next run.
+type PlannedMigration struct {
+	Version Version `json:"version"`
+	Name    string  `json:"name"`
+	// Retry - the migration failed or was interrupted before.
+	Retry bool `json:"retry"`
+	// Tables - tables created by the migration.
+	Tables []string `json:"tables"`
+	// Items - number of items written by the migration.
+	Items int `json:"items"`
+	// Backups - tables backed up before the migration.
+	Backups []string `json:"backups,omitempty"`
+}
+
+// MigrationStatus - status of a migration record.
+type MigrationStatus string
+
+// Migration statuses.
+const (
+	MigrationStatusInProgress MigrationStatus = "in_progress"
+	MigrationStatusSucceeded  MigrationStatus = "succeeded"
+	MigrationStatusFailed     MigrationStatus = "failed"
+	// MigrationStatusReverted - status of a reverted migration in a run report, reverted migrations have no record.
+	MigrationStatusReverted MigrationStatus = "reverted"
+)
+
 // MigrationRecord - migration record.
 type MigrationRecord struct {
-	Version  Version
-	Name     string
-	Metadata Metadata
+	Version  Version  `json:"version"`
+	Name     string   `json:"name"`
+	Metadata Metadata `json:"metadata"`
+	// Baselined - the record was created by the baseline command, the migration was not executed.
+	Baselined bool `json:"baselined"`
+	// Checksum - checksum of the migration file content.
+	Checksum string `json:"checksum"`
+	// Status - status of the migration, records without status are considered succeeded.
+	Status MigrationStatus `json:"status"`
+	// Error - error message of the last failed attempt.
+	Error string `json:"error,omitempty"`
+	// Attempts - number of attempts to apply the migration.
+	Attempts int `json:"attempts"`
+}
+
+// GetStatus - returns the migration status, records without status are considered succeeded.
+func (rec MigrationRecord) GetStatus() MigrationStatus {
+	if len(rec.Status) == 0 {
+		return MigrationStatusSucceeded
+	}
+	return rec.Status
+}
+
+// IsSucceeded - checks if the migration was applied.
+func (rec MigrationRecord) IsSucceeded() bool {
+	return rec.GetStatus() == MigrationStatusSucceeded
+}
+
+// ComputeChecksum - returns a checksum of the migration file content.
+func ComputeChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
 
 // Migration - migration struct.
@@ -55,13 +270,101 @@ func (mig Migration) String() string {
 func (mig *Migration) SetExecutionTime(start, end time.Time) {
 	mig.Metadata.StartTime = start.Unix()
 	mig.Metadata.ExecutionTime = end.Unix() - start.Unix()
+	mig.Metadata.StartTimeMs = start.UnixNano() / int64(time.Millisecond)
+	mig.Metadata.DurationMs = end.Sub(start).Milliseconds()
+}
+
+// SetRunner - sets the metadata of the migration runner.
+func (mig *Migration) SetRunner(runner Runner) {
+	mig.Metadata.AppVersion = runner.AppVersion
+	mig.Metadata.Hostname = runner.Hostname
+	mig.Metadata.Runner = runner.Identity
+}
+
+// SetExecutionResult - sets the metadata of executed queries.
+func (mig *Migration) SetExecutionResult(result ExecutionResult) {
+	mig.Metadata.TablesCreated = result.TablesCreated
+	mig.Metadata.ItemsWritten = result.ItemsWritten
+	mig.Metadata.ConsumedCapacity = result.ConsumedCapacity
+	mig.Metadata.EstimatedCost = result.EstimatedCost
+}
+
+// MigrationResult - result of a migration applied or reverted by a run.
+type MigrationResult struct {
+	Version   Version         `json:"version"`
+	Name      string          `json:"name"`
+	Status    MigrationStatus `json:"status"`
+	StartTime time.Time       `json:"start_time"`
+	// DurationMs - duration of the migration in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+	ExecutionResult
+	// Backups - on-demand backups of tables taken before the queries were executed.
+	Backups []TableBackup `json:"backups,omitempty"`
+	// Error - error message if the migration failed.
+	Error string `json:"error,omitempty"`
+}
+
+// RunReport - migrations applied or reverted by a run, contains the results collected before a failure.
+type RunReport struct {
+	Migrations []*MigrationResult `json:"migrations"`
+	// ConsumedCapacity - capacity units consumed by all migrations by table.
+	ConsumedCapacity ConsumedCapacity `json:"consumed_capacity"`
+	// EstimatedCost - estimated on-demand cost of all migrations in USD.
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// NewRunReport - constructs an empty run report.
+func NewRunReport() *RunReport {
+	return &RunReport{
+		Migrations:       []*MigrationResult{},
+		ConsumedCapacity: make(ConsumedCapacity),
+	}
+}
+
+// Add - adds the migration result and its consumed capacity.
+func (r *RunReport) Add(result *MigrationResult) {
+	r.Migrations = append(r.Migrations, result)
+	r.ConsumedCapacity.Add(result.ConsumedCapacity)
+	r.EstimatedCost += result.EstimatedCost
+}
+
+// Count - returns the number of migrations with the status.
+func (r *RunReport) Count(status MigrationStatus) int {
+	count := 0
+	for _, result := range r.Migrations {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// MigrationInfo - migration file and its record.
+type MigrationInfo struct {
+	Version Version `json:"version"`
+	Name    string  `json:"name"`
+	// FileChecksum - checksum of the migration file, empty if the file does not exist.
+	FileChecksum string `json:"file_checksum"`
+	// Record - migration record, nil if the migration is pending.
+	Record *MigrationRecord `json:"record"`
+	// RestoreHints - commands restoring the backups taken before the migration.
+	RestoreHints []string `json:"restore_hints,omitempty"`
+}
+
+// IsChecksumMismatch - checks if the migration file was changed after it was applied.
+func (info MigrationInfo) IsChecksumMismatch() bool {
+	return info.Record != nil && len(info.Record.Checksum) > 0 && len(info.FileChecksum) > 0 &&
+		info.Record.Checksum != info.FileChecksum
 }
 
 // QueryExecutor - query executor.
 type QueryExecutor interface {
 
 	// ExecuteQueries - execute migration queries.
-	ExecuteQueries(queries []*DynamoDBQuery) error
+	ExecuteQueries(ctx context.Context, queries []*DynamoDBQuery) (ExecutionResult, error)
+
+	// RevertQueries - deletes items written by migration queries, tables are deleted only if requested.
+	RevertQueries(ctx context.Context, queries []*DynamoDBQuery, dropTables bool) (ExecutionResult, error)
 }
 
 // MigrationRepository - migration repository interface.
@@ -69,10 +372,35 @@ type MigrationRepository interface {
 	QueryExecutor
 
 	// IsMigrationRecordExist - checks if the migration record exist.
-	IsMigrationRecordExist(ver Version) (bool, error)
+	IsMigrationRecordExist(ctx context.Context, ver Version) (bool, error)
 
 	// CreateMigrationRecord - creates migration record.
-	CreateMigrationRecord(migrationRecord MigrationRecord) error
+	CreateMigrationRecord(ctx context.Context, migrationRecord MigrationRecord) error
+
+	// GetMigrationRecord - returns the migration record or nil if it does not exist.
+	GetMigrationRecord(ctx context.Context, ver Version) (*MigrationRecord, error)
+
+	// GetMigrationRecords - returns all migration records.
+	GetMigrationRecords(ctx context.Context) ([]*MigrationRecord, error)
+
+	// UpdateMigrationRecord - replaces an existing migration record.
+	UpdateMigrationRecord(ctx context.Context, migrationRecord MigrationRecord) error
+
+	// DeleteMigrationRecord - deletes an existing migration record.
+	DeleteMigrationRecord(ctx context.Context, ver Version) error
+
+	// AcquireLock - acquires the migrations lock, returns LockHeldError if another owner holds it.
+	AcquireLock(ctx context.Context, lock Lock) error
+
+	// ReleaseLock - releases the migrations lock held by the owner.
+	ReleaseLock(ctx context.Context, owner string) error
+
+	// RefreshLock - extends the migrations lock held by the owner until its expiry time,
+	// returns an error of kind ErrorKindLockHeld if the owner no longer holds it.
+	RefreshLock(ctx context.Context, lock Lock) error
+
+	// DeleteLock - deletes the migrations lock regardless of the owner, returns nil if there was no lock.
+	DeleteLock(ctx context.Context) (*Lock, error)
 }
 
 // MigrationStorage - migration storage.
@@ -80,11 +408,60 @@ type MigrationStorage interface {
 
 	// GetExecutableMigrations - returns executable migrations.
 	GetExecutableMigrations() ([]*Migration, error)
+
+	// CreateMigration - creates a new migration file, returns its path.
+	CreateMigration(name string, content []byte) (string, error)
+}
+
+// MigrateOptions - options of a migration run.
+type MigrateOptions struct {
+	// Target - the last version to apply, nil means all pending migrations.
+	Target *Version
+	// Steps - the maximum number of pending migrations to apply, 0 means no limit.
+	Steps int
+	// AllowFailed - retry failed or in-progress migrations instead of stopping at them.
+	AllowFailed bool
+}
+
+// RollbackOptions - options of a rollback run.
+type RollbackOptions struct {
+	// Target - the last version to keep applied, nil means the steps limit only.
+	Target *Version
+	// Steps - the maximum number of applied migrations to revert, 0 means no limit.
+	Steps int
+	// DropTables - delete tables created by the reverted migrations.
+	DropTables bool
 }
 
 // MigrationService - migration service.
 type MigrationService interface {
 
-	// RunMigrations - runs migrations.
-	Migrate() (applied int, err error)
+	// Migrate - runs migrations, the report contains the failed migration and the ones applied before it.
+	// Cancelling the context stops the run between migrations, the running migration is completed unless the deadline expires.
+	Migrate(ctx context.Context, options MigrateOptions) (*RunReport, error)
+
+	// Plan - returns pending migrations that will be applied by Migrate with the same options.
+	Plan(ctx context.Context, options MigrateOptions) ([]*PlannedMigration, error)
+
+	// Rollback - reverts applied migrations in the reverse order, the report contains the migrations reverted before a failure.
+	// Cancelling the context stops the run between migrations, the running migration is completed unless the deadline expires.
+	Rollback(ctx context.Context, options RollbackOptions) (*RunReport, error)
+
+	// Baseline - marks migrations up to the target version as applied without executing them.
+	Baseline(ctx context.Context, target Version) (baselined int, err error)
+
+	// MarkApplied - marks a single migration as applied without executing it.
+	MarkApplied(ctx context.Context, ver Version) error
+
+	// Unmark - removes the migration record, so the migration will be applied again.
+	Unmark(ctx context.Context, ver Version) error
+
+	// Repair - recomputes checksums of migration records and removes failed records.
+	Repair(ctx context.Context) (repaired int, err error)
+
+	// Status - returns migration files merged with migration records.
+	Status(ctx context.Context) ([]*MigrationInfo, error)
+
+	// Unlock - removes the migrations lock left by a crashed run, returns nil if there was no lock.
+	Unlock(ctx context.Context) (*Lock, error)
 }
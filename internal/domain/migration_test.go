@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextVersion(t *testing.T) {
+	var (
+		now    = time.Date(2021, 9, 1, 14, 30, 5, 0, time.UTC)
+		latest = Version{Major: 1, Minor: 2, Patch: 3}
+		later  = Version{Major: 20210901, Minor: 143005}
+		older  = Version{Major: 20210901, Minor: 143004, Patch: 7}
+	)
+
+	// Test.
+	tests := []struct {
+		name        string
+		latest      *Version
+		bump        VersionBump
+		now         time.Time
+		expected    Version
+		expectError bool
+	}{
+		{
+			name:     "Success: patch",
+			latest:   &latest,
+			bump:     VersionBumpPatch,
+			expected: Version{Major: 1, Minor: 2, Patch: 4},
+		},
+		{
+			name:     "Success: minor resets the patch",
+			latest:   &latest,
+			bump:     VersionBumpMinor,
+			expected: Version{Major: 1, Minor: 3},
+		},
+		{
+			name:     "Success: major resets the minor and the patch",
+			latest:   &latest,
+			bump:     VersionBumpMajor,
+			expected: Version{Major: 2},
+		},
+		{
+			name:     "Success: first patch version",
+			bump:     VersionBumpPatch,
+			expected: Version{Major: 1},
+		},
+		{
+			name:     "Success: first major version",
+			bump:     VersionBumpMajor,
+			expected: Version{Major: 1},
+		},
+		{
+			name:     "Success: first timestamp version",
+			bump:     VersionBumpTimestamp,
+			now:      now,
+			expected: Version{Major: 20210901, Minor: 143005},
+		},
+		{
+			name:     "Success: timestamp in UTC",
+			bump:     VersionBumpTimestamp,
+			now:      now.In(time.FixedZone("CEST", 2*60*60)),
+			expected: Version{Major: 20210901, Minor: 143005},
+		},
+		{
+			name:     "Success: timestamp after semantic versions",
+			latest:   &latest,
+			bump:     VersionBumpTimestamp,
+			now:      now,
+			expected: Version{Major: 20210901, Minor: 143005},
+		},
+		{
+			name:     "Success: timestamp after an older timestamp",
+			latest:   &older,
+			bump:     VersionBumpTimestamp,
+			now:      now,
+			expected: Version{Major: 20210901, Minor: 143005},
+		},
+		{
+			name:        "Fail: timestamp equal to the latest version",
+			latest:      &later,
+			bump:        VersionBumpTimestamp,
+			now:         now,
+			expectError: true,
+		},
+		{
+			name:        "Fail: timestamp before the latest version",
+			latest:      &later,
+			bump:        VersionBumpTimestamp,
+			now:         now.Add(-time.Hour),
+			expectError: true,
+		},
+		{
+			name:        "Fail: unknown bump",
+			latest:      &latest,
+			bump:        VersionBump("build"),
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := NextVersion(test.latest, test.bump, test.now)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error but got version: %s", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if actual != test.expected {
+				t.Errorf("actual version: %s does not match expected: %s", actual, test.expected)
+			}
+		})
+	}
+}
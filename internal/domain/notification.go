@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// RunEvent - stage of a migration run that is notified.
+type RunEvent string
+
+// Run events.
+const (
+	RunEventStarted   RunEvent = "started"
+	RunEventSucceeded RunEvent = "succeeded"
+	RunEventFailed    RunEvent = "failed"
+)
+
+// RunNotification - summary of a migration run sent to notifiers.
+type RunNotification struct {
+	Event RunEvent `json:"event"`
+	// Command - command of the run, up or down.
+	Command string `json:"command"`
+	// Environment - environment of the run, e.g. the config file profile, set by the notifier.
+	Environment string    `json:"environment"`
+	Runner      string    `json:"runner"`
+	Hostname    string    `json:"hostname"`
+	StartTime   time.Time `json:"start_time"`
+	// DurationMs - duration of the run in milliseconds, 0 when the run starts.
+	DurationMs int64 `json:"duration_ms"`
+	// Pending - migrations that will be applied or reverted, set when the run starts.
+	Pending []*MigrationSummary `json:"pending,omitempty"`
+	// Migrations - migrations applied or reverted by the run, set when the run ends.
+	Migrations []*MigrationResult `json:"migrations,omitempty"`
+	// Error - error message if the run failed.
+	Error string `json:"error,omitempty"`
+}
+
+// MigrationSummary - version and name of a migration.
+type MigrationSummary struct {
+	Version Version `json:"version"`
+	Name    string  `json:"name"`
+}
+
+// Notifier - sends notifications of migration runs, e.g. to chat webhooks.
+// Failed notifications are reported by the notifier, they never fail the run.
+type Notifier interface {
+	Notify(ctx context.Context, notification *RunNotification)
+}
+
+// WebhookFormat - payload format of a webhook.
+type WebhookFormat string
+
+// Webhook formats.
+const (
+	WebhookFormatSlack WebhookFormat = "slack"
+	WebhookFormatJSON  WebhookFormat = "json"
+)
+
+// Webhook - HTTP endpoint receiving notifications of migration runs.
+type Webhook struct {
+	URL    string
+	Format WebhookFormat
+	// Events - notified events, all events if empty.
+	Events []RunEvent
+	// Template - Go template of the payload, overrides the format.
+	Template string
+}
+
+// IsNotified - checks if the event is sent to the webhook.
+func (w Webhook) IsNotified(event RunEvent) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, notified := range w.Events {
+		if notified == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate - checks the URL, the format and the events.
+func (w Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return Errorf(ErrorKindConfig, "Invalid webhook URL, expected an http or https URL")
+	}
+	switch w.Format {
+	case WebhookFormatSlack, WebhookFormatJSON:
+	default:
+		if len(w.Template) == 0 {
+			return Errorf(ErrorKindConfig, "Invalid webhook format %q, expected slack or json", w.Format)
+		}
+	}
+	for _, event := range w.Events {
+		switch event {
+		case RunEventStarted, RunEventSucceeded, RunEventFailed:
+		default:
+			return Errorf(ErrorKindConfig, "Invalid webhook event %q, expected started, succeeded or failed", event)
+		}
+	}
+	return nil
+}
@@ -5,4 +5,7 @@ type QueryParser interface {
 
 	// ParseQuery - parses content query into dynamodb query.
 	ParseContent(content []byte) ([]*DynamoDBQuery, error)
+
+	// ParseMigration - parses content into dynamodb queries and options of the migration.
+	ParseMigration(content []byte) (*DynamoDBMigration, error)
 }
@@ -1,46 +1,175 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 // Field names.
 const (
 	JSONFieldTableName = "table_name"
 	JSONFieldSchema    = "schema"
 	JSONFieldData      = "data"
+	// Fields of the object form of migration files.
+	JSONFieldBackupBefore = "backup_before"
+	JSONFieldQueries      = "queries"
+)
+
+// DynamoDB attribute and key types.
+const (
+	AttributeTypeString = "S"
+	AttributeTypeNumber = "N"
+	AttributeTypeBinary = "B"
+	KeyTypeHash         = "HASH"
+	KeyTypeRange        = "RANGE"
+)
+
+// DynamoDB stream view types.
+const (
+	StreamViewTypeKeysOnly        = "KEYS_ONLY"
+	StreamViewTypeNewImage        = "NEW_IMAGE"
+	StreamViewTypeOldImage        = "OLD_IMAGE"
+	StreamViewTypeNewAndOldImages = "NEW_AND_OLD_IMAGES"
+)
+
+// Server-side encryption types.
+const (
+	SSETypeAWSOwned = "AWS_OWNED"
+	SSETypeKMS      = "KMS"
 )
 
+// DynamoDB table classes.
+const (
+	TableClassStandard                 = "STANDARD"
+	TableClassStandardInfrequentAccess = "STANDARD_INFREQUENT_ACCESS"
+)
+
+// MaxTransactionItems - maximum number of items in a DynamoDB transaction, all items of a migration are written in one transaction.
+const MaxTransactionItems = 100
+
 // DynamoDBAttributeDefinition - represents an attribute for describing the key schema for the table and indexes.
 type DynamoDBAttributeDefinition struct {
-	AttributeName string `json:"name"`
-	AttributeType string `json:"type"`
+	AttributeName string `json:"name" jsonschema:"required,minLength=1" description:"Name of the key attribute."`
+	AttributeType string `json:"type" jsonschema:"required,enum=S|N|B" description:"Type of the key attribute: S - string, N - number, B - binary."`
 }
 
 // DynamoDBKeySchema - represents a single element of a key schema.
 type DynamoDBKeySchema struct {
-	AttributeName string `json:"name"`
-	KeyType       string `json:"type"`
+	AttributeName string `json:"name" jsonschema:"required,minLength=1" description:"Name of the key attribute, must be listed in attribute_definitions."`
+	KeyType       string `json:"type" jsonschema:"required,enum=HASH|RANGE" description:"Role of the key attribute: HASH - partition key, RANGE - sort key."`
+}
+
+// DynamoDBTimeToLive - Time to Live setting of a table, items expire at the epoch seconds in the attribute.
+type DynamoDBTimeToLive struct {
+	AttributeName string `json:"attribute_name" jsonschema:"required,minLength=1" description:"Attribute with the expiration time in epoch seconds."`
+	Enabled       bool   `json:"enabled" jsonschema:"required" description:"Enables or disables Time to Live."`
+}
+
+// DynamoDBStreamSpecification - DynamoDB Streams setting of a table.
+type DynamoDBStreamSpecification struct {
+	Enabled  bool   `json:"enabled" jsonschema:"required" description:"Enables or disables the stream."`
+	ViewType string `json:"view_type,omitempty" jsonschema:"enum=KEYS_ONLY|NEW_IMAGE|OLD_IMAGE|NEW_AND_OLD_IMAGES" description:"Item attributes written to the stream, required if the stream is enabled."`
+}
+
+// DynamoDBSSESpecification - server-side encryption of a table.
+type DynamoDBSSESpecification struct {
+	Type     string `json:"type" jsonschema:"required,enum=AWS_OWNED|KMS" description:"Encryption key: AWS_OWNED - key owned by DynamoDB, KMS - key stored in KMS."`
+	KMSKeyID string `json:"kms_key_id,omitempty" description:"ID or ARN of the KMS key, the AWS managed key alias/aws/dynamodb if empty."`
 }
 
 // DynamoDBSchema - represents a dynamodb schema format.
+// A schema without keys updates the settings of an existing table.
 type DynamoDBSchema struct {
-	AttributeDefinitions []*DynamoDBAttributeDefinition `json:"attribute_definitions"`
-	KeySchema            []*DynamoDBKeySchema           `json:"key_schema"`
+	AttributeDefinitions []*DynamoDBAttributeDefinition `json:"attribute_definitions" jsonschema:"minItems=1,maxItems=2" description:"Key attributes of the table, required to create the table."`
+	KeySchema            []*DynamoDBKeySchema           `json:"key_schema" jsonschema:"minItems=1,maxItems=2" description:"Exactly one HASH key and at most one RANGE key, required to create the table."`
+	TTL                  *DynamoDBTimeToLive            `json:"ttl,omitempty" description:"Time to Live of the table, applied after the table is created or to the existing table."`
+	StreamSpecification  *DynamoDBStreamSpecification   `json:"stream_specification,omitempty" description:"Stream of the table, set when the table is created or updated on the existing table."`
+	Tags                 map[string]string              `json:"tags,omitempty" description:"Resource tags of the table, added or updated, other tags of the table are kept."`
+	SSESpecification     *DynamoDBSSESpecification      `json:"sse_specification,omitempty" description:"Server-side encryption of the table."`
+	PointInTimeRecovery  *bool                          `json:"point_in_time_recovery,omitempty" description:"Enables or disables point-in-time recovery of the table."`
+	DeletionProtection   *bool                          `json:"deletion_protection,omitempty" description:"Enables or disables deletion protection, a protected table cannot be deleted."`
+	TableClass           string                         `json:"table_class,omitempty" jsonschema:"enum=STANDARD|STANDARD_INFREQUENT_ACCESS" description:"Table class: STANDARD or STANDARD_INFREQUENT_ACCESS for rarely read data."`
+}
+
+// CreatesTable - checks if the schema creates the table, otherwise it only updates the settings of the table.
+func (s *DynamoDBSchema) CreatesTable() bool {
+	return len(s.AttributeDefinitions) > 0 || len(s.KeySchema) > 0
+}
+
+// HasSettings - checks if the schema defines settings applied to created and existing tables.
+func (s *DynamoDBSchema) HasSettings() bool {
+	return s.TTL != nil || s.StreamSpecification != nil || len(s.Tags) > 0 || s.SSESpecification != nil || s.PointInTimeRecovery != nil ||
+		s.DeletionProtection != nil || len(s.TableClass) > 0
 }
 
 // DynamoDBQuery - represents a dynamodb query format.
 type DynamoDBQuery struct {
-	TableName string                   `json:"table_name"`
-	Schema    []*DynamoDBSchema        `json:"schema"`
-	Data      []map[string]interface{} `json:"data"`
+	TableName string                   `json:"table_name" jsonschema:"required,minLength=1" description:"Name of the table."`
+	Schema    []*DynamoDBSchema        `json:"schema" description:"Creates the table if it does not exist."`
+	Data      []map[string]interface{} `json:"data" description:"Items written in one transaction together with the other items of the migration file."`
+}
+
+// DynamoDBMigration - migration file in the object form with options of the migration,
+// the array form of migration files contains only the queries.
+type DynamoDBMigration struct {
+	BackupBefore []string         `json:"backup_before,omitempty" description:"Tables backed up on demand before the queries are executed, the migration fails if a backup fails."`
+	Queries      []*DynamoDBQuery `json:"queries" jsonschema:"required" description:"Queries of the migration."`
+	// QueriesPath - JSON pointer of the queries, empty for the array form.
+	QueriesPath string `json:"-"`
+}
+
+// ParseError - describes where a migration file cannot be parsed.
+type ParseError struct {
+	// Path - JSON pointer to the invalid value, empty for the whole file.
+	Path string
+	Err  error
+}
+
+// Error - returns an error message.
+func (e *ParseError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap - returns the wrapped error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
 }
 
 // Validate - checks if the dynamodb query is valid.
 func (q *DynamoDBQuery) Validate() error {
 	if len(q.TableName) == 0 {
-		return errors.New("Table name required")
+		return NewError(ErrorKindValidation, errors.New("Table name required"))
 	}
 	if len(q.Schema) == 0 && len(q.Data) == 0 {
-		return errors.New("Either schema or data must be specified")
+		return NewError(ErrorKindValidation, errors.New("Either schema or data must be specified"))
+	}
+	return nil
+}
+
+// CreatesTable - checks if a schema of the query creates the table.
+func (q *DynamoDBQuery) CreatesTable() bool {
+	for _, schema := range q.Schema {
+		if schema.CreatesTable() {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate - checks if the tables to back up are named once.
+func (m *DynamoDBMigration) Validate() error {
+	tables := make(map[string]bool, len(m.BackupBefore))
+	for _, table := range m.BackupBefore {
+		if len(table) == 0 {
+			return NewError(ErrorKindValidation, errors.New("Table name of the backup required"))
+		}
+		if tables[table] {
+			return NewError(ErrorKindValidation, fmt.Errorf("Table %s is backed up twice", table))
+		}
+		tables[table] = true
 	}
 	return nil
 }
@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Retryable AWS error codes, transactions report the codes of cancellation reasons.
+const (
+	RetryCodeTransactionConflict           = "TransactionConflict"
+	RetryCodeTransactionConflictException  = "TransactionConflictException"
+	RetryCodeProvisionedThroughputExceeded = "ProvisionedThroughputExceeded"
+	RetryCodeThroughputExceededException   = "ProvisionedThroughputExceededException"
+	RetryCodeThrottlingError               = "ThrottlingError"
+	RetryCodeThrottlingException           = "ThrottlingException"
+	RetryCodeRequestLimitExceeded          = "RequestLimitExceeded"
+	RetryCodeLimitExceededException        = "LimitExceededException"
+)
+
+// RetryPolicy - retries of AWS requests failed with retryable error codes.
+type RetryPolicy struct {
+	// MaxAttempts - maximum number of attempts including the first one, 1 disables retries.
+	MaxAttempts int `json:"max_attempts"`
+	// BaseDelay - delay before the first retry, doubled for every next retry.
+	BaseDelay time.Duration `json:"base_delay"`
+	// MaxDelay - upper limit of the delay.
+	MaxDelay time.Duration `json:"max_delay"`
+	// Jitter - fraction of the delay randomized to spread retries of concurrent runs, from 0 to 1.
+	Jitter float64 `json:"jitter"`
+	// RetryableCodes - AWS error codes that are retried.
+	RetryableCodes []string `json:"retryable_codes"`
+}
+
+// DefaultRetryPolicy - returns the policy retrying throttling and transaction conflicts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.5,
+		RetryableCodes: []string{
+			RetryCodeTransactionConflict,
+			RetryCodeTransactionConflictException,
+			RetryCodeProvisionedThroughputExceeded,
+			RetryCodeThroughputExceededException,
+			RetryCodeThrottlingError,
+			RetryCodeThrottlingException,
+			RetryCodeRequestLimitExceeded,
+			RetryCodeLimitExceededException,
+		},
+	}
+}
+
+// Validate - checks if the retry policy properties are valid.
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.MaxAttempts < 1:
+		return NewError(ErrorKindConfig, errors.New("Retry max attempts must be positive"))
+	case p.BaseDelay < 0 || p.MaxDelay < 0:
+		return NewError(ErrorKindConfig, errors.New("Retry delays cannot be negative"))
+	case p.Jitter < 0 || p.Jitter > 1:
+		return NewError(ErrorKindConfig, errors.New("Retry jitter must be between 0 and 1"))
+	}
+	return nil
+}
+
+// IsThrottlingCode - checks if the error code means the request was rejected by throttling.
+func IsThrottlingCode(code string) bool {
+	switch code {
+	case RetryCodeProvisionedThroughputExceeded, RetryCodeThroughputExceededException,
+		RetryCodeThrottlingError, RetryCodeThrottlingException,
+		RetryCodeRequestLimitExceeded, RetryCodeLimitExceededException:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRetryable - checks if the error code is retried.
+func (p RetryPolicy) IsRetryable(code string) bool {
+	for _, retryable := range p.RetryableCodes {
+		if retryable == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay - returns the delay before the retry following the attempt, random is a number from 0 to 1.
+func (p RetryPolicy) Delay(attempt int, random float64) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	delay -= delay * p.Jitter * random
+	return time.Duration(delay)
+}
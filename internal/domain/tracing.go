@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"net/url"
+)
+
+// TracingExport - OTLP export of run traces.
+type TracingExport struct {
+	// Endpoint - URL of the OTLP/HTTP collector, e.g. http://localhost:4318, empty disables tracing.
+	// Spans are sent to /v1/traces under the URL path.
+	Endpoint string
+	// Headers - headers of export requests, e.g. an API key of the tracing backend.
+	Headers map[string]string
+	// ServiceName - service.name resource attribute of the spans.
+	ServiceName string
+}
+
+// DefaultTracingServiceName - service.name of the spans.
+const DefaultTracingServiceName = "dynamodb-migrations"
+
+// Validate - checks the endpoint URL.
+func (e TracingExport) Validate() error {
+	if len(e.Endpoint) == 0 {
+		return nil
+	}
+	u, err := url.Parse(e.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return Errorf(ErrorKindConfig, "Invalid OTLP endpoint, expected an http or https URL: %s", e.Endpoint)
+	}
+	return nil
+}
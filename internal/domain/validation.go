@@ -0,0 +1,26 @@
+package domain
+
+// ValidationIssue - describes a problem found in a migration file.
+type ValidationIssue struct {
+	// Migration - name of the migration file.
+	Migration string `json:"migration"`
+	// Path - location of the problem inside the migration file, empty for the whole file.
+	Path string `json:"path"`
+	// Message - description of the problem.
+	Message string `json:"message"`
+}
+
+// String - returns a string representation.
+func (i ValidationIssue) String() string {
+	if len(i.Path) == 0 {
+		return i.Migration + ": " + i.Message
+	}
+	return i.Migration + ": " + i.Path + ": " + i.Message
+}
+
+// MigrationValidator - validates migration files without AWS access.
+type MigrationValidator interface {
+
+	// Validate - returns problems found in the migration files.
+	Validate(migrations []*Migration) []*ValidationIssue
+}
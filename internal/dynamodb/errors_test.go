@@ -0,0 +1,111 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// transactionCanceled - returns the error of a transaction canceled for the reasons.
+func transactionCanceled(reasons ...string) error {
+	err := &awsDynamodb.TransactionCanceledException{Message_: aws.String("Transaction cancelled")}
+	for _, reason := range reasons {
+		err.CancellationReasons = append(err.CancellationReasons, &awsDynamodb.CancellationReason{Code: aws.String(reason)})
+	}
+	return err
+}
+
+func TestWrapAWSError(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name          string
+		err           error
+		expectedKind  domain.ErrorKind
+		expectedCodes []string
+	}{
+		{
+			name: "Success: no error",
+		},
+		{
+			name:         "Success: classified error is kept",
+			err:          domain.Errorf(domain.ErrorKindMigrationState, "Migration record does not exist: 1.0.0"),
+			expectedKind: domain.ErrorKindMigrationState,
+		},
+		{
+			name:         "Success: error of another service is kept",
+			err:          errors.New("Migrations table name required"),
+			expectedKind: domain.ErrorKindInternal,
+		},
+		{
+			name:          "Success: canceled request",
+			err:           awserr.New(request.CanceledErrorCode, "request context canceled", nil),
+			expectedKind:  domain.ErrorKindCanceled,
+			expectedCodes: []string{request.CanceledErrorCode},
+		},
+		{
+			name:          "Success: transaction conflict",
+			err:           awserr.New(awsDynamodb.ErrCodeTransactionConflictException, "Transaction is ongoing", nil),
+			expectedKind:  domain.ErrorKindTransactionConflict,
+			expectedCodes: []string{awsDynamodb.ErrCodeTransactionConflictException},
+		},
+		{
+			name:          "Success: transaction canceled by a conflict",
+			err:           transactionCanceled("None", awsReasonTransactionConflict),
+			expectedKind:  domain.ErrorKindTransactionConflict,
+			expectedCodes: []string{"None", awsReasonTransactionConflict, awsDynamodb.ErrCodeTransactionCanceledException},
+		},
+		{
+			name:          "Success: transaction canceled by a condition",
+			err:           transactionCanceled("ConditionalCheckFailed", "None"),
+			expectedKind:  domain.ErrorKindAWS,
+			expectedCodes: []string{"ConditionalCheckFailed", "None", awsDynamodb.ErrCodeTransactionCanceledException},
+		},
+		{
+			name:          "Success: throttled request",
+			err:           awserr.New(awsDynamodb.ErrCodeProvisionedThroughputExceededException, "Throughput exceeded", nil),
+			expectedKind:  domain.ErrorKindAWS,
+			expectedCodes: []string{awsDynamodb.ErrCodeProvisionedThroughputExceededException},
+		},
+		{
+			name:          "Success: wrapped AWS error",
+			err:           fmt.Errorf("Query API call failed: %w", awserr.New(awsDynamodb.ErrCodeResourceNotFoundException, "Table not found", nil)),
+			expectedKind:  domain.ErrorKindAWS,
+			expectedCodes: []string{awsDynamodb.ErrCodeResourceNotFoundException},
+		},
+		{
+			name:          "Success: wrapped transaction canceled by a conflict",
+			err:           fmt.Errorf("Transaction failed: %w", transactionCanceled(awsReasonTransactionConflict)),
+			expectedKind:  domain.ErrorKindTransactionConflict,
+			expectedCodes: []string{awsReasonTransactionConflict, awsDynamodb.ErrCodeTransactionCanceledException},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if codes := awsErrorCodes(test.err); !reflect.DeepEqual(codes, test.expectedCodes) {
+				t.Errorf("actual codes: %v do not match expected: %v", codes, test.expectedCodes)
+			}
+			err := wrapAWSError(test.err)
+			if test.err == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if kind := domain.ErrorKindOf(err); kind != test.expectedKind {
+				t.Errorf("actual kind: %q does not match expected: %q", kind, test.expectedKind)
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("wrapped error: %v must keep the original error: %v", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/ratelimit"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	awsSession "github.com/aws/aws-sdk-go/aws/session"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// lockStandIn - local stand-in of the DynamoDB lock requests, the lock is held by another owner until a number of reads.
+type lockStandIn struct {
+	mu sync.Mutex
+	// holder - owner of the lock, the lock is released when it is read after releasedAfter reads.
+	holder        string
+	releasedAfter int
+	// retaken - puts of the lock rejected after the holder released it, as if other runs took it in between.
+	retaken int
+	puts    int
+}
+
+func (s *lockStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	switch operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."); operation {
+	case "PutItem":
+		s.puts++
+		if len(s.holder) > 0 || s.retaken > 0 {
+			if len(s.holder) == 0 {
+				s.retaken--
+			}
+			writeLockError(w, "ConditionalCheckFailedException", "The conditional request failed")
+			return
+		}
+		_, _ = w.Write([]byte("{}"))
+	case "GetItem":
+		if s.releasedAfter == 0 {
+			s.holder = ""
+		}
+		s.releasedAfter--
+		if len(s.holder) == 0 {
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		lock := marshalLock(domain.NewLock(s.holder, time.Now(), time.Hour))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Item": lock})
+	default:
+		writeLockError(w, "UnknownOperationException", operation)
+	}
+}
+
+func writeLockError(w http.ResponseWriter, code, message string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.dynamodb.v20120810#" + code, "message": message})
+}
+
+func TestAcquireLockReleasedByHolder(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name         string
+		standIn      *lockStandIn
+		expectedPuts int
+		expectedKind domain.ErrorKind
+		// expectedOwner - owner of the held lock reported by LockHeldError.
+		expectedOwner string
+	}{
+		{
+			name:          "Fail: lock held by another owner",
+			standIn:       &lockStandIn{holder: "another", releasedAfter: -1},
+			expectedPuts:  1,
+			expectedKind:  domain.ErrorKindLockHeld,
+			expectedOwner: "another",
+		},
+		{
+			name:         "Success: lock released before the holder is read",
+			standIn:      &lockStandIn{holder: "another"},
+			expectedPuts: 2,
+		},
+		{
+			name:         "Fail: lock taken and released repeatedly",
+			standIn:      &lockStandIn{holder: "another", retaken: maxLockAttempts},
+			expectedPuts: maxLockAttempts,
+			expectedKind: domain.ErrorKindLockHeld,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(test.standIn)
+			defer server.Close()
+			session := awsSession.Must(awsSession.NewSession(&aws.Config{
+				Endpoint:    aws.String(server.URL),
+				Region:      aws.String("eu-west-1"),
+				Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+			}))
+			repository := &migrationRepo{
+				db:              awsDynamodb.New(session, noRetries()),
+				migrationsTable: "migrations",
+				readLimiter:     ratelimit.NewTableLimiter(0, nil),
+				writeLimiter:    ratelimit.NewTableLimiter(0, nil),
+			}
+
+			err := repository.AcquireLock(context.Background(), domain.NewLock("owner", time.Now(), time.Hour))
+			if kind := domain.ErrorKindOf(err); kind != test.expectedKind {
+				t.Errorf("actual error kind: %q does not match expected: %q, error: %v", kind, test.expectedKind, err)
+			}
+			var lockErr *domain.LockHeldError
+			if errors.As(err, &lockErr) != (len(test.expectedOwner) > 0) || (lockErr != nil && lockErr.Lock.Owner != test.expectedOwner) {
+				t.Errorf("unexpected lock held error: %v, expected owner: %q", err, test.expectedOwner)
+			}
+			if test.standIn.puts != test.expectedPuts {
+				t.Errorf("actual puts: %d does not match expected: %d", test.standIn.puts, test.expectedPuts)
+			}
+		})
+	}
+}
@@ -78,7 +78,10 @@ func TestMain(m *testing.M) {
 
 	// Init repositories.
 	//
-	testMigrationRepository = NewMigrationRepository(testAwsSession, "testMigrations")
+	testMigrationRepository, err = NewMigrationRepository(ctx, testAwsSession, "testMigrations")
+	if err != nil {
+		log.Fatalf("Failed to create migration repository %v", err)
+	}
 
 	exitVal := m.Run()
 	os.Exit(exitVal)
@@ -1,24 +1,37 @@
 package dynamodb
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"os"
+	"sort"
 	"strconv"
+	"time"
 
 	"dynamodb.data-migration/internal/domain"
 	"dynamodb.data-migration/internal/helpers"
+	"dynamodb.data-migration/internal/logging"
+	"dynamodb.data-migration/internal/metrics"
+	"dynamodb.data-migration/internal/ratelimit"
+	"dynamodb.data-migration/internal/retry"
+	"dynamodb.data-migration/internal/tracing"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	awsSession "github.com/aws/aws-sdk-go/aws/session"
 	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	awsErrorResourceNotFound = "ResourceNotFoundException"
 	awsErrorResourceInUse    = "ResourceInUseException"
+	// awsReasonTransactionConflict - cancellation reason of a transaction conflicting with another request.
+	awsReasonTransactionConflict = "TransactionConflict"
 )
 
 const (
@@ -27,26 +40,126 @@ const (
 	fieldMetadata      = "metadata"
 	fieldStartTime     = "start_time"
 	fieldExecutionTime = "execution_time"
+	fieldStartTimeMs   = "start_time_ms"
+	fieldDurationMs    = "duration_ms"
+	fieldAppVersion    = "app_version"
+	fieldHostname      = "hostname"
+	fieldRunner        = "runner"
+	fieldTablesCreated = "tables_created"
+	fieldItemsWritten  = "items_written"
+	fieldCapacity      = "consumed_capacity"
+	fieldReadUnits     = "read_units"
+	fieldWriteUnits    = "write_units"
+	fieldEstimatedCost = "estimated_cost"
+	fieldBackups       = "backups"
+	fieldTableName     = "table_name"
+	fieldBackupName    = "backup_name"
+	fieldBackupArn     = "backup_arn"
+	fieldBaselined     = "baselined"
+	fieldChecksum      = "checksum"
+	fieldStatus        = "status"
+	fieldError         = "error"
+	fieldAttempts      = "attempts"
+	fieldOwner         = "owner"
+	fieldAcquiredAt    = "acquired_at"
+	fieldExpiresAt     = "expires_at"
 )
 
+// lockID - key of the lock item in the migrations table.
+const lockID = "migrations_lock"
+
+// maxLockAttempts - number of puts of the lock released by its holder while it is acquired.
+const maxLockAttempts = 3
+
+// errLockReleased - the lock held by another owner is released after the conditional put of the lock failed.
+var errLockReleased = errors.New("migrations lock is released")
+
 type migrationRepo struct {
 	db              *awsDynamodb.DynamoDB
 	migrationsTable string
+	logger          domain.Logger
+	metrics         domain.Metrics
+	tracer          trace.Tracer
+	retryPolicy     domain.RetryPolicy
+	retryer         *retry.Retryer
+	rateLimits      domain.RateLimits
+	readLimiter     *ratelimit.TableLimiter
+	writeLimiter    *ratelimit.TableLimiter
+	pollInterval    time.Duration
+}
+
+// Option - configures optional repository dependencies.
+type Option func(r *migrationRepo)
+
+// WithLogger - sets the logger, by default info entries are written to stderr as text.
+func WithLogger(logger domain.Logger) Option {
+	return func(r *migrationRepo) {
+		r.logger = logger
+	}
+}
+
+// WithMetrics - sets the metrics of retried and throttled requests, by default they are discarded.
+func WithMetrics(metrics domain.Metrics) Option {
+	return func(r *migrationRepo) {
+		r.metrics = metrics
+	}
+}
+
+// WithTracer - sets the tracer of query execution, by default spans are discarded.
+// AWS calls are traced by the instrumented session, see tracing.InstrumentSession.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(r *migrationRepo) {
+		r.tracer = tracer
+	}
+}
+
+// WithRetryPolicy - sets the retry policy of throttled and conflicting requests, by default domain.DefaultRetryPolicy.
+func WithRetryPolicy(policy domain.RetryPolicy) Option {
+	return func(r *migrationRepo) {
+		r.retryPolicy = policy
+	}
 }
 
-// NewMigrationRepository creates a new repository.
-func NewMigrationRepository(session *awsSession.Session, migrationsTable string) domain.MigrationRepository {
+// WithRateLimits - sets capacity units per second consumed by migrations, by default there is no limit.
+func WithRateLimits(limits domain.RateLimits) Option {
+	return func(r *migrationRepo) {
+		r.rateLimits = limits
+	}
+}
+
+// noRetries - disables the retries of the AWS SDK, so the retry policy is the only retry layer
+// and every retry is logged and counted.
+func noRetries() *aws.Config {
+	return aws.NewConfig().WithMaxRetries(0)
+}
+
+// NewMigrationRepository creates a new repository, the migrations table is created if it does not exist.
+func NewMigrationRepository(ctx context.Context, session *awsSession.Session, migrationsTable string, options ...Option) (domain.MigrationRepository, error) {
 	r := &migrationRepo{
-		db:              awsDynamodb.New(session),
+		db:              awsDynamodb.New(session, noRetries()),
 		migrationsTable: migrationsTable,
+		logger:          logging.NewLogger(os.Stderr, domain.LogLevelInfo, logging.FormatText),
+		metrics:         metrics.NewNopMetrics(),
+		tracer:          tracing.NewNopTracer(),
+		retryPolicy:     domain.DefaultRetryPolicy(),
+		pollInterval:    defaultPollInterval,
+	}
+	for _, option := range options {
+		option(r)
 	}
-	if err := r.ensureMigrationsTableExist(); err != nil {
-		panic(err)
+	// Continuous backups of a new table are unavailable until they are initialized.
+	policy := r.retryPolicy
+	policy.RetryableCodes = append([]string{awsDynamodb.ErrCodeContinuousBackupsUnavailableException}, policy.RetryableCodes...)
+	r.retryer = retry.NewRetryer(policy, awsErrorCodes, r.logger, r.metrics)
+	r.readLimiter = ratelimit.NewTableLimiter(r.rateLimits.ReadUnits, r.rateLimits.TableReadUnits())
+	r.writeLimiter = ratelimit.NewTableLimiter(r.rateLimits.WriteUnits, r.rateLimits.TableWriteUnits())
+	if err := r.ensureMigrationsTableExist(ctx); err != nil {
+		return nil, wrapAWSError(err)
 	}
-	return r
+	return r, nil
 }
 
-func (r *migrationRepo) ensureMigrationsTableExist() error {
+func (r *migrationRepo) ensureMigrationsTableExist(ctx context.Context) error {
 
 	// Check table name.
 	if len(r.migrationsTable) == 0 {
@@ -54,7 +167,7 @@ func (r *migrationRepo) ensureMigrationsTableExist() error {
 	}
 
 	// Check if table exist.
-	isExist, err := r.isTableExist(r.migrationsTable)
+	isExist, err := r.isTableExist(ctx, r.migrationsTable)
 	if err != nil {
 		return err
 	}
@@ -63,24 +176,27 @@ func (r *migrationRepo) ensureMigrationsTableExist() error {
 	}
 
 	// Create table.
-	_, err = r.db.CreateTable(&awsDynamodb.CreateTableInput{
-		AttributeDefinitions: []*awsDynamodb.AttributeDefinition{
-			{
-				AttributeName: aws.String(fieldVersion),
-				AttributeType: aws.String("S"),
+	err = r.retryer.Do(ctx, "CreateTable", func() error {
+		_, err := r.db.CreateTableWithContext(ctx, &awsDynamodb.CreateTableInput{
+			AttributeDefinitions: []*awsDynamodb.AttributeDefinition{
+				{
+					AttributeName: aws.String(fieldVersion),
+					AttributeType: aws.String("S"),
+				},
 			},
-		},
-		KeySchema: []*awsDynamodb.KeySchemaElement{
-			{
-				AttributeName: aws.String(fieldVersion),
-				KeyType:       aws.String("HASH"),
+			KeySchema: []*awsDynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String(fieldVersion),
+					KeyType:       aws.String("HASH"),
+				},
 			},
-		},
-		ProvisionedThroughput: &awsDynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(10),
-			WriteCapacityUnits: aws.Int64(10),
-		},
-		TableName: aws.String(r.migrationsTable),
+			ProvisionedThroughput: &awsDynamodb.ProvisionedThroughput{
+				ReadCapacityUnits:  aws.Int64(10),
+				WriteCapacityUnits: aws.Int64(10),
+			},
+			TableName: aws.String(r.migrationsTable),
+		})
+		return err
 	})
 	if aerr, ok := err.(awserr.Error); ok {
 		if aerr.Code() != awsErrorResourceInUse {
@@ -88,12 +204,65 @@ func (r *migrationRepo) ensureMigrationsTableExist() error {
 		}
 	}
 	// Wait for table.
-	return r.db.WaitUntilTableExists(&awsDynamodb.DescribeTableInput{
-		TableName: aws.String(r.migrationsTable),
+	return r.waitUntilTableExists(ctx, r.migrationsTable)
+}
+
+// waitUntilTableExists - waits until the table is created, throttled waits are retried.
+func (r *migrationRepo) waitUntilTableExists(ctx context.Context, tableName string) error {
+	return r.retryer.Do(ctx, "WaitUntilTableExists", func() error {
+		return r.db.WaitUntilTableExistsWithContext(ctx, &awsDynamodb.DescribeTableInput{
+			TableName: aws.String(tableName),
+		})
+	})
+}
+
+// waitUntilTableNotExists - waits until the table is deleted, throttled waits are retried.
+func (r *migrationRepo) waitUntilTableNotExists(ctx context.Context, tableName string) error {
+	return r.retryer.Do(ctx, "WaitUntilTableNotExists", func() error {
+		return r.db.WaitUntilTableNotExistsWithContext(ctx, &awsDynamodb.DescribeTableInput{
+			TableName: aws.String(tableName),
+		})
+	})
+}
+
+// transactWriteItems - runs the transaction, transactions canceled by conflicts or throttling are retried.
+// Every attempt waits for the write capacity, the estimate is corrected by the consumed capacity.
+func (r *migrationRepo) transactWriteItems(ctx context.Context, input *awsDynamodb.TransactWriteItemsInput) (*awsDynamodb.TransactWriteItemsOutput, error) {
+	var (
+		output    *awsDynamodb.TransactWriteItemsOutput
+		estimated = estimateTransactionUnits(input.TransactItems)
+	)
+	input.ReturnConsumedCapacity = aws.String(awsDynamodb.ReturnConsumedCapacityTotal)
+	err := r.retryer.Do(ctx, "TransactWriteItems", func() (err error) {
+		if err := r.writeLimiter.Wait(ctx, estimated); err != nil {
+			return err
+		}
+		output, err = r.db.TransactWriteItemsWithContext(ctx, input)
+		return err
 	})
+	if err == nil {
+		r.writeLimiter.Adjust(estimated, consumedUnits(output.ConsumedCapacity))
+		meterWrites(ctx, output.ConsumedCapacity...)
+	}
+	return output, err
+}
+
+// getItem - reads the item, waits for the read capacity.
+func (r *migrationRepo) getItem(ctx context.Context, input *awsDynamodb.GetItemInput) (*awsDynamodb.GetItemOutput, error) {
+	estimated := ratelimit.Units{aws.StringValue(input.TableName): strongReadUnits}
+	if err := r.readLimiter.Wait(ctx, estimated); err != nil {
+		return nil, err
+	}
+	input.ReturnConsumedCapacity = aws.String(awsDynamodb.ReturnConsumedCapacityTotal)
+	output, err := r.db.GetItemWithContext(ctx, input)
+	if err == nil && output.ConsumedCapacity != nil {
+		r.readLimiter.Adjust(estimated, consumedUnits([]*awsDynamodb.ConsumedCapacity{output.ConsumedCapacity}))
+		meterReads(ctx, output.ConsumedCapacity)
+	}
+	return output, err
 }
 
-func (r *migrationRepo) isTableExist(tableName string) (bool, error) {
+func (r *migrationRepo) isTableExist(ctx context.Context, tableName string) (bool, error) {
 
 	// Check table name.
 	if len(tableName) == 0 {
@@ -101,12 +270,12 @@ func (r *migrationRepo) isTableExist(tableName string) (bool, error) {
 	}
 
 	// Check if the table exist.
-	describeTableOutput, err := r.db.DescribeTable(&awsDynamodb.DescribeTableInput{
+	describeTableOutput, err := r.db.DescribeTableWithContext(ctx, &awsDynamodb.DescribeTableInput{
 		TableName: aws.String(tableName),
 	})
 	if aerr, ok := err.(awserr.Error); ok {
 		if aerr.Code() != awsErrorResourceNotFound {
-			return false, errors.New(aerr.Error())
+			return false, aerr
 		}
 	} else if err != nil {
 		// Returned internal server error.
@@ -117,7 +286,7 @@ func (r *migrationRepo) isTableExist(tableName string) (bool, error) {
 	return describeTableOutput.Table != nil, nil
 }
 
-func (r *migrationRepo) IsMigrationRecordExist(ver domain.Version) (bool, error) {
+func (r *migrationRepo) IsMigrationRecordExist(ctx context.Context, ver domain.Version) (bool, error) {
 
 	// Build the query input parameters.
 	getInput := &awsDynamodb.GetItemInput{
@@ -130,27 +299,16 @@ func (r *migrationRepo) IsMigrationRecordExist(ver domain.Version) (bool, error)
 	}
 
 	// Make the DynamoDB Query API call.
-	result, err := r.db.GetItem(getInput)
+	result, err := r.getItem(ctx, getInput)
 	if err != nil {
-		return false, fmt.Errorf("Query API call failed: %s", err)
+		return false, wrapAWSError(fmt.Errorf("Query API call failed: %w", err))
 	}
 
 	// Return result.
 	return len(result.Item) > 0, nil
 }
 
-func (r *migrationRepo) CreateMigrationRecord(migrationRecord domain.MigrationRecord) error {
-
-	// Marshal Go value type to a map of AttributeValues.
-	items := map[string]*awsDynamodb.AttributeValue{
-		fieldVersion: {S: aws.String(migrationRecord.Version.ID())},
-		fieldName:    {S: aws.String(migrationRecord.Name)},
-		fieldMetadata: {M: map[string]*awsDynamodb.AttributeValue{
-			fieldStartTime:     {N: aws.String(strconv.Itoa(int(migrationRecord.Metadata.StartTime)))},
-			fieldExecutionTime: {N: aws.String(strconv.Itoa(int(migrationRecord.Metadata.ExecutionTime)))},
-		}},
-	}
-
+func (r *migrationRepo) CreateMigrationRecord(ctx context.Context, migrationRecord domain.MigrationRecord) error {
 	transaction := &awsDynamodb.TransactWriteItemsInput{
 		TransactItems: []*awsDynamodb.TransactWriteItem{
 			{
@@ -160,31 +318,606 @@ func (r *migrationRepo) CreateMigrationRecord(migrationRecord domain.MigrationRe
 					ExpressionAttributeNames: map[string]*string{
 						"#pk": aws.String(fieldVersion),
 					},
-					Item: items,
+					Item: marshalMigrationRecord(migrationRecord),
 				},
 			},
 		},
 	}
 
 	// Run transaction.
-	req, _ := r.db.TransactWriteItemsRequest(transaction)
-	if err := req.Send(); err != nil {
-		return err
+	if _, err := r.transactWriteItems(ctx, transaction); err != nil {
+		return wrapAWSError(err)
 	}
 	return nil
 }
 
-func (r *migrationRepo) ExecuteQueries(queries []*domain.DynamoDBQuery) error {
+func (r *migrationRepo) GetMigrationRecord(ctx context.Context, ver domain.Version) (*domain.MigrationRecord, error) {
+	result, err := r.getItem(ctx, &awsDynamodb.GetItemInput{
+		TableName:      aws.String(r.migrationsTable),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*awsDynamodb.AttributeValue{
+			fieldVersion: {
+				S: aws.String(ver.ID()),
+			},
+		},
+	})
+	if err != nil {
+		return nil, wrapAWSError(fmt.Errorf("Query API call failed: %w", err))
+	}
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+	return unmarshalMigrationRecord(result.Item)
+}
+
+func (r *migrationRepo) GetMigrationRecords(ctx context.Context) ([]*domain.MigrationRecord, error) {
+	var (
+		records    []*domain.MigrationRecord
+		processErr error
+	)
+	estimated := ratelimit.Units{r.migrationsTable: strongReadUnits}
+	if err := r.readLimiter.Wait(ctx, estimated); err != nil {
+		return nil, err
+	}
+	err := r.db.ScanPagesWithContext(ctx, &awsDynamodb.ScanInput{
+		TableName:              aws.String(r.migrationsTable),
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+		FilterExpression:       aws.String("#pk <> :lock"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String(fieldVersion),
+		},
+		ExpressionAttributeValues: map[string]*awsDynamodb.AttributeValue{
+			":lock": {S: aws.String(lockID)},
+		},
+	}, func(page *awsDynamodb.ScanOutput, lastPage bool) bool {
+
+		// Pay the consumed capacity before the next page.
+		if page.ConsumedCapacity != nil {
+			r.readLimiter.Adjust(estimated, consumedUnits([]*awsDynamodb.ConsumedCapacity{page.ConsumedCapacity}))
+			meterReads(ctx, page.ConsumedCapacity)
+			estimated = ratelimit.Units{}
+		}
+		if err := r.readLimiter.Wait(ctx, estimated); err != nil {
+			processErr = err
+			return false
+		}
+		for _, item := range page.Items {
+			record, err := unmarshalMigrationRecord(item)
+			if err != nil {
+				processErr = err
+				return false
+			}
+			records = append(records, record)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, wrapAWSError(fmt.Errorf("Scan API call failed: %w", err))
+	}
+	if processErr != nil {
+		return nil, processErr
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Version.Less(records[j].Version)
+	})
+	return records, nil
+}
+
+func (r *migrationRepo) UpdateMigrationRecord(ctx context.Context, migrationRecord domain.MigrationRecord) error {
+	err := r.retryer.Do(ctx, "PutItem", func() error {
+		output, err := r.db.PutItemWithContext(ctx, &awsDynamodb.PutItemInput{
+			TableName:           aws.String(r.migrationsTable),
+			ConditionExpression: aws.String("attribute_exists(#pk)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#pk": aws.String(fieldVersion),
+			},
+			Item:                   marshalMigrationRecord(migrationRecord),
+			ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err == nil {
+			meterWrites(ctx, output.ConsumedCapacity)
+		}
+		return err
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsDynamodb.ErrCodeConditionalCheckFailedException {
+		return domain.Errorf(domain.ErrorKindMigrationState, "Migration record does not exist: %s", migrationRecord.Version)
+	}
+	return wrapAWSError(err)
+}
+
+func (r *migrationRepo) DeleteMigrationRecord(ctx context.Context, ver domain.Version) error {
+	err := r.retryer.Do(ctx, "DeleteItem", func() error {
+		output, err := r.db.DeleteItemWithContext(ctx, &awsDynamodb.DeleteItemInput{
+			TableName:           aws.String(r.migrationsTable),
+			ConditionExpression: aws.String("attribute_exists(#pk)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#pk": aws.String(fieldVersion),
+			},
+			Key: map[string]*awsDynamodb.AttributeValue{
+				fieldVersion: {
+					S: aws.String(ver.ID()),
+				},
+			},
+			ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err == nil {
+			meterWrites(ctx, output.ConsumedCapacity)
+		}
+		return err
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsDynamodb.ErrCodeConditionalCheckFailedException {
+		return domain.Errorf(domain.ErrorKindMigrationState, "Migration record does not exist: %s", ver)
+	}
+	return wrapAWSError(err)
+}
+
+// AcquireLock - puts the lock unless another owner holds it. The put is retried if the lock is released
+// between the failed put and reading the holder.
+func (r *migrationRepo) AcquireLock(ctx context.Context, lock domain.Lock) error {
+	err := r.putLock(ctx, lock)
+	for attempt := 1; errors.Is(err, errLockReleased) && attempt < maxLockAttempts; attempt++ {
+		err = r.putLock(ctx, lock)
+	}
+	if errors.Is(err, errLockReleased) {
+		return domain.Errorf(domain.ErrorKindLockHeld, "Migrations lock is taken and released by other runs, try again")
+	}
+	return err
+}
+
+// putLock - conditionally puts the lock, returns LockHeldError if another owner holds it
+// or errLockReleased if the holder released it after the put failed.
+func (r *migrationRepo) putLock(ctx context.Context, lock domain.Lock) error {
+	output, err := r.db.PutItemWithContext(ctx, &awsDynamodb.PutItemInput{
+		TableName:           aws.String(r.migrationsTable),
+		ConditionExpression: aws.String("attribute_not_exists(#pk) OR #expiresAt < :now OR #owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk":        aws.String(fieldVersion),
+			"#expiresAt": aws.String(fieldExpiresAt),
+			"#owner":     aws.String(fieldOwner),
+		},
+		ExpressionAttributeValues: map[string]*awsDynamodb.AttributeValue{
+			":now":   {N: aws.String(strconv.FormatInt(lock.AcquiredAt, 10))},
+			":owner": {S: aws.String(lock.Owner)},
+		},
+		Item:                   marshalLock(lock),
+		ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err == nil {
+		meterWrites(ctx, output.ConsumedCapacity)
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsDynamodb.ErrCodeConditionalCheckFailedException {
+		result, err := r.getItem(ctx, &awsDynamodb.GetItemInput{
+			TableName:      aws.String(r.migrationsTable),
+			ConsistentRead: aws.Bool(true),
+			Key:            lockKey(),
+		})
+		if err != nil {
+			return wrapAWSError(fmt.Errorf("Query API call failed: %w", err))
+		}
+		if len(result.Item) == 0 {
+			return errLockReleased
+		}
+		held, err := unmarshalLock(result.Item)
+		if err != nil {
+			return err
+		}
+		return &domain.LockHeldError{Lock: held}
+	}
+	return wrapAWSError(err)
+}
+
+func (r *migrationRepo) ReleaseLock(ctx context.Context, owner string) error {
+	output, err := r.db.DeleteItemWithContext(ctx, &awsDynamodb.DeleteItemInput{
+		TableName:           aws.String(r.migrationsTable),
+		Key:                 lockKey(),
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String(fieldOwner),
+		},
+		ExpressionAttributeValues: map[string]*awsDynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+		},
+		ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err == nil {
+		meterWrites(ctx, output.ConsumedCapacity)
+	}
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsDynamodb.ErrCodeConditionalCheckFailedException {
+		return domain.Errorf(domain.ErrorKindLockHeld, "Migrations lock is not held by %s", owner)
+	}
+	return wrapAWSError(err)
+}
+
+func (r *migrationRepo) RefreshLock(ctx context.Context, lock domain.Lock) error {
+	err := r.retryer.Do(ctx, "UpdateItem", func() error {
+		output, err := r.db.UpdateItemWithContext(ctx, &awsDynamodb.UpdateItemInput{
+			TableName:           aws.String(r.migrationsTable),
+			Key:                 lockKey(),
+			UpdateExpression:    aws.String("SET #expiresAt = :expiresAt"),
+			ConditionExpression: aws.String("#owner = :owner"),
+			ExpressionAttributeNames: map[string]*string{
+				"#expiresAt": aws.String(fieldExpiresAt),
+				"#owner":     aws.String(fieldOwner),
+			},
+			ExpressionAttributeValues: map[string]*awsDynamodb.AttributeValue{
+				":expiresAt": {N: aws.String(strconv.FormatInt(lock.ExpiresAt, 10))},
+				":owner":     {S: aws.String(lock.Owner)},
+			},
+			ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err == nil {
+			meterWrites(ctx, output.ConsumedCapacity)
+		}
+		return err
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsDynamodb.ErrCodeConditionalCheckFailedException {
+		return domain.Errorf(domain.ErrorKindLockHeld, "Migrations lock is no longer held by %s", lock.Owner)
+	}
+	return wrapAWSError(err)
+}
+
+func (r *migrationRepo) DeleteLock(ctx context.Context) (*domain.Lock, error) {
+	result, err := r.db.DeleteItemWithContext(ctx, &awsDynamodb.DeleteItemInput{
+		TableName:              aws.String(r.migrationsTable),
+		Key:                    lockKey(),
+		ReturnValues:           aws.String(awsDynamodb.ReturnValueAllOld),
+		ReturnConsumedCapacity: aws.String(awsDynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return nil, wrapAWSError(err)
+	}
+	meterWrites(ctx, result.ConsumedCapacity)
+	if len(result.Attributes) == 0 {
+		return nil, nil
+	}
+	lock, err := unmarshalLock(result.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func lockKey() map[string]*awsDynamodb.AttributeValue {
+	return map[string]*awsDynamodb.AttributeValue{
+		fieldVersion: {S: aws.String(lockID)},
+	}
+}
+
+func marshalLock(lock domain.Lock) map[string]*awsDynamodb.AttributeValue {
+	return map[string]*awsDynamodb.AttributeValue{
+		fieldVersion:    {S: aws.String(lockID)},
+		fieldOwner:      {S: aws.String(lock.Owner)},
+		fieldAcquiredAt: {N: aws.String(strconv.FormatInt(lock.AcquiredAt, 10))},
+		fieldExpiresAt:  {N: aws.String(strconv.FormatInt(lock.ExpiresAt, 10))},
+	}
+}
+
+func unmarshalLock(item map[string]*awsDynamodb.AttributeValue) (domain.Lock, error) {
+	lock := domain.Lock{
+		Owner: stringValue(item[fieldOwner]),
+	}
+	var err error
+	if lock.AcquiredAt, err = int64Value(item[fieldAcquiredAt]); err != nil {
+		return lock, err
+	}
+	if lock.ExpiresAt, err = int64Value(item[fieldExpiresAt]); err != nil {
+		return lock, err
+	}
+	return lock, nil
+}
+
+func marshalMigrationRecord(migrationRecord domain.MigrationRecord) map[string]*awsDynamodb.AttributeValue {
+	item := map[string]*awsDynamodb.AttributeValue{
+		fieldVersion:  {S: aws.String(migrationRecord.Version.ID())},
+		fieldName:     {S: aws.String(migrationRecord.Name)},
+		fieldStatus:   {S: aws.String(string(migrationRecord.GetStatus()))},
+		fieldMetadata: {M: marshalMetadata(migrationRecord.Metadata)},
+	}
+	if len(migrationRecord.Error) > 0 {
+		item[fieldError] = &awsDynamodb.AttributeValue{S: aws.String(migrationRecord.Error)}
+	}
+	if migrationRecord.Attempts > 0 {
+		item[fieldAttempts] = &awsDynamodb.AttributeValue{N: aws.String(strconv.Itoa(migrationRecord.Attempts))}
+	}
+	if len(migrationRecord.Checksum) > 0 {
+		item[fieldChecksum] = &awsDynamodb.AttributeValue{S: aws.String(migrationRecord.Checksum)}
+	}
+	if migrationRecord.Baselined {
+		item[fieldBaselined] = &awsDynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	}
+	return item
+}
+
+func unmarshalMigrationRecord(item map[string]*awsDynamodb.AttributeValue) (*domain.MigrationRecord, error) {
+	ver, err := domain.ParseVersion(stringValue(item[fieldVersion]))
+	if err != nil {
+		return nil, err
+	}
+	record := &domain.MigrationRecord{
+		Version:   ver,
+		Name:      stringValue(item[fieldName]),
+		Checksum:  stringValue(item[fieldChecksum]),
+		Status:    domain.MigrationStatus(stringValue(item[fieldStatus])),
+		Error:     stringValue(item[fieldError]),
+		Baselined: item[fieldBaselined] != nil && aws.BoolValue(item[fieldBaselined].BOOL),
+	}
+	attempts, err := int64Value(item[fieldAttempts])
+	if err != nil {
+		return nil, err
+	}
+	record.Attempts = int(attempts)
+	if metadata := item[fieldMetadata]; metadata != nil {
+		if record.Metadata, err = unmarshalMetadata(metadata.M); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+func marshalMetadata(metadata domain.Metadata) map[string]*awsDynamodb.AttributeValue {
+	m := map[string]*awsDynamodb.AttributeValue{
+		fieldStartTime:     {N: aws.String(strconv.FormatInt(metadata.StartTime, 10))},
+		fieldExecutionTime: {N: aws.String(strconv.FormatInt(metadata.ExecutionTime, 10))},
+		fieldStartTimeMs:   {N: aws.String(strconv.FormatInt(metadata.StartTimeMs, 10))},
+		fieldDurationMs:    {N: aws.String(strconv.FormatInt(metadata.DurationMs, 10))},
+		fieldTablesCreated: {N: aws.String(strconv.Itoa(metadata.TablesCreated))},
+		fieldItemsWritten:  {N: aws.String(strconv.Itoa(metadata.ItemsWritten))},
+		fieldEstimatedCost: {N: aws.String(formatFloat(metadata.EstimatedCost))},
+	}
+	if len(metadata.ConsumedCapacity) > 0 {
+		m[fieldCapacity] = &awsDynamodb.AttributeValue{M: marshalCapacity(metadata.ConsumedCapacity)}
+	}
+	if len(metadata.Backups) > 0 {
+		m[fieldBackups] = &awsDynamodb.AttributeValue{L: marshalBackups(metadata.Backups)}
+	}
+	for field, value := range map[string]string{
+		fieldAppVersion: metadata.AppVersion,
+		fieldHostname:   metadata.Hostname,
+		fieldRunner:     metadata.Runner,
+	} {
+		if len(value) > 0 {
+			m[field] = &awsDynamodb.AttributeValue{S: aws.String(value)}
+		}
+	}
+	return m
+}
+
+func unmarshalMetadata(m map[string]*awsDynamodb.AttributeValue) (domain.Metadata, error) {
+	metadata := domain.Metadata{
+		AppVersion: stringValue(m[fieldAppVersion]),
+		Hostname:   stringValue(m[fieldHostname]),
+		Runner:     stringValue(m[fieldRunner]),
+	}
+	var (
+		tablesCreated, itemsWritten int64
+		err                         error
+	)
+	for field, value := range map[string]*int64{
+		fieldStartTime:     &metadata.StartTime,
+		fieldExecutionTime: &metadata.ExecutionTime,
+		fieldStartTimeMs:   &metadata.StartTimeMs,
+		fieldDurationMs:    &metadata.DurationMs,
+		fieldTablesCreated: &tablesCreated,
+		fieldItemsWritten:  &itemsWritten,
+	} {
+		if *value, err = int64Value(m[field]); err != nil {
+			return metadata, fmt.Errorf("Cannot parse %s: %v", field, err)
+		}
+	}
+	metadata.TablesCreated = int(tablesCreated)
+	metadata.ItemsWritten = int(itemsWritten)
+	if metadata.EstimatedCost, err = float64Value(m[fieldEstimatedCost]); err != nil {
+		return metadata, fmt.Errorf("Cannot parse %s: %v", fieldEstimatedCost, err)
+	}
+	if attr := m[fieldCapacity]; attr != nil {
+		if metadata.ConsumedCapacity, err = unmarshalCapacity(attr.M); err != nil {
+			return metadata, fmt.Errorf("Cannot parse %s: %v", fieldCapacity, err)
+		}
+	}
+	if attr := m[fieldBackups]; attr != nil {
+		metadata.Backups = unmarshalBackups(attr.L)
+	}
+	return metadata, nil
+}
+
+// marshalBackups - marshals the backups as a list of maps with the table, the name and the ARN.
+func marshalBackups(backups []domain.TableBackup) []*awsDynamodb.AttributeValue {
+	list := make([]*awsDynamodb.AttributeValue, len(backups))
+	for i, backup := range backups {
+		list[i] = &awsDynamodb.AttributeValue{M: map[string]*awsDynamodb.AttributeValue{
+			fieldTableName:  {S: aws.String(backup.TableName)},
+			fieldBackupName: {S: aws.String(backup.BackupName)},
+			fieldBackupArn:  {S: aws.String(backup.BackupArn)},
+		}}
+	}
+	return list
+}
+
+func unmarshalBackups(list []*awsDynamodb.AttributeValue) []domain.TableBackup {
+	backups := make([]domain.TableBackup, 0, len(list))
+	for _, attr := range list {
+		if attr == nil {
+			continue
+		}
+		backups = append(backups, domain.TableBackup{
+			TableName:  stringValue(attr.M[fieldTableName]),
+			BackupName: stringValue(attr.M[fieldBackupName]),
+			BackupArn:  stringValue(attr.M[fieldBackupArn]),
+		})
+	}
+	return backups
+}
+
+// marshalCapacity - marshals the consumed capacity as a map of tables to read and write units.
+func marshalCapacity(consumed domain.ConsumedCapacity) map[string]*awsDynamodb.AttributeValue {
+	m := make(map[string]*awsDynamodb.AttributeValue, len(consumed))
+	for table, capacity := range consumed {
+		m[table] = &awsDynamodb.AttributeValue{M: map[string]*awsDynamodb.AttributeValue{
+			fieldReadUnits:  {N: aws.String(formatFloat(capacity.ReadUnits))},
+			fieldWriteUnits: {N: aws.String(formatFloat(capacity.WriteUnits))},
+		}}
+	}
+	return m
+}
+
+func unmarshalCapacity(m map[string]*awsDynamodb.AttributeValue) (domain.ConsumedCapacity, error) {
+	consumed := make(domain.ConsumedCapacity, len(m))
+	for table, attr := range m {
+		var (
+			capacity domain.TableCapacity
+			err      error
+		)
+		if attr == nil {
+			continue
+		}
+		if capacity.ReadUnits, err = float64Value(attr.M[fieldReadUnits]); err != nil {
+			return nil, err
+		}
+		if capacity.WriteUnits, err = float64Value(attr.M[fieldWriteUnits]); err != nil {
+			return nil, err
+		}
+		consumed[table] = capacity
+	}
+	return consumed, nil
+}
+
+func stringValue(attr *awsDynamodb.AttributeValue) string {
+	if attr == nil {
+		return ""
+	}
+	return aws.StringValue(attr.S)
+}
+
+func int64Value(attr *awsDynamodb.AttributeValue) (int64, error) {
+	if attr == nil || attr.N == nil {
+		return 0, nil
+	}
+	return strconv.ParseInt(*attr.N, 10, 64)
+}
+
+func float64Value(attr *awsDynamodb.AttributeValue) (float64, error) {
+	if attr == nil || attr.N == nil {
+		return 0, nil
+	}
+	return strconv.ParseFloat(*attr.N, 64)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Capacity units estimated before a request, corrected by the consumed capacity.
+const (
+	// transactWriteUnits - units of a transactional write of an item up to 1 KB.
+	transactWriteUnits = 2
+	// strongReadUnits - units of a strongly consistent read of an item up to 4 KB.
+	strongReadUnits = 1
+)
+
+// estimateTransactionUnits - returns write units of the transaction items by table.
+func estimateTransactionUnits(items []*awsDynamodb.TransactWriteItem) ratelimit.Units {
+	units := make(ratelimit.Units)
+	for _, item := range items {
+		switch {
+		case item.Put != nil:
+			units[aws.StringValue(item.Put.TableName)] += transactWriteUnits
+		case item.Delete != nil:
+			units[aws.StringValue(item.Delete.TableName)] += transactWriteUnits
+		case item.Update != nil:
+			units[aws.StringValue(item.Update.TableName)] += transactWriteUnits
+		}
+	}
+	return units
+}
+
+// consumedUnits - returns consumed capacity units by table.
+func consumedUnits(consumed []*awsDynamodb.ConsumedCapacity) ratelimit.Units {
+	units := make(ratelimit.Units, len(consumed))
+	for _, capacity := range consumed {
+		units[aws.StringValue(capacity.TableName)] += aws.Float64Value(capacity.CapacityUnits)
+	}
+	return units
+}
+
+// queryAttributes - returns span attributes of the tables and the number of items of the queries.
+func queryAttributes(queries []*domain.DynamoDBQuery) []attribute.KeyValue {
 	var (
+		tables []string
+		items  int
+		seen   = make(map[string]bool)
+	)
+	for _, q := range queries {
+		if !seen[q.TableName] {
+			seen[q.TableName] = true
+			tables = append(tables, q.TableName)
+		}
+		items += len(q.Data)
+	}
+	return []attribute.KeyValue{
+		tracing.AttrTables.StringSlice(tables),
+		attribute.Int("migration.items", items),
+	}
+}
+
+// meterReads - adds the consumed read capacity to the capacity meter of the context.
+func meterReads(ctx context.Context, consumed ...*awsDynamodb.ConsumedCapacity) {
+	meter := domain.CapacityMeterFrom(ctx)
+	for _, capacity := range consumed {
+		if capacity != nil {
+			meter.Add(aws.StringValue(capacity.TableName), domain.TableCapacity{ReadUnits: aws.Float64Value(capacity.CapacityUnits)})
+		}
+	}
+}
+
+// meterWrites - adds the consumed write capacity to the capacity meter of the context.
+func meterWrites(ctx context.Context, consumed ...*awsDynamodb.ConsumedCapacity) {
+	meter := domain.CapacityMeterFrom(ctx)
+	for _, capacity := range consumed {
+		if capacity != nil {
+			meter.Add(aws.StringValue(capacity.TableName), domain.TableCapacity{WriteUnits: aws.Float64Value(capacity.CapacityUnits)})
+		}
+	}
+}
+
+func (r *migrationRepo) ExecuteQueries(ctx context.Context, queries []*domain.DynamoDBQuery) (domain.ExecutionResult, error) {
+	ctx, span := r.tracer.Start(ctx, "ExecuteQueries", trace.WithAttributes(queryAttributes(queries)...))
+	result, err := r.executeQueries(ctx, queries)
+	span.SetAttributes(
+		tracing.AttrTablesCreated.Int(result.TablesCreated),
+		tracing.AttrItemsWritten.Int(result.ItemsWritten),
+	)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *migrationRepo) executeQueries(ctx context.Context, queries []*domain.DynamoDBQuery) (domain.ExecutionResult, error) {
+	var (
+		result            domain.ExecutionResult
 		createTableInputs = make([]*awsDynamodb.CreateTableInput, 0)
+		ttlUpdates        = make([]timeToLiveUpdate, 0)
+		streamUpdates     = make([]streamUpdate, 0)
+		settingsUpdates   = make([]settingsUpdate, 0)
 		dataTransactions  = make([]*awsDynamodb.TransactWriteItem, 0)
 	)
 	for _, q := range queries {
 		if err := q.Validate(); err != nil {
-			return err
+			return result, err
 		}
 		for _, schema := range q.Schema {
-			createTableInputs = append(createTableInputs, &awsDynamodb.CreateTableInput{
+			if schema.TTL != nil {
+				ttlUpdates = append(ttlUpdates, timeToLiveUpdate{tableName: q.TableName, ttl: schema.TTL})
+			}
+			if schema.StreamSpecification != nil {
+				streamUpdates = append(streamUpdates, streamUpdate{tableName: q.TableName, spec: schema.StreamSpecification})
+			}
+			if hasSettings(schema) {
+				settingsUpdates = append(settingsUpdates, settingsUpdate{tableName: q.TableName, schema: schema})
+			}
+			if !schema.CreatesTable() {
+				continue
+			}
+			createTableInput := &awsDynamodb.CreateTableInput{
 				AttributeDefinitions: helpers.ConvertToAWSAttributeDefinitions(schema.AttributeDefinitions),
 				KeySchema:            helpers.ConvertToAWSKeySchemaElement(schema.KeySchema),
 				ProvisionedThroughput: &awsDynamodb.ProvisionedThroughput{
@@ -192,16 +925,30 @@ func (r *migrationRepo) ExecuteQueries(queries []*domain.DynamoDBQuery) error {
 					WriteCapacityUnits: aws.Int64(10),
 				},
 				TableName: aws.String(q.TableName),
-			})
+			}
+			if schema.StreamSpecification != nil {
+				createTableInput.StreamSpecification = awsStreamSpecification(schema.StreamSpecification)
+			}
+			if len(schema.Tags) > 0 {
+				createTableInput.Tags = awsTags(schema.Tags)
+			}
+			if schema.SSESpecification != nil {
+				createTableInput.SSESpecification = awsSSESpecification(schema.SSESpecification)
+			}
+			createTableInput.DeletionProtectionEnabled = schema.DeletionProtection
+			if len(schema.TableClass) > 0 {
+				createTableInput.TableClass = aws.String(schema.TableClass)
+			}
+			createTableInputs = append(createTableInputs, createTableInput)
 		}
 		for _, data := range q.Data {
 			// Marshal Go value type to a map of AttributeValues.
 			item, err := dynamodbattribute.MarshalMap(data)
 			if err != nil {
-				return err
+				return result, domain.NewError(domain.ErrorKindValidation, err)
 			}
 			if len(item) == 0 {
-				return fmt.Errorf("Items cannot be empty for %v", q.TableName)
+				return result, domain.Errorf(domain.ErrorKindValidation, "Items cannot be empty for %v", q.TableName)
 			}
 			dataTransactions = append(dataTransactions, &awsDynamodb.TransactWriteItem{
 				Put: &awsDynamodb.Put{
@@ -214,34 +961,225 @@ func (r *migrationRepo) ExecuteQueries(queries []*domain.DynamoDBQuery) error {
 
 	// Create tables.
 	for _, createTableInput := range createTableInputs {
-		isTableExist, err := r.isTableExist(*createTableInput.TableName)
+		isTableExist, err := r.isTableExist(ctx, *createTableInput.TableName)
 		if err != nil {
-			return err
+			return result, wrapAWSError(err)
 		}
 		if isTableExist {
-			log.Printf("Skipping a table %s because the table already exist\n", *createTableInput.TableName)
+			r.logger.Info("Skipping a table because the table already exist", domain.F("table", *createTableInput.TableName))
 			continue
 		}
-		_, err = r.db.CreateTable(createTableInput)
+		err = r.retryer.Do(ctx, "CreateTable", func() error {
+			_, err := r.db.CreateTableWithContext(ctx, createTableInput)
+			return err
+		})
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() != awsErrorResourceInUse {
-				return aerr
+				return result, wrapAWSError(aerr)
 			}
+		} else if err == nil {
+			result.TablesCreated++
 		}
 		// Wait for table.
-		if err := r.db.WaitUntilTableExists(&awsDynamodb.DescribeTableInput{TableName: createTableInput.TableName}); err != nil {
-			return err
+		if err := r.waitUntilTableExists(ctx, *createTableInput.TableName); err != nil {
+			return result, wrapAWSError(err)
+		}
+	}
+
+	// Apply Time to Live to created and existing tables.
+	for _, update := range ttlUpdates {
+		if err := r.updateTimeToLive(ctx, update); err != nil {
+			return result, wrapAWSError(err)
+		}
+	}
+
+	// Update streams of existing tables, created tables already have them.
+	for _, update := range streamUpdates {
+		streamArn, err := r.updateStream(ctx, update)
+		if err != nil {
+			return result, wrapAWSError(err)
+		}
+		if len(streamArn) > 0 {
+			result.Streams = append(result.Streams, domain.TableStream{TableName: update.tableName, StreamArn: streamArn})
+		}
+	}
+
+	// Reconcile the settings of existing tables, point-in-time recovery cannot be set when a table is created.
+	for _, update := range settingsUpdates {
+		if err := r.updateSettings(ctx, update); err != nil {
+			return result, wrapAWSError(err)
 		}
 	}
 
 	// Run data migrations if present.
 	if len(dataTransactions) > 0 {
-		req, _ := r.db.TransactWriteItemsRequest(&awsDynamodb.TransactWriteItemsInput{
+		_, err := r.transactWriteItems(ctx, &awsDynamodb.TransactWriteItemsInput{
 			TransactItems: dataTransactions,
 		})
-		if err := req.Send(); err != nil {
+		if err != nil {
+			return result, wrapAWSError(err)
+		}
+		result.ItemsWritten = len(dataTransactions)
+	}
+	return result, nil
+}
+
+func (r *migrationRepo) RevertQueries(ctx context.Context, queries []*domain.DynamoDBQuery, dropTables bool) (domain.ExecutionResult, error) {
+	ctx, span := r.tracer.Start(ctx, "RevertQueries", trace.WithAttributes(
+		append(queryAttributes(queries), attribute.Bool("migration.drop_tables", dropTables))...))
+	result, err := r.revertQueries(ctx, queries, dropTables)
+	span.SetAttributes(
+		tracing.AttrTablesDeleted.Int(result.TablesDeleted),
+		tracing.AttrItemsDeleted.Int(result.ItemsDeleted),
+	)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *migrationRepo) revertQueries(ctx context.Context, queries []*domain.DynamoDBQuery, dropTables bool) (domain.ExecutionResult, error) {
+	var (
+		result             domain.ExecutionResult
+		dropTableNames     = make([]string, 0)
+		droppedTables      = make(map[string]bool)
+		deleteTransactions = make([]*awsDynamodb.TransactWriteItem, 0)
+	)
+	for _, q := range queries {
+		if err := q.Validate(); err != nil {
+			return result, err
+		}
+		if dropTables && q.CreatesTable() && !droppedTables[q.TableName] {
+			dropTableNames = append(dropTableNames, q.TableName)
+			droppedTables[q.TableName] = true
+		}
+	}
+	for _, q := range queries {
+		if len(q.Data) == 0 || droppedTables[q.TableName] {
+			continue
+		}
+		keyNames, err := r.getKeyAttributeNames(ctx, q)
+		if err != nil {
+			return result, wrapAWSError(err)
+		}
+		for _, data := range q.Data {
+			item, err := dynamodbattribute.MarshalMap(data)
+			if err != nil {
+				return result, domain.NewError(domain.ErrorKindValidation, err)
+			}
+			key := make(map[string]*awsDynamodb.AttributeValue, len(keyNames))
+			for _, name := range keyNames {
+				value, ok := item[name]
+				if !ok {
+					return result, domain.Errorf(domain.ErrorKindValidation, "Key attribute %s is missing in the item for %v", name, q.TableName)
+				}
+				key[name] = value
+			}
+			deleteTransactions = append(deleteTransactions, &awsDynamodb.TransactWriteItem{
+				Delete: &awsDynamodb.Delete{
+					TableName: aws.String(q.TableName),
+					Key:       key,
+				},
+			})
+		}
+	}
+
+	// Delete items if present.
+	if len(deleteTransactions) > 0 {
+		_, err := r.transactWriteItems(ctx, &awsDynamodb.TransactWriteItemsInput{
+			TransactItems: deleteTransactions,
+		})
+		if err != nil {
+			return result, wrapAWSError(err)
+		}
+		result.ItemsDeleted = len(deleteTransactions)
+	}
+
+	// Delete tables in the reverse order.
+	for i := len(dropTableNames) - 1; i >= 0; i-- {
+		tableName := dropTableNames[i]
+		err := r.retryer.Do(ctx, "DeleteTable", func() error {
+			_, err := r.db.DeleteTableWithContext(ctx, &awsDynamodb.DeleteTableInput{TableName: aws.String(tableName)})
 			return err
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awsErrorResourceNotFound {
+			r.logger.Info("Skipping a table because the table does not exist", domain.F("table", tableName))
+			continue
+		} else if err != nil {
+			return result, wrapAWSError(err)
+		}
+		if err := r.waitUntilTableNotExists(ctx, tableName); err != nil {
+			return result, wrapAWSError(err)
 		}
+		result.TablesDeleted++
 	}
-	return nil
+	return result, nil
+}
+
+// getKeyAttributeNames - returns key attribute names from the query schema or the existing table.
+func (r *migrationRepo) getKeyAttributeNames(ctx context.Context, q *domain.DynamoDBQuery) ([]string, error) {
+	var keyNames []string
+	for _, schema := range q.Schema {
+		for _, key := range schema.KeySchema {
+			keyNames = append(keyNames, key.AttributeName)
+		}
+	}
+	if len(keyNames) > 0 {
+		return keyNames, nil
+	}
+	output, err := r.db.DescribeTableWithContext(ctx, &awsDynamodb.DescribeTableInput{
+		TableName: aws.String(q.TableName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range output.Table.KeySchema {
+		keyNames = append(keyNames, aws.StringValue(key.AttributeName))
+	}
+	return keyNames, nil
+}
+
+// awsErrorCodes - returns the AWS error code and the cancellation reason codes of a transaction.
+func awsErrorCodes(err error) []string {
+	var codes []string
+	var canceledErr *awsDynamodb.TransactionCanceledException
+	if errors.As(err, &canceledErr) {
+		for _, reason := range canceledErr.CancellationReasons {
+			codes = append(codes, aws.StringValue(reason.Code))
+		}
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		codes = append(codes, aerr.Code())
+	}
+	return codes
+}
+
+// wrapAWSError - classifies AWS errors, other errors are returned as is.
+func wrapAWSError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var kindErr *domain.Error
+	if errors.As(err, &kindErr) {
+		return err
+	}
+	var canceledErr *awsDynamodb.TransactionCanceledException
+	if errors.As(err, &canceledErr) {
+		for _, reason := range canceledErr.CancellationReasons {
+			if aws.StringValue(reason.Code) == awsReasonTransactionConflict {
+				return domain.NewError(domain.ErrorKindTransactionConflict, err)
+			}
+		}
+		return domain.NewError(domain.ErrorKindAWS, err)
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		if aerr.Code() == request.CanceledErrorCode {
+			return domain.NewError(domain.ErrorKindCanceled, err)
+		}
+		if aerr.Code() == awsDynamodb.ErrCodeTransactionConflictException {
+			return domain.NewError(domain.ErrorKindTransactionConflict, err)
+		}
+		return domain.NewError(domain.ErrorKindAWS, err)
+	}
+	return err
 }
@@ -1,8 +1,11 @@
 package dynamodb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"dynamodb.data-migration/internal/domain"
 	aws "github.com/aws/aws-sdk-go/aws"
@@ -30,7 +33,7 @@ func TestMigrationRecords(t *testing.T) {
 	}
 	for _, ver := range versions {
 		// Migration record should not exist.
-		isExist, err := testMigrationRepository.IsMigrationRecordExist(ver)
+		isExist, err := testMigrationRepository.IsMigrationRecordExist(context.Background(), ver)
 		if err != nil {
 			t.Errorf("unexpected err: %v", err)
 		}
@@ -39,7 +42,7 @@ func TestMigrationRecords(t *testing.T) {
 		}
 
 		// Create a new migration record.
-		err = testMigrationRepository.CreateMigrationRecord(domain.MigrationRecord{
+		err = testMigrationRepository.CreateMigrationRecord(context.Background(), domain.MigrationRecord{
 			Version: ver,
 			Name:    "test",
 			Metadata: domain.Metadata{
@@ -52,7 +55,7 @@ func TestMigrationRecords(t *testing.T) {
 		}
 
 		// Check if the migration record created.
-		isExist, err = testMigrationRepository.IsMigrationRecordExist(ver)
+		isExist, err = testMigrationRepository.IsMigrationRecordExist(context.Background(), ver)
 		if err != nil {
 			t.Errorf("unexpected err: %v", err)
 		}
@@ -62,6 +65,162 @@ func TestMigrationRecords(t *testing.T) {
 	}
 }
 
+func TestMigrationRecordUpdates(t *testing.T) {
+	ver := domain.Version{
+		Major: 2,
+		Minor: 0,
+		Patch: 1,
+	}
+	record := domain.MigrationRecord{
+		Version:  ver,
+		Name:     "2.0.1_test.json",
+		Checksum: "checksum",
+		Status:   domain.MigrationStatusFailed,
+		Metadata: domain.Metadata{
+			StartTime:     123,
+			ExecutionTime: 1,
+			ConsumedCapacity: domain.ConsumedCapacity{
+				"users": {ReadUnits: 1, WriteUnits: 4.5},
+			},
+			EstimatedCost: 0.0000059,
+			Backups: []domain.TableBackup{{
+				TableName:  "users",
+				BackupName: "users-2.0.1-20210901T100000Z",
+				BackupArn:  "arn:aws:dynamodb:eu-west-1:123456789012:table/users/backup/01630490400000-abcdef12",
+			}},
+		},
+	}
+
+	// Records that do not exist cannot be updated or deleted.
+	if err := testMigrationRepository.UpdateMigrationRecord(context.Background(), record); err == nil {
+		t.Error("expected error but got nothing")
+	}
+	if err := testMigrationRepository.DeleteMigrationRecord(context.Background(), ver); err == nil {
+		t.Error("expected error but got nothing")
+	}
+
+	// Create and read the migration record.
+	if err := testMigrationRepository.CreateMigrationRecord(context.Background(), record); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	actual, err := testMigrationRepository.GetMigrationRecord(context.Background(), ver)
+	if err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	if diff := deep.Equal(actual, &record); diff != nil {
+		t.Errorf("actual record does not match expected: %v", diff)
+	}
+
+	// Update the migration record.
+	record.Status = domain.MigrationStatusSucceeded
+	record.Checksum = "updated"
+	if err := testMigrationRepository.UpdateMigrationRecord(context.Background(), record); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	records, err := testMigrationRepository.GetMigrationRecords(context.Background())
+	if err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	found := false
+	for _, r := range records {
+		if r.Version == ver {
+			found = true
+			if diff := deep.Equal(r, &record); diff != nil {
+				t.Errorf("actual record does not match expected: %v", diff)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("migration record must exist for %v", ver)
+	}
+
+	// Delete the migration record.
+	if err := testMigrationRepository.DeleteMigrationRecord(context.Background(), ver); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	actual, err = testMigrationRepository.GetMigrationRecord(context.Background(), ver)
+	if err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	if actual != nil {
+		t.Errorf("migration record should not exist for %v", ver)
+	}
+}
+
+func TestMigrationsLock(t *testing.T) {
+	var (
+		now    = time.Now()
+		first  = domain.NewLock("first", now, time.Hour)
+		second = domain.NewLock("second", now, time.Hour)
+	)
+
+	// Only one owner can hold the lock.
+	if err := testMigrationRepository.AcquireLock(context.Background(), first); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	err := testMigrationRepository.AcquireLock(context.Background(), second)
+	var lockErr *domain.LockHeldError
+	if !errors.As(err, &lockErr) {
+		t.Errorf("expected lock held error, actual: %v", err)
+	} else if lockErr.Lock.Owner != first.Owner {
+		t.Errorf("actual lock owner: %v does not match expected: %v", lockErr.Lock.Owner, first.Owner)
+	}
+	if err := testMigrationRepository.ReleaseLock(context.Background(), second.Owner); err == nil {
+		t.Error("expected error but got nothing")
+	}
+
+	// Only the owner can refresh the lock.
+	refreshed := first
+	refreshed.ExpiresAt = now.Add(2 * time.Hour).Unix()
+	if err := testMigrationRepository.RefreshLock(context.Background(), refreshed); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	if err := testMigrationRepository.RefreshLock(context.Background(), second); domain.ErrorKindOf(err) != domain.ErrorKindLockHeld {
+		t.Errorf("expected lock held error, actual: %v", err)
+	}
+
+	// The lock record is not a migration record.
+	records, err := testMigrationRepository.GetMigrationRecords(context.Background())
+	if err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	for _, record := range records {
+		if len(record.Name) == 0 {
+			t.Errorf("unexpected migration record: %+v", record)
+		}
+	}
+
+	// Released lock can be acquired by another owner.
+	if err := testMigrationRepository.ReleaseLock(context.Background(), first.Owner); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	if err := testMigrationRepository.AcquireLock(context.Background(), second); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+
+	// Expired lock can be acquired by another owner.
+	expired := domain.NewLock("third", now.Add(2*time.Hour), time.Hour)
+	if err := testMigrationRepository.AcquireLock(context.Background(), expired); err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+
+	// Delete the lock regardless of the owner.
+	lock, err := testMigrationRepository.DeleteLock(context.Background())
+	if err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	if lock == nil || lock.Owner != expired.Owner {
+		t.Errorf("unexpected deleted lock: %v", lock)
+	}
+	lock, err = testMigrationRepository.DeleteLock(context.Background())
+	if err != nil {
+		t.Errorf("unexpected err: %v", err)
+	}
+	if lock != nil {
+		t.Errorf("lock should not exist: %v", lock)
+	}
+}
+
 func TestExecuteQueries(t *testing.T) {
 	var (
 		db    = awsDynamodb.New(testAwsSession)
@@ -94,7 +253,7 @@ func TestExecuteQueries(t *testing.T) {
 	)
 
 	// Create table schemas with test data.
-	err := testMigrationRepository.ExecuteQueries([]*domain.DynamoDBQuery{
+	result, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{
 		{
 			TableName: "users",
 			Schema: []*domain.DynamoDBSchema{
@@ -139,6 +298,9 @@ func TestExecuteQueries(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected err: %v", err)
 	}
+	if result.TablesCreated != 2 || result.ItemsWritten != len(users)+len(roles) {
+		t.Errorf("unexpected execution result: %+v", result)
+	}
 
 	// Find created users.
 	for _, user := range users {
@@ -211,3 +373,194 @@ func TestExecuteQueries(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteQueriesTimeToLive(t *testing.T) {
+	var (
+		db       = awsDynamodb.New(testAwsSession)
+		sessions = &domain.DynamoDBQuery{
+			TableName: "sessions",
+			Schema: []*domain.DynamoDBSchema{
+				{
+					AttributeDefinitions: []*domain.DynamoDBAttributeDefinition{{AttributeName: "id", AttributeType: "S"}},
+					KeySchema:            []*domain.DynamoDBKeySchema{{AttributeName: "id", KeyType: "HASH"}},
+					TTL:                  &domain.DynamoDBTimeToLive{AttributeName: "expires_at", Enabled: true},
+				},
+			},
+		}
+		// audit - Time to Live of an existing table.
+		audit = &domain.DynamoDBQuery{
+			TableName: "sessions_audit",
+			Schema:    []*domain.DynamoDBSchema{{TTL: &domain.DynamoDBTimeToLive{AttributeName: "expires_at", Enabled: true}}},
+		}
+	)
+	_, err := db.CreateTable(&awsDynamodb.CreateTableInput{
+		TableName:             aws.String(audit.TableName),
+		AttributeDefinitions:  []*awsDynamodb.AttributeDefinition{{AttributeName: aws.String("id"), AttributeType: aws.String("S")}},
+		KeySchema:             []*awsDynamodb.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: aws.String("HASH")}},
+		ProvisionedThroughput: &awsDynamodb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(1), WriteCapacityUnits: aws.Int64(1)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// Time to Live is applied to the created and the existing table, applying it again changes nothing.
+	for i := 0; i < 2; i++ {
+		result, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{sessions, audit})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if expected := 1 - i; result.TablesCreated != expected {
+			t.Errorf("actual tables created: %d do not match expected: %d", result.TablesCreated, expected)
+		}
+	}
+	for _, table := range []string{sessions.TableName, audit.TableName} {
+		output, err := db.DescribeTimeToLive(&awsDynamodb.DescribeTimeToLiveInput{TableName: aws.String(table)})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		description := output.TimeToLiveDescription
+		if aws.StringValue(description.TimeToLiveStatus) != awsDynamodb.TimeToLiveStatusEnabled || aws.StringValue(description.AttributeName) != "expires_at" {
+			t.Errorf("unexpected Time to Live of %s: %v", table, description)
+		}
+	}
+
+	// Time to Live enabled on another attribute must be disabled first.
+	other := &domain.DynamoDBQuery{
+		TableName: audit.TableName,
+		Schema:    []*domain.DynamoDBSchema{{TTL: &domain.DynamoDBTimeToLive{AttributeName: "deleted_at", Enabled: true}}},
+	}
+	if _, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{other}); domain.ErrorKindOf(err) != domain.ErrorKindValidation {
+		t.Errorf("expected validation error, got: %v", err)
+	}
+
+	// Dropping tables keeps the existing table.
+	result, err := testMigrationRepository.RevertQueries(context.Background(), []*domain.DynamoDBQuery{sessions, audit}, true)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if result.TablesDeleted != 1 {
+		t.Errorf("only the created table must be deleted, deleted: %d", result.TablesDeleted)
+	}
+}
+
+func TestExecuteQueriesStreams(t *testing.T) {
+	var (
+		orders = &domain.DynamoDBQuery{
+			TableName: "orders",
+			Schema: []*domain.DynamoDBSchema{
+				{
+					AttributeDefinitions: []*domain.DynamoDBAttributeDefinition{{AttributeName: "id", AttributeType: "S"}},
+					KeySchema:            []*domain.DynamoDBKeySchema{{AttributeName: "id", KeyType: "HASH"}},
+					StreamSpecification:  &domain.DynamoDBStreamSpecification{Enabled: true, ViewType: domain.StreamViewTypeNewAndOldImages},
+				},
+			},
+		}
+		stream = func(enabled bool, viewType string) *domain.DynamoDBQuery {
+			return &domain.DynamoDBQuery{
+				TableName: orders.TableName,
+				Schema:    []*domain.DynamoDBSchema{{StreamSpecification: &domain.DynamoDBStreamSpecification{Enabled: enabled, ViewType: viewType}}},
+			}
+		}
+	)
+
+	// The stream is created with the table, its ARN is reported.
+	result, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{orders})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(result.Streams) != 1 || result.Streams[0].TableName != orders.TableName || len(result.Streams[0].StreamArn) == 0 {
+		t.Errorf("unexpected streams: %+v", result.Streams)
+	}
+
+	// The view type of an enabled stream cannot be changed.
+	_, err = testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{stream(true, domain.StreamViewTypeKeysOnly)})
+	if domain.ErrorKindOf(err) != domain.ErrorKindValidation {
+		t.Errorf("expected validation error, got: %v", err)
+	}
+
+	// The stream of the existing table is disabled and enabled again.
+	for _, query := range []*domain.DynamoDBQuery{stream(false, ""), stream(true, domain.StreamViewTypeKeysOnly)} {
+		result, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{query})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if expected := query.Schema[0].StreamSpecification.Enabled; expected != (len(result.Streams) == 1) {
+			t.Errorf("unexpected streams: %+v of enabled: %t", result.Streams, expected)
+		}
+	}
+}
+
+func TestExecuteQueriesTableSettings(t *testing.T) {
+	var (
+		db       = awsDynamodb.New(testAwsSession)
+		enabled  = true
+		disabled = false
+		ledger   = &domain.DynamoDBQuery{
+			TableName: "ledger",
+			Schema: []*domain.DynamoDBSchema{
+				{
+					AttributeDefinitions: []*domain.DynamoDBAttributeDefinition{{AttributeName: "id", AttributeType: "S"}},
+					KeySchema:            []*domain.DynamoDBKeySchema{{AttributeName: "id", KeyType: "HASH"}},
+					Tags:                 map[string]string{"team": "payments"},
+					SSESpecification:     &domain.DynamoDBSSESpecification{Type: domain.SSETypeAWSOwned},
+					PointInTimeRecovery:  &enabled,
+					DeletionProtection:   &enabled,
+					TableClass:           domain.TableClassStandardInfrequentAccess,
+				},
+			},
+		}
+		// compliance - settings of the existing table.
+		compliance = &domain.DynamoDBQuery{
+			TableName: ledger.TableName,
+			Schema: []*domain.DynamoDBSchema{{
+				Tags:               map[string]string{"team": "finance", "compliance": "pci"},
+				DeletionProtection: &disabled,
+				TableClass:         domain.TableClassStandard,
+			}},
+		}
+	)
+
+	// Settings are applied to the created table, applying them again changes nothing.
+	for i := 0; i < 2; i++ {
+		if _, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{ledger}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	backups, err := db.DescribeContinuousBackups(&awsDynamodb.DescribeContinuousBackupsInput{TableName: aws.String(ledger.TableName)})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if status := aws.StringValue(backups.ContinuousBackupsDescription.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus); status != awsDynamodb.PointInTimeRecoveryStatusEnabled {
+		t.Errorf("actual point-in-time recovery status: %s does not match expected: %s", status, awsDynamodb.PointInTimeRecoveryStatusEnabled)
+	}
+
+	table, err := db.DescribeTable(&awsDynamodb.DescribeTableInput{TableName: aws.String(ledger.TableName)})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !aws.BoolValue(table.Table.DeletionProtectionEnabled) || tableClassOf(table.Table) != domain.TableClassStandardInfrequentAccess {
+		t.Errorf("unexpected deletion protection: %t or table class: %s", aws.BoolValue(table.Table.DeletionProtectionEnabled), tableClassOf(table.Table))
+	}
+
+	// Settings of the existing table are reconciled, tags are added and updated.
+	if _, err := testMigrationRepository.ExecuteQueries(context.Background(), []*domain.DynamoDBQuery{compliance}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if table, err = db.DescribeTable(&awsDynamodb.DescribeTableInput{TableName: aws.String(ledger.TableName)}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if aws.BoolValue(table.Table.DeletionProtectionEnabled) || tableClassOf(table.Table) != domain.TableClassStandard {
+		t.Errorf("unexpected deletion protection: %t or table class: %s", aws.BoolValue(table.Table.DeletionProtectionEnabled), tableClassOf(table.Table))
+	}
+	output, err := db.ListTagsOfResource(&awsDynamodb.ListTagsOfResourceInput{ResourceArn: table.Table.TableArn})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	tags := make(map[string]string)
+	for _, tag := range output.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if diff := deep.Equal(tags, compliance.Schema[0].Tags); diff != nil {
+		t.Errorf("actual tags: %v do not match expected: %v", tags, compliance.Schema[0].Tags)
+	}
+}
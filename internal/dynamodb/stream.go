@@ -0,0 +1,87 @@
+package dynamodb
+
+import (
+	"context"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// streamUpdate - stream specification of a table in a migration.
+type streamUpdate struct {
+	tableName string
+	spec      *domain.DynamoDBStreamSpecification
+}
+
+// awsStreamSpecification - converts the stream specification, the view type is only sent for enabled streams.
+func awsStreamSpecification(spec *domain.DynamoDBStreamSpecification) *awsDynamodb.StreamSpecification {
+	result := &awsDynamodb.StreamSpecification{StreamEnabled: aws.Bool(spec.Enabled)}
+	if spec.Enabled {
+		result.StreamViewType = aws.String(spec.ViewType)
+	}
+	return result
+}
+
+// updateStream - updates the stream of the table unless it already matches the specification and waits until the table
+// is active, returns the ARN of the enabled stream. The view type of an enabled stream cannot be changed, as consumers
+// of the stream would lose the records of the old stream.
+func (r *migrationRepo) updateStream(ctx context.Context, update streamUpdate) (string, error) {
+	table, err := r.describeTable(ctx, update.tableName)
+	if err != nil {
+		return "", err
+	}
+	var (
+		current   = table.StreamSpecification
+		isEnabled = current != nil && aws.BoolValue(current.StreamEnabled)
+	)
+	switch {
+	case update.spec.Enabled && isEnabled && aws.StringValue(current.StreamViewType) != update.spec.ViewType:
+		return "", domain.Errorf(domain.ErrorKindValidation, "Stream of table %s has view type %s, disable it before enabling it with %s",
+			update.tableName, aws.StringValue(current.StreamViewType), update.spec.ViewType)
+	case update.spec.Enabled == isEnabled:
+		r.logger.Info("Skipping a stream because the table already has it", domain.F("table", update.tableName), domain.F("enabled", isEnabled))
+		if !isEnabled {
+			// The latest stream ARN of a disabled stream refers to the old stream.
+			return "", nil
+		}
+		return aws.StringValue(table.LatestStreamArn), nil
+	}
+	err = r.retryer.Do(ctx, "UpdateTable", func() error {
+		_, err := r.db.UpdateTableWithContext(ctx, &awsDynamodb.UpdateTableInput{
+			TableName:           aws.String(update.tableName),
+			StreamSpecification: awsStreamSpecification(update.spec),
+		})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := r.waitUntilTableExists(ctx, update.tableName); err != nil {
+		return "", err
+	}
+	r.logger.Info("Stream updated", domain.F("table", update.tableName), domain.F("enabled", update.spec.Enabled))
+	if !update.spec.Enabled {
+		return "", nil
+	}
+	if table, err = r.describeTable(ctx, update.tableName); err != nil {
+		return "", err
+	}
+	return aws.StringValue(table.LatestStreamArn), nil
+}
+
+// describeTable - returns the description of the table, throttled requests are retried.
+func (r *migrationRepo) describeTable(ctx context.Context, tableName string) (*awsDynamodb.TableDescription, error) {
+	var output *awsDynamodb.DescribeTableOutput
+	err := r.retryer.Do(ctx, "DescribeTable", func() (err error) {
+		output, err = r.db.DescribeTableWithContext(ctx, &awsDynamodb.DescribeTableInput{
+			TableName: aws.String(tableName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Table, nil
+}
@@ -0,0 +1,234 @@
+package dynamodb
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// settingsUpdate - tags, encryption, point-in-time recovery, deletion protection and the class of a table in a migration.
+type settingsUpdate struct {
+	tableName string
+	schema    *domain.DynamoDBSchema
+}
+
+// hasSettings - checks if the schema defines settings applied by updateSettings.
+func hasSettings(schema *domain.DynamoDBSchema) bool {
+	return len(schema.Tags) > 0 || schema.SSESpecification != nil || schema.PointInTimeRecovery != nil ||
+		schema.DeletionProtection != nil || len(schema.TableClass) > 0
+}
+
+// awsTags - converts the tags ordered by key.
+func awsTags(tags map[string]string) []*awsDynamodb.Tag {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]*awsDynamodb.Tag, len(keys))
+	for i, key := range keys {
+		result[i] = &awsDynamodb.Tag{Key: aws.String(key), Value: aws.String(tags[key])}
+	}
+	return result
+}
+
+// awsSSESpecification - converts the encryption, AWS owned keys are used when encryption with KMS is disabled.
+func awsSSESpecification(spec *domain.DynamoDBSSESpecification) *awsDynamodb.SSESpecification {
+	if spec.Type != domain.SSETypeKMS {
+		return &awsDynamodb.SSESpecification{Enabled: aws.Bool(false)}
+	}
+	result := &awsDynamodb.SSESpecification{Enabled: aws.Bool(true), SSEType: aws.String(awsDynamodb.SSETypeKms)}
+	if len(spec.KMSKeyID) > 0 {
+		result.KMSMasterKeyId = aws.String(spec.KMSKeyID)
+	}
+	return result
+}
+
+// updateSettings - reconciles the tags, the encryption, point-in-time recovery, deletion protection and the class
+// of the table with the schema, settings the table already has are not updated.
+func (r *migrationRepo) updateSettings(ctx context.Context, update settingsUpdate) error {
+	table, err := r.describeTable(ctx, update.tableName)
+	if err != nil {
+		return err
+	}
+	if len(update.schema.Tags) > 0 {
+		if err := r.updateTags(ctx, update.tableName, aws.StringValue(table.TableArn), update.schema.Tags); err != nil {
+			return err
+		}
+	}
+	if spec := update.schema.SSESpecification; spec != nil && !isEncryptedWith(table.SSEDescription, spec) {
+		err := r.retryer.Do(ctx, "UpdateTable", func() error {
+			_, err := r.db.UpdateTableWithContext(ctx, &awsDynamodb.UpdateTableInput{
+				TableName:        aws.String(update.tableName),
+				SSESpecification: awsSSESpecification(spec),
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := r.waitUntilTableExists(ctx, update.tableName); err != nil {
+			return err
+		}
+		r.logger.Info("Encryption updated", domain.F("table", update.tableName), domain.F("type", spec.Type))
+	}
+	if enabled := update.schema.PointInTimeRecovery; enabled != nil {
+		if err := r.updatePointInTimeRecovery(ctx, update.tableName, *enabled); err != nil {
+			return err
+		}
+	}
+	return r.updateTable(ctx, update, table)
+}
+
+// updateTable - updates deletion protection and the class of the table unless the table already has them.
+// DynamoDB allows one change of the table class per table in 30 days.
+func (r *migrationRepo) updateTable(ctx context.Context, update settingsUpdate, table *awsDynamodb.TableDescription) error {
+	input := &awsDynamodb.UpdateTableInput{TableName: aws.String(update.tableName)}
+	if enabled := update.schema.DeletionProtection; enabled != nil && aws.BoolValue(table.DeletionProtectionEnabled) != *enabled {
+		input.DeletionProtectionEnabled = enabled
+	}
+	if class := update.schema.TableClass; len(class) > 0 && tableClassOf(table) != class {
+		input.TableClass = aws.String(class)
+	}
+	if input.DeletionProtectionEnabled == nil && input.TableClass == nil {
+		return nil
+	}
+	err := r.retryer.Do(ctx, "UpdateTable", func() error {
+		_, err := r.db.UpdateTableWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.waitUntilTableExists(ctx, update.tableName); err != nil {
+		return err
+	}
+	r.logger.Info("Table updated", domain.F("table", update.tableName),
+		domain.F("deletion_protection", aws.BoolValue(input.DeletionProtectionEnabled)), domain.F("table_class", aws.StringValue(input.TableClass)))
+	return nil
+}
+
+// tableClassOf - returns the class of the table, tables without a class summary are standard tables.
+func tableClassOf(table *awsDynamodb.TableDescription) string {
+	if table.TableClassSummary == nil || table.TableClassSummary.TableClass == nil {
+		return awsDynamodb.TableClassStandard
+	}
+	return aws.StringValue(table.TableClassSummary.TableClass)
+}
+
+// isEncryptedWith - checks if the table is encrypted as specified. KMS keys are compared by ID or ARN,
+// a specification without a key matches any KMS key.
+func isEncryptedWith(description *awsDynamodb.SSEDescription, spec *domain.DynamoDBSSESpecification) bool {
+	isKMS := false
+	if description != nil {
+		switch aws.StringValue(description.Status) {
+		case awsDynamodb.SSEStatusEnabled, awsDynamodb.SSEStatusEnabling, awsDynamodb.SSEStatusUpdating:
+			isKMS = true
+		}
+	}
+	if spec.Type != domain.SSETypeKMS {
+		return !isKMS
+	}
+	if !isKMS {
+		return false
+	}
+	keyArn := aws.StringValue(description.KMSMasterKeyArn)
+	return len(spec.KMSKeyID) == 0 || keyArn == spec.KMSKeyID || strings.HasSuffix(keyArn, ":key/"+spec.KMSKeyID)
+}
+
+// updateTags - adds the tags missing on the table and updates the changed ones, other tags of the table are kept.
+func (r *migrationRepo) updateTags(ctx context.Context, tableName, tableArn string, tags map[string]string) error {
+	current, err := r.listTags(ctx, tableArn)
+	if err != nil {
+		return err
+	}
+	changed := make(map[string]string)
+	for key, value := range tags {
+		if existing, ok := current[key]; !ok || existing != value {
+			changed[key] = value
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	err = r.retryer.Do(ctx, "TagResource", func() error {
+		_, err := r.db.TagResourceWithContext(ctx, &awsDynamodb.TagResourceInput{
+			ResourceArn: aws.String(tableArn),
+			Tags:        awsTags(changed),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Info("Tags updated", domain.F("table", tableName), domain.F("tags", len(changed)))
+	return nil
+}
+
+// listTags - returns all tags of the resource.
+func (r *migrationRepo) listTags(ctx context.Context, resourceArn string) (map[string]string, error) {
+	var (
+		tags      = make(map[string]string)
+		nextToken *string
+	)
+	for {
+		var output *awsDynamodb.ListTagsOfResourceOutput
+		err := r.retryer.Do(ctx, "ListTagsOfResource", func() (err error) {
+			output, err = r.db.ListTagsOfResourceWithContext(ctx, &awsDynamodb.ListTagsOfResourceInput{
+				ResourceArn: aws.String(resourceArn),
+				NextToken:   nextToken,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range output.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		if nextToken = output.NextToken; nextToken == nil {
+			return tags, nil
+		}
+	}
+}
+
+// updatePointInTimeRecovery - enables or disables point-in-time recovery unless the table already has it.
+// Continuous backups of a new table can be unavailable for a while, these requests are retried.
+func (r *migrationRepo) updatePointInTimeRecovery(ctx context.Context, tableName string, enabled bool) error {
+	var output *awsDynamodb.DescribeContinuousBackupsOutput
+	err := r.retryer.Do(ctx, "DescribeContinuousBackups", func() (err error) {
+		output, err = r.db.DescribeContinuousBackupsWithContext(ctx, &awsDynamodb.DescribeContinuousBackupsInput{
+			TableName: aws.String(tableName),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var status string
+	if description := output.ContinuousBackupsDescription; description != nil && description.PointInTimeRecoveryDescription != nil {
+		status = aws.StringValue(description.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus)
+	}
+	if (status == awsDynamodb.PointInTimeRecoveryStatusEnabled) == enabled {
+		return nil
+	}
+	err = r.retryer.Do(ctx, "UpdateContinuousBackups", func() error {
+		_, err := r.db.UpdateContinuousBackupsWithContext(ctx, &awsDynamodb.UpdateContinuousBackupsInput{
+			TableName: aws.String(tableName),
+			PointInTimeRecoverySpecification: &awsDynamodb.PointInTimeRecoverySpecification{
+				PointInTimeRecoveryEnabled: aws.Bool(enabled),
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.logger.Info("Point-in-time recovery updated", domain.F("table", tableName), domain.F("enabled", enabled))
+	return nil
+}
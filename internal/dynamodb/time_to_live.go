@@ -0,0 +1,103 @@
+package dynamodb
+
+import (
+	"context"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// defaultPollInterval - time between checks of table settings that take effect asynchronously.
+const defaultPollInterval = 5 * time.Second
+
+// timeToLiveUpdate - Time to Live setting of a table in a migration.
+type timeToLiveUpdate struct {
+	tableName string
+	ttl       *domain.DynamoDBTimeToLive
+}
+
+// updateTimeToLive - applies the Time to Live setting unless the table already has it and waits until it takes effect.
+// Time to Live enabled on another attribute must be disabled first, which DynamoDB allows once per hour.
+func (r *migrationRepo) updateTimeToLive(ctx context.Context, update timeToLiveUpdate) error {
+	description, err := r.describeTimeToLive(ctx, update.tableName)
+	if err != nil {
+		return err
+	}
+	status, attributeName := aws.StringValue(description.TimeToLiveStatus), aws.StringValue(description.AttributeName)
+	isEnabled := status == awsDynamodb.TimeToLiveStatusEnabled || status == awsDynamodb.TimeToLiveStatusEnabling
+	switch {
+	case update.ttl.Enabled && isEnabled && attributeName != update.ttl.AttributeName:
+		return domain.Errorf(domain.ErrorKindValidation, "Time to Live of table %s is enabled on attribute %s, disable it before enabling it on %s",
+			update.tableName, attributeName, update.ttl.AttributeName)
+	case update.ttl.Enabled && isEnabled, !update.ttl.Enabled && status == awsDynamodb.TimeToLiveStatusDisabled:
+		r.logger.Info("Skipping Time to Live because the table already has it", domain.F("table", update.tableName), domain.F("status", status))
+		return r.waitUntilTimeToLive(ctx, update, status)
+	}
+	err = r.retryer.Do(ctx, "UpdateTimeToLive", func() error {
+		_, err := r.db.UpdateTimeToLiveWithContext(ctx, &awsDynamodb.UpdateTimeToLiveInput{
+			TableName: aws.String(update.tableName),
+			TimeToLiveSpecification: &awsDynamodb.TimeToLiveSpecification{
+				AttributeName: aws.String(update.ttl.AttributeName),
+				Enabled:       aws.Bool(update.ttl.Enabled),
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.waitUntilTimeToLive(ctx, update, ""); err != nil {
+		return err
+	}
+	r.logger.Info("Time to Live updated", domain.F("table", update.tableName),
+		domain.F("attribute", update.ttl.AttributeName), domain.F("enabled", update.ttl.Enabled))
+	return nil
+}
+
+// waitUntilTimeToLive - polls the Time to Live status until it is enabled or disabled as requested,
+// the known status is checked first.
+func (r *migrationRepo) waitUntilTimeToLive(ctx context.Context, update timeToLiveUpdate, status string) error {
+	expected := awsDynamodb.TimeToLiveStatusDisabled
+	if update.ttl.Enabled {
+		expected = awsDynamodb.TimeToLiveStatusEnabled
+	}
+	for status != expected {
+		if len(status) > 0 {
+			r.logger.Debug("Waiting for Time to Live", domain.F("table", update.tableName), domain.F("status", status))
+			timer := time.NewTimer(r.pollInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		description, err := r.describeTimeToLive(ctx, update.tableName)
+		if err != nil {
+			return err
+		}
+		status = aws.StringValue(description.TimeToLiveStatus)
+	}
+	return nil
+}
+
+// describeTimeToLive - returns the Time to Live setting of the table, throttled requests are retried.
+func (r *migrationRepo) describeTimeToLive(ctx context.Context, tableName string) (*awsDynamodb.TimeToLiveDescription, error) {
+	var output *awsDynamodb.DescribeTimeToLiveOutput
+	err := r.retryer.Do(ctx, "DescribeTimeToLive", func() (err error) {
+		output, err = r.db.DescribeTimeToLiveWithContext(ctx, &awsDynamodb.DescribeTimeToLiveInput{
+			TableName: aws.String(tableName),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.TimeToLiveDescription == nil {
+		return &awsDynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(awsDynamodb.TimeToLiveStatusDisabled)}, nil
+	}
+	return output.TimeToLiveDescription, nil
+}
@@ -25,6 +25,11 @@ func NewMigrationStorage(migrationsDir string) domain.MigrationStorage {
 }
 
 func (s *storage) GetExecutableMigrations() ([]*domain.Migration, error) {
+	migrations, err := s.getExecutableMigrations()
+	return migrations, domain.NewError(domain.ErrorKindStorage, err)
+}
+
+func (s *storage) getExecutableMigrations() ([]*domain.Migration, error) {
 	var migrations []*domain.Migration
 	err := filepath.Walk(s.migrationsDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -64,7 +69,8 @@ func (s *storage) GetExecutableMigrations() ([]*domain.Migration, error) {
 					Minor: minor,
 					Patch: patch,
 				},
-				Name: name,
+				Name:     name,
+				Checksum: domain.ComputeChecksum(content),
 			},
 			Content: content,
 		}
@@ -87,3 +93,38 @@ func getVersion(match []string, i int, filename string) (int, error) {
 	}
 	return 0, fmt.Errorf("Incorrect file versioning pattern: %s", filename)
 }
+
+func (s *storage) CreateMigration(name string, content []byte) (string, error) {
+	path, err := s.createMigration(name, content)
+	return path, domain.NewError(domain.ErrorKindStorage, err)
+}
+
+func (s *storage) createMigration(name string, content []byte) (string, error) {
+	match := s.pattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", fmt.Errorf("Incorrect file naming pattern: %s", name)
+	}
+	version, err := domain.ParseVersion(match[3] + "." + match[4] + "." + match[5])
+	if err != nil {
+		return "", err
+	}
+	migrations, err := s.getExecutableMigrations()
+	if err != nil {
+		return "", err
+	}
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return "", fmt.Errorf("Version %s is already used by the migration %s", version, migration.Name)
+		}
+	}
+	path := filepath.Join(s.migrationsDir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return "", err
+	}
+	return path, file.Close()
+}
@@ -0,0 +1,96 @@
+package filestorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+func TestCreateMigration(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name     string
+		existing []string
+		// directories - directories in the migrations directory, they are not migrations.
+		directories []string
+		file        string
+		expectError bool
+	}{
+		{
+			name: "Success: first migration",
+			file: "1.0.0_create_users.json",
+		},
+		{
+			name:     "Success: next version",
+			existing: []string{"1.0.0_create_users.json"},
+			file:     "1.0.1_add_roles.json",
+		},
+		{
+			name:        "Fail: existing file",
+			existing:    []string{"1.0.0_create_users.json"},
+			file:        "1.0.0_create_users.json",
+			expectError: true,
+		},
+		{
+			name:        "Fail: existing version",
+			existing:    []string{"1.0.0_create_users.json"},
+			file:        "1.0.0_add_roles.json",
+			expectError: true,
+		},
+		{
+			name:        "Fail: path taken by a directory",
+			existing:    []string{"1.0.0_create_users.json"},
+			directories: []string{"1.0.1_add_roles.json"},
+			file:        "1.0.1_add_roles.json",
+			expectError: true,
+		},
+		{
+			name:        "Fail: incorrect naming pattern",
+			file:        "create_users.json",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range test.existing {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("existing"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, name := range test.directories {
+				if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			storage := NewMigrationStorage(dir)
+
+			path, err := storage.CreateMigration(test.file, []byte("created"))
+			if test.expectError {
+				if kind := domain.ErrorKindOf(err); kind != domain.ErrorKindStorage {
+					t.Errorf("expected %s error, got: %v", domain.ErrorKindStorage, err)
+				}
+				for _, name := range test.existing {
+					content, err := ioutil.ReadFile(filepath.Join(dir, name))
+					if err != nil || string(content) != "existing" {
+						t.Errorf("existing file %s must not be overwritten, content: %q, error: %v", name, content, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if expected := filepath.Join(dir, test.file); path != expected {
+				t.Errorf("actual path: %s does not match expected: %s", path, expected)
+			}
+			if content, err := ioutil.ReadFile(path); err != nil || string(content) != "created" {
+				t.Errorf("unexpected content: %q, error: %v", content, err)
+			}
+		})
+	}
+}
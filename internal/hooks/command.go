@@ -0,0 +1,102 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// Environment variables passed to hook commands.
+const (
+	envStage    = "MIGRATION_HOOK"
+	envVersion  = "MIGRATION_VERSION"
+	envName     = "MIGRATION_NAME"
+	envStatus   = "MIGRATION_STATUS"
+	envVersions = "MIGRATION_VERSIONS"
+	envError    = "MIGRATION_ERROR"
+	envRunner   = "MIGRATION_RUNNER"
+)
+
+// NewCommandHook - returns a hook running the command with sh, the event is passed in MIGRATION_* variables
+// and as JSON on stdin. Output of the command is written to output, e.g. stderr to keep stdout for results.
+// Events about migrations other than the hook versions are skipped.
+func NewCommandHook(command domain.HookCommand, output io.Writer) domain.Hook {
+	return func(ctx context.Context, event *domain.HookEvent) error {
+		if !command.IsRunFor(event) {
+			return nil
+		}
+		input, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if command.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, command.Timeout)
+			defer cancel()
+		}
+		cmd := exec.Command("sh", "-c", command.Command)
+		cmd.Env = append(os.Environ(), eventEnv(event)...)
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = output
+		cmd.Stderr = output
+		if err := run(ctx, cmd); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return fmt.Errorf("%s: %w", command.Command, ctxErr)
+			}
+			return fmt.Errorf("%s: %w", command.Command, err)
+		}
+		return nil
+	}
+}
+
+// run - runs the command, its processes are killed when the context is done. Killing only the shell
+// would leave its children holding the output open, so the command runs in its own process group.
+func run(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	return cmd.Wait()
+}
+
+// eventEnv - returns the environment variables of the event.
+func eventEnv(event *domain.HookEvent) []string {
+	env := []string{
+		envStage + "=" + string(event.Stage),
+		envRunner + "=" + event.Runner,
+	}
+	if event.Migration != nil {
+		env = append(env, envVersion+"="+event.Migration.Version.String(), envName+"="+event.Migration.Name)
+	}
+	if event.Result != nil {
+		env = append(env, envStatus+"="+string(event.Result.Status))
+	}
+	versions := event.Versions()
+	if len(versions) > 0 {
+		values := make([]string, len(versions))
+		for i, version := range versions {
+			values[i] = version.String()
+		}
+		env = append(env, envVersions+"="+strings.Join(values, ","))
+	}
+	if len(event.Error) > 0 {
+		env = append(env, envError+"="+event.Error)
+	}
+	return env
+}
@@ -0,0 +1,74 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+func TestCommandHook(t *testing.T) {
+	version := domain.Version{Major: 1, Minor: 2}
+	event := &domain.HookEvent{
+		Stage:     domain.HookStageBeforeEach,
+		Runner:    "ci",
+		Migration: &domain.MigrationSummary{Version: version, Name: "1.2.0_backfill_users.json"},
+	}
+
+	// Test.
+	tests := []struct {
+		name           string
+		command        domain.HookCommand
+		expectedOutput string
+		expectError    bool
+	}{
+		{
+			name:           "Success: event in variables",
+			command:        domain.HookCommand{Command: `echo "$MIGRATION_HOOK $MIGRATION_VERSION $MIGRATION_NAME $MIGRATION_RUNNER"`},
+			expectedOutput: "before_each 1.2.0 1.2.0_backfill_users.json ci\n",
+		},
+		{
+			name:           "Success: event on stdin",
+			command:        domain.HookCommand{Command: "cat"},
+			expectedOutput: `{"stage":"before_each","runner":"ci","hostname":"","migration":{"version":"1.2.0","name":"1.2.0_backfill_users.json"}}`,
+		},
+		{
+			name:           "Success: hook version",
+			command:        domain.HookCommand{Command: "echo backup", Versions: []domain.Version{version}},
+			expectedOutput: "backup\n",
+		},
+		{
+			name:    "Success: other version skipped",
+			command: domain.HookCommand{Command: "echo backup", Versions: []domain.Version{{Major: 1, Minor: 3}}},
+		},
+		{
+			name:           "Fail: exit status",
+			command:        domain.HookCommand{Command: "echo consumer busy >&2; exit 3"},
+			expectedOutput: "consumer busy\n",
+			expectError:    true,
+		},
+		{
+			name:        "Fail: timeout",
+			command:     domain.HookCommand{Command: "sleep 5", Timeout: 50 * time.Millisecond},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := NewCommandHook(test.command, &output)(context.Background(), event)
+			if test.expectError != (err != nil) {
+				t.Errorf("actual error: %v, expected error: %t", err, test.expectError)
+			}
+			if actual := output.String(); actual != test.expectedOutput {
+				t.Errorf("actual output: %q does not match expected: %q", actual, test.expectedOutput)
+			}
+			if err != nil && !strings.Contains(err.Error(), test.command.Command) {
+				t.Errorf("the error must contain the command: %v", err)
+			}
+		})
+	}
+}
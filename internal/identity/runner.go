@@ -0,0 +1,53 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/user"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awsSession "github.com/aws/aws-sdk-go/aws/session"
+	awsSts "github.com/aws/aws-sdk-go/service/sts"
+)
+
+// GetRunner - returns the runner metadata, the identity is the caller ARN unless overridden.
+// The local user is the identity if the caller ARN cannot be resolved.
+func GetRunner(ctx context.Context, session *awsSession.Session, appVersion, identityOverride string, logger domain.Logger) domain.Runner {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warn("Cannot resolve hostname", domain.F("error", err))
+	}
+	runner := domain.Runner{
+		AppVersion: appVersion,
+		Hostname:   hostname,
+		Identity:   identityOverride,
+	}
+	if len(runner.Identity) > 0 {
+		return runner
+	}
+	// The endpoint of the session is the DynamoDB endpoint, e.g. of a local DynamoDB, STS uses its own endpoint.
+	output, err := awsSts.New(session, aws.NewConfig().WithEndpoint("")).GetCallerIdentityWithContext(ctx, &awsSts.GetCallerIdentityInput{})
+	if err == nil && len(aws.StringValue(output.Arn)) == 0 {
+		err = errors.New("STS returned no caller ARN")
+	}
+	if err != nil {
+		runner.Identity = localUser(logger)
+		logger.Warn("Cannot resolve caller identity, using the local user", domain.F("identity", runner.Identity), domain.F("error", err))
+		return runner
+	}
+	runner.Identity = aws.StringValue(output.Arn)
+	return runner
+}
+
+// localUser - returns the name of the user running the process, empty if it cannot be resolved.
+func localUser(logger domain.Logger) string {
+	current, err := user.Current()
+	if err != nil {
+		logger.Warn("Cannot resolve local user", domain.F("error", err))
+		return ""
+	}
+	return current.Username
+}
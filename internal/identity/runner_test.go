@@ -0,0 +1,116 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os/user"
+	"strings"
+	"sync"
+	"testing"
+
+	"dynamodb.data-migration/internal/logging"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	awsSession "github.com/aws/aws-sdk-go/aws/session"
+)
+
+const callerIdentityResponse = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>%s<UserId>AIDAEXAMPLE</UserId><Account>123456789012</Account></GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>01234567-89ab-cdef-0123-456789abcdef</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`
+
+// standIn - answers STS requests without network access and records the requested hosts.
+type standIn struct {
+	mu    sync.Mutex
+	hosts []string
+	// arn - caller ARN of the response, the element is omitted if empty.
+	arn string
+	// failed - requests fail with a connection error.
+	failed bool
+}
+
+func (s *standIn) RoundTrip(r *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hosts = append(s.hosts, r.URL.Host)
+	if s.failed {
+		return nil, errors.New("connection refused")
+	}
+	var arn string
+	if len(s.arn) > 0 {
+		arn = "<Arn>" + s.arn + "</Arn>"
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       ioutil.NopCloser(strings.NewReader(strings.Replace(callerIdentityResponse, "%s", arn, 1))),
+		Request:    r,
+	}, nil
+}
+
+func TestGetRunner(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("local user cannot be resolved: %s", err)
+	}
+
+	// Test.
+	tests := []struct {
+		name             string
+		standIn          *standIn
+		identityOverride string
+		expectedIdentity string
+		expectedHosts    []string
+	}{
+		{
+			name:             "Success: caller ARN from STS instead of the custom endpoint",
+			standIn:          &standIn{arn: "arn:aws:iam::123456789012:user/ci"},
+			expectedIdentity: "arn:aws:iam::123456789012:user/ci",
+			expectedHosts:    []string{"sts.amazonaws.com"},
+		},
+		{
+			name:             "Success: overridden identity",
+			standIn:          &standIn{arn: "arn:aws:iam::123456789012:user/ci"},
+			identityOverride: "ci@pipeline",
+			expectedIdentity: "ci@pipeline",
+		},
+		{
+			name:             "Fail: local user if STS cannot be reached",
+			standIn:          &standIn{failed: true},
+			expectedIdentity: current.Username,
+			expectedHosts:    []string{"sts.amazonaws.com"},
+		},
+		{
+			name:             "Fail: local user if STS returns no ARN",
+			standIn:          &standIn{},
+			expectedIdentity: current.Username,
+			expectedHosts:    []string{"sts.amazonaws.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := awsSession.Must(awsSession.NewSession(&aws.Config{
+				Endpoint:    aws.String("http://localhost:4566"),
+				Region:      aws.String("eu-west-1"),
+				Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+				MaxRetries:  aws.Int(0),
+			}))
+			// Set after the session is created, which rejects custom transports when a CA bundle is configured.
+			session.Config.HTTPClient = &http.Client{Transport: test.standIn}
+
+			runner := GetRunner(context.Background(), session, "1.0.0", test.identityOverride, logging.NewNopLogger())
+			if runner.Identity != test.expectedIdentity {
+				t.Errorf("actual identity: %q does not match expected: %q", runner.Identity, test.expectedIdentity)
+			}
+			if runner.AppVersion != "1.0.0" {
+				t.Errorf("actual app version: %q does not match expected: 1.0.0", runner.AppVersion)
+			}
+			if strings.Join(test.standIn.hosts, ",") != strings.Join(test.expectedHosts, ",") {
+				t.Errorf("actual requested hosts: %v do not match expected: %v", test.standIn.hosts, test.expectedHosts)
+			}
+		})
+	}
+}
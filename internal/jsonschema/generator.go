@@ -0,0 +1,192 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Draft - JSON Schema draft of generated documents.
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Struct field tags read by the generator.
+const (
+	// TagSchema - comma separated keywords: required, minLength=N, minItems=N, maxItems=N, enum=A|B.
+	TagSchema = "jsonschema"
+	// TagDescription - description of the field.
+	TagDescription = "description"
+)
+
+type generator struct {
+	definitions map[string]interface{}
+}
+
+// Generate - returns a JSON Schema document for values of the type, structs are placed in definitions.
+func Generate(t reflect.Type, title string) ([]byte, error) {
+	g := &generator{
+		definitions: make(map[string]interface{}),
+	}
+	root, err := g.schemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+	return g.document(root, title)
+}
+
+// GenerateOneOf - returns a JSON Schema document for values of exactly one of the types, e.g. alternative file forms.
+func GenerateOneOf(title string, types ...reflect.Type) ([]byte, error) {
+	g := &generator{
+		definitions: make(map[string]interface{}),
+	}
+	alternatives := make([]interface{}, len(types))
+	for i, t := range types {
+		schema, err := g.schemaOf(t)
+		if err != nil {
+			return nil, err
+		}
+		alternatives[i] = schema
+	}
+	return g.document(map[string]interface{}{"oneOf": alternatives}, title)
+}
+
+// document - adds the draft, the title and the definitions to the root schema.
+func (g *generator) document(root map[string]interface{}, title string) ([]byte, error) {
+	root["$schema"] = Draft
+	root["title"] = title
+	if len(g.definitions) > 0 {
+		root["definitions"] = g.definitions
+	}
+	content, err := json.MarshalIndent(root, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+func (g *generator) schemaOf(t reflect.Type) (map[string]interface{}, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// Reserve the name first, so recursive types refer to themselves.
+			g.definitions[t.Name()] = nil
+			definition, err := g.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			g.definitions[t.Name()] = definition
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("Map keys must be strings: %s", t)
+		}
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]interface{}{"type": "object"}, nil
+		}
+		values, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported type: %s", t)
+	}
+}
+
+func (g *generator) structSchema(t reflect.Type) (map[string]interface{}, error) {
+	var (
+		properties = make(map[string]interface{})
+		required   []string
+	)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || len(field.PkgPath) > 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		property, err := g.schemaOf(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t.Name(), field.Name, err)
+		}
+		if _, ok := property["$ref"]; ok && len(field.Tag.Get(TagDescription)) > 0 {
+			// Draft 7 ignores keywords next to $ref.
+			property = map[string]interface{}{"allOf": []interface{}{property}}
+		}
+		if description := field.Tag.Get(TagDescription); len(description) > 0 {
+			property["description"] = description
+		}
+		isRequired, err := applyKeywords(property, field.Tag.Get(TagSchema))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", t.Name(), field.Name, err)
+		}
+		if isRequired {
+			required = append(required, name)
+		}
+		properties[name] = property
+	}
+	definition := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		definition["required"] = required
+	}
+	return definition, nil
+}
+
+// applyKeywords - adds keywords of the field tag to the property, returns true if the field is required.
+func applyKeywords(property map[string]interface{}, tag string) (bool, error) {
+	if len(tag) == 0 {
+		return false, nil
+	}
+	required := false
+	for _, keyword := range strings.Split(tag, ",") {
+		parts := strings.SplitN(keyword, "=", 2)
+		switch parts[0] {
+		case "required":
+			required = true
+		case "minLength", "minItems", "maxItems":
+			if len(parts) != 2 {
+				return false, fmt.Errorf("Keyword %s requires a value", parts[0])
+			}
+			value, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return false, fmt.Errorf("Keyword %s: %v", parts[0], err)
+			}
+			property[parts[0]] = value
+		case "enum":
+			if len(parts) != 2 {
+				return false, fmt.Errorf("Keyword enum requires a value")
+			}
+			property["enum"] = strings.Split(parts[1], "|")
+		default:
+			return false, fmt.Errorf("Unknown keyword: %s", keyword)
+		}
+	}
+	return required, nil
+}
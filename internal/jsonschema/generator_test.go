@@ -0,0 +1,142 @@
+package jsonschema
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestMigrationFileInSync(t *testing.T) {
+	expected, err := ioutil.ReadFile("../../schema/migration.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := MigrationFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Error("schema/migration.schema.json is out of sync with the domain types, run: go run ./cmd schema > schema/migration.schema.json")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	type node struct {
+		Name     string  `json:"name" jsonschema:"required,enum=a|b"`
+		Children []*node `json:"children,omitempty"`
+		Weight   float64
+		internal int
+	}
+	type invalid struct {
+		Name string `json:"name" jsonschema:"unknown"`
+	}
+
+	// Test.
+	tests := []struct {
+		name        string
+		value       interface{}
+		expected    string
+		expectError bool
+	}{
+		{
+			name:  "Success: recursive struct",
+			value: node{},
+			expected: `{
+    "$ref": "#/definitions/node",
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "definitions": {
+        "node": {
+            "additionalProperties": false,
+            "properties": {
+                "Weight": {
+                    "type": "number"
+                },
+                "children": {
+                    "items": {
+                        "$ref": "#/definitions/node"
+                    },
+                    "type": "array"
+                },
+                "name": {
+                    "enum": [
+                        "a",
+                        "b"
+                    ],
+                    "type": "string"
+                }
+            },
+            "required": [
+                "name"
+            ],
+            "type": "object"
+        }
+    },
+    "title": "test"
+}
+`,
+		},
+		{
+			name:        "Fail: unknown keyword",
+			value:       invalid{},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Generate(reflect.TypeOf(test.value), "test")
+			if test.expectError {
+				if err == nil {
+					t.Error("expected error but got nothing")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(actual) != test.expected {
+				t.Errorf("Generate() = %s, expected %s", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateOneOf(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	expected := `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "definitions": {
+        "item": {
+            "additionalProperties": false,
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            },
+            "type": "object"
+        }
+    },
+    "oneOf": [
+        {
+            "items": {
+                "$ref": "#/definitions/item"
+            },
+            "type": "array"
+        },
+        {
+            "$ref": "#/definitions/item"
+        }
+    ],
+    "title": "test"
+}
+`
+	actual, err := GenerateOneOf("test", reflect.TypeOf([]item{}), reflect.TypeOf(item{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("GenerateOneOf() = %s, expected %s", actual, expected)
+	}
+}
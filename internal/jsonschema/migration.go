@@ -0,0 +1,16 @@
+package jsonschema
+
+import (
+	"reflect"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// MigrationFileTitle - title of the migration file schema.
+const MigrationFileTitle = "DynamoDB data migration file"
+
+// MigrationFile - returns the JSON Schema of migration files generated from the domain types,
+// a file is an array of queries or a migration object with options.
+func MigrationFile() ([]byte, error) {
+	return GenerateOneOf(MigrationFileTitle, reflect.TypeOf([]*domain.DynamoDBQuery{}), reflect.TypeOf(domain.DynamoDBMigration{}))
+}
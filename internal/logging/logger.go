@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// Format - format of log entries.
+type Format string
+
+// Log formats.
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat - parses a log format name: text or json.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatText, FormatJSON:
+		return Format(s), nil
+	default:
+		return FormatText, fmt.Errorf("Invalid log format %q, expected text or json", s)
+	}
+}
+
+// output - writer shared by loggers derived with With.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  domain.LogLevel
+	format Format
+	now    func() time.Time
+}
+
+type logger struct {
+	out    *output
+	fields []domain.LogField
+}
+
+// NewLogger - constructs a logger writing entries of the level and above.
+func NewLogger(w io.Writer, level domain.LogLevel, format Format) domain.Logger {
+	return &logger{
+		out: &output{
+			w:      w,
+			level:  level,
+			format: format,
+			now:    time.Now,
+		},
+	}
+}
+
+// NewNopLogger - constructs a logger discarding all entries.
+func NewNopLogger() domain.Logger {
+	return NewLogger(io.Discard, domain.LogLevelError+1, FormatText)
+}
+
+func (l *logger) Debug(msg string, fields ...domain.LogField) {
+	l.log(domain.LogLevelDebug, msg, fields)
+}
+
+func (l *logger) Info(msg string, fields ...domain.LogField) {
+	l.log(domain.LogLevelInfo, msg, fields)
+}
+
+func (l *logger) Warn(msg string, fields ...domain.LogField) {
+	l.log(domain.LogLevelWarn, msg, fields)
+}
+
+func (l *logger) Error(msg string, fields ...domain.LogField) {
+	l.log(domain.LogLevelError, msg, fields)
+}
+
+func (l *logger) With(fields ...domain.LogField) domain.Logger {
+	merged := make([]domain.LogField, 0, len(l.fields)+len(fields))
+	merged = append(merged, l.fields...)
+	merged = append(merged, fields...)
+	return &logger{
+		out:    l.out,
+		fields: merged,
+	}
+}
+
+func (l *logger) log(level domain.LogLevel, msg string, fields []domain.LogField) {
+	if level < l.out.level {
+		return
+	}
+	all := make([]domain.LogField, 0, len(l.fields)+len(fields))
+	all = append(all, l.fields...)
+	all = append(all, fields...)
+
+	var line string
+	now := l.out.now()
+	if l.out.format == FormatJSON {
+		line = formatJSON(now, level, msg, all)
+	} else {
+		line = formatText(now, level, msg, all)
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = io.WriteString(l.out.w, line)
+}
+
+// formatText - formats the entry like the standard logger followed by key=value fields.
+func formatText(now time.Time, level domain.LogLevel, msg string, fields []domain.LogField) string {
+	var b strings.Builder
+	b.WriteString(now.Format("2006/01/02 15:04:05 "))
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for _, field := range fields {
+		b.WriteString(" ")
+		b.WriteString(field.Key)
+		b.WriteString("=")
+		value := fmt.Sprint(fieldValue(field.Value))
+		if strings.ContainsAny(value, " \t\n\"=") || len(value) == 0 {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(value)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// formatJSON - formats the entry as a JSON object, fields are added after time, level and msg.
+func formatJSON(now time.Time, level domain.LogLevel, msg string, fields []domain.LogField) string {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSON(&b, now.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for _, field := range fields {
+		b.WriteString(",")
+		writeJSON(&b, field.Key)
+		b.WriteString(":")
+		writeJSON(&b, fieldValue(field.Value))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeJSON(b *strings.Builder, value interface{}) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(content)
+}
+
+// fieldValue - converts errors and stringers to strings, other values are logged as is.
+func fieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return value
+	}
+}
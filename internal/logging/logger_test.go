@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+func TestLogger(t *testing.T) {
+	now := time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)
+
+	// Test.
+	tests := []struct {
+		name     string
+		format   Format
+		level    domain.LogLevel
+		expected string
+	}{
+		{
+			name:   "Success: text",
+			format: FormatText,
+			level:  domain.LogLevelInfo,
+			expected: "2021/09/01 10:00:00 INFO Migration applied version=1.0.0 name=1.0.0_users.json items=3\n" +
+				"2021/09/01 10:00:00 ERROR Migration failed version=1.0.0 name=1.0.0_users.json error=\"transaction rejected\"\n",
+		},
+		{
+			name:   "Success: json",
+			format: FormatJSON,
+			level:  domain.LogLevelDebug,
+			expected: `{"time":"2021-09-01T10:00:00Z","level":"debug","msg":"Parsing migration","version":"1.0.0","name":"1.0.0_users.json"}` + "\n" +
+				`{"time":"2021-09-01T10:00:00Z","level":"info","msg":"Migration applied","version":"1.0.0","name":"1.0.0_users.json","items":3}` + "\n" +
+				`{"time":"2021-09-01T10:00:00Z","level":"error","msg":"Migration failed","version":"1.0.0","name":"1.0.0_users.json","error":"transaction rejected"}` + "\n",
+		},
+		{
+			name:     "Success: level filter",
+			format:   FormatText,
+			level:    domain.LogLevelError + 1,
+			expected: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := NewLogger(&buf, test.level, test.format)
+			l.(*logger).out.now = func() time.Time { return now }
+
+			migrationLogger := l.With(domain.F("version", domain.Version{Major: 1}), domain.F("name", "1.0.0_users.json"))
+			migrationLogger.Debug("Parsing migration")
+			migrationLogger.Info("Migration applied", domain.F("items", 3))
+			migrationLogger.Error("Migration failed", domain.F("error", errors.New("transaction rejected")))
+			if actual := buf.String(); actual != test.expected {
+				t.Errorf("actual log:\n%s\ndoes not match expected:\n%s", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseLevelAndFormat(t *testing.T) {
+	if level, err := domain.ParseLogLevel("WARN"); err != nil || level != domain.LogLevelWarn {
+		t.Errorf("ParseLogLevel() = %v, %v, expected warn", level, err)
+	}
+	if _, err := domain.ParseLogLevel("verbose"); err == nil {
+		t.Error("expected error but got nothing")
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error but got nothing")
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// Server - HTTP endpoint serving the metrics on /metrics while a command runs.
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// Serve - starts serving the registry on the address. The address is bound before returning,
+// so an address in use is reported immediately.
+func Serve(addr string, registry *Registry, logger domain.Logger) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, domain.Errorf(domain.ErrorKindConfig, "Cannot serve metrics on %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	s := &Server{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		listener: listener,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics endpoint stopped", domain.F("addr", addr), domain.F("error", err))
+		}
+	}()
+	logger.Debug("Serving metrics", domain.F("addr", s.Addr()))
+	return s, nil
+}
+
+// Addr - returns the bound address, e.g. with the port chosen for :0.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown - stops the endpoint, running scrapes are completed until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// Push - replaces the metrics of the job in a Pushgateway with the content of the registry.
+func Push(ctx context.Context, client *http.Client, pushURL, job string, registry *Registry) error {
+	var body bytes.Buffer
+	if err := registry.WriteText(&body); err != nil {
+		return err
+	}
+	target := strings.TrimRight(pushURL, "/") + "/metrics/job/" + url.PathEscape(job)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &body)
+	if err != nil {
+		return fmt.Errorf("Invalid metrics push URL %s: %w", pushURL, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Cannot push metrics to %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Cannot push metrics to %s: %s %s", target, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
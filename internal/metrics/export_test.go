@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dynamodb.data-migration/internal/logging"
+)
+
+func TestPush(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name        string
+		status      int
+		job         string
+		expectedURL string
+		expectError bool
+	}{
+		{
+			name:        "Success: metrics replaced",
+			status:      http.StatusOK,
+			job:         "dynamodb_migrations",
+			expectedURL: "/metrics/job/dynamodb_migrations",
+		},
+		{
+			name:        "Success: job escaped",
+			status:      http.StatusAccepted,
+			job:         "prod/migrations",
+			expectedURL: "/metrics/job/prod%2Fmigrations",
+		},
+		{
+			name:        "Fail: rejected by the gateway",
+			status:      http.StatusBadRequest,
+			job:         "dynamodb_migrations",
+			expectedURL: "/metrics/job/dynamodb_migrations",
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				method, url, mediaType, body string
+				registry                     = NewRegistry()
+			)
+			registry.MigrationsSkipped(3)
+			gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				content, _ := ioutil.ReadAll(r.Body)
+				method, url, mediaType, body = r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type"), string(content)
+				w.WriteHeader(test.status)
+			}))
+			defer gateway.Close()
+
+			err := Push(context.Background(), gateway.Client(), gateway.URL+"/", test.job, registry)
+			if (err != nil) != test.expectError {
+				t.Errorf("actual error: %v, expected error: %t", err, test.expectError)
+			}
+			if method != http.MethodPut || url != test.expectedURL || mediaType != contentType {
+				t.Errorf("unexpected push request: %s %s, content type: %s", method, url, mediaType)
+			}
+			if !strings.Contains(body, "migrations_skipped_total 3\n") {
+				t.Errorf("pushed body must contain the metrics, actual:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	registry := NewRegistry()
+	registry.MigrationsSkipped(1)
+	server, err := Serve("127.0.0.1:0", registry, logging.NewNopLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer server.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + server.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "migrations_skipped_total 1\n") {
+		t.Errorf("unexpected metrics response: %s\n%s", resp.Status, body)
+	}
+
+	// The address in use is reported.
+	if _, err := Serve(server.Addr(), registry, logging.NewNopLogger()); err == nil {
+		t.Error("expected error but got nothing")
+	}
+}
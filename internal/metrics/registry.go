@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// Metric names.
+const (
+	nameApplied      = "migrations_applied_total"
+	nameFailed       = "migrations_failed_total"
+	nameReverted     = "migrations_reverted_total"
+	nameSkipped      = "migrations_skipped_total"
+	nameDuration     = "migration_duration_seconds"
+	nameItemsWritten = "migration_items_written"
+	nameRetries      = "migration_request_retries_total"
+	nameThrottles    = "migration_request_throttles_total"
+	nameLockWait     = "migration_lock_wait_seconds"
+)
+
+// Metric types of the Prometheus text format.
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+// contentType - content type of the Prometheus text format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+type family struct {
+	help    string
+	kind    string
+	samples map[string]*sample
+}
+
+// Registry - collects metrics of a run in memory, safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry - constructs a registry, counters of migrations start at zero.
+func NewRegistry() *Registry {
+	r := &Registry{
+		families: map[string]*family{
+			nameApplied:      {help: "Migrations applied successfully.", kind: typeCounter},
+			nameFailed:       {help: "Migrations failed.", kind: typeCounter},
+			nameReverted:     {help: "Migrations reverted.", kind: typeCounter},
+			nameSkipped:      {help: "Pending migrations not run because the run stopped before them.", kind: typeCounter},
+			nameDuration:     {help: "Duration of a migration.", kind: typeGauge},
+			nameItemsWritten: {help: "Items written by a migration.", kind: typeGauge},
+			nameRetries:      {help: "Retries of AWS requests by operation and error code.", kind: typeCounter},
+			nameThrottles:    {help: "AWS requests rejected by throttling.", kind: typeCounter},
+			nameLockWait:     {help: "Time spent acquiring the migrations lock.", kind: typeGauge},
+		},
+	}
+	for name, f := range r.families {
+		f.samples = make(map[string]*sample)
+		if f.kind == typeCounter && name != nameRetries && name != nameThrottles {
+			f.samples[""] = &sample{}
+		}
+	}
+	return r
+}
+
+func (r *Registry) MigrationFinished(result *domain.MigrationResult) {
+	labels := []string{"version", result.Version.String(), "name", result.Name}
+	switch result.Status {
+	case domain.MigrationStatusSucceeded:
+		r.add(nameApplied, 1)
+	case domain.MigrationStatusReverted:
+		r.add(nameReverted, 1)
+	default:
+		r.add(nameFailed, 1)
+	}
+	r.set(nameDuration, float64(result.DurationMs)/1000, labels...)
+	if result.Status != domain.MigrationStatusReverted {
+		r.set(nameItemsWritten, float64(result.ItemsWritten), labels...)
+	}
+}
+
+func (r *Registry) MigrationsSkipped(count int) {
+	r.add(nameSkipped, float64(count))
+}
+
+func (r *Registry) RequestRetried(operation, code string) {
+	r.add(nameRetries, 1, "operation", operation, "code", code)
+}
+
+func (r *Registry) RequestThrottled(operation string) {
+	r.add(nameThrottles, 1, "operation", operation)
+}
+
+func (r *Registry) LockWaited(d time.Duration) {
+	r.set(nameLockWait, d.Seconds())
+}
+
+// WriteText - writes the metrics in the Prometheus text format, sorted by name and labels.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+		keys := make([]string, 0, len(f.samples))
+		for key := range f.samples {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.samples[key]
+			fmt.Fprintf(&b, "%s%s %s\n", name, s.labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler - returns the HTTP handler serving the metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = r.WriteText(w)
+	})
+}
+
+func (r *Registry) add(name string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sample(name, labels).value += value
+}
+
+func (r *Registry) set(name string, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sample(name, labels).value = value
+}
+
+// sample - returns the sample of the label pairs, creates it if needed. The registry must be locked.
+func (r *Registry) sample(name string, labels []string) *sample {
+	f := r.families[name]
+	key := formatLabels(labels)
+	s, ok := f.samples[key]
+	if !ok {
+		s = &sample{labels: key}
+		f.samples[key] = s
+	}
+	return s
+}
+
+// formatLabels - formats label name and value pairs as {name="value",...}.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// nopMetrics - discards all measurements.
+type nopMetrics struct{}
+
+// NewNopMetrics - constructs metrics discarding all measurements.
+func NewNopMetrics() domain.Metrics {
+	return nopMetrics{}
+}
+
+func (nopMetrics) MigrationFinished(*domain.MigrationResult) {}
+
+func (nopMetrics) MigrationsSkipped(int) {}
+
+func (nopMetrics) RequestRetried(string, string) {}
+
+func (nopMetrics) RequestThrottled(string) {}
+
+func (nopMetrics) LockWaited(time.Duration) {}
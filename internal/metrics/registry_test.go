@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	registry := NewRegistry()
+	registry.MigrationFinished(&domain.MigrationResult{
+		Version:         domain.Version{Major: 1},
+		Name:            "1.0.0_create_users.json",
+		Status:          domain.MigrationStatusSucceeded,
+		DurationMs:      1500,
+		ExecutionResult: domain.ExecutionResult{ItemsWritten: 5},
+	})
+	registry.MigrationFinished(&domain.MigrationResult{
+		Version: domain.Version{Major: 1, Patch: 1},
+		Name:    `1.0.1_"quoted".json`,
+		Status:  domain.MigrationStatusFailed,
+	})
+	registry.MigrationsSkipped(2)
+	registry.RequestRetried("TransactWriteItems", domain.RetryCodeTransactionConflict)
+	registry.RequestRetried("TransactWriteItems", domain.RetryCodeTransactionConflict)
+	registry.RequestThrottled("PutItem")
+	registry.LockWaited(250 * time.Millisecond)
+
+	var actual strings.Builder
+	if err := registry.WriteText(&actual); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := `# HELP migration_duration_seconds Duration of a migration.
+# TYPE migration_duration_seconds gauge
+migration_duration_seconds{version="1.0.0",name="1.0.0_create_users.json"} 1.5
+migration_duration_seconds{version="1.0.1",name="1.0.1_\"quoted\".json"} 0
+# HELP migration_items_written Items written by a migration.
+# TYPE migration_items_written gauge
+migration_items_written{version="1.0.0",name="1.0.0_create_users.json"} 5
+migration_items_written{version="1.0.1",name="1.0.1_\"quoted\".json"} 0
+# HELP migration_lock_wait_seconds Time spent acquiring the migrations lock.
+# TYPE migration_lock_wait_seconds gauge
+migration_lock_wait_seconds 0.25
+# HELP migration_request_retries_total Retries of AWS requests by operation and error code.
+# TYPE migration_request_retries_total counter
+migration_request_retries_total{operation="TransactWriteItems",code="TransactionConflict"} 2
+# HELP migration_request_throttles_total AWS requests rejected by throttling.
+# TYPE migration_request_throttles_total counter
+migration_request_throttles_total{operation="PutItem"} 1
+# HELP migrations_applied_total Migrations applied successfully.
+# TYPE migrations_applied_total counter
+migrations_applied_total 1
+# HELP migrations_failed_total Migrations failed.
+# TYPE migrations_failed_total counter
+migrations_failed_total 1
+# HELP migrations_reverted_total Migrations reverted.
+# TYPE migrations_reverted_total counter
+migrations_reverted_total 0
+# HELP migrations_skipped_total Pending migrations not run because the run stopped before them.
+# TYPE migrations_skipped_total counter
+migrations_skipped_total 2
+`
+	if actual.String() != expected {
+		t.Errorf("actual metrics:\n%s\ndo not match expected:\n%s", actual.String(), expected)
+	}
+}
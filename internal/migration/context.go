@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// lockReleaseTimeout - time to release the migrations lock after the run context is canceled.
+const lockReleaseTimeout = 10 * time.Second
+
+// lockRefreshesPerTTL - number of lock refreshes within the lock ttl, so a failed refresh leaves time for the next one.
+const lockRefreshesPerTTL = 3
+
+// detachedContext - keeps values of the parent context but is never canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// detach - returns a context with values of the parent context, cancellation of the parent is ignored.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// runStep - runs a migration step with a context that is not canceled together with the run context,
+// so an interrupted run completes the running step. The deadline of the run context still applies.
+// The step is traced as a span with the migration attributes.
+func (s *service) runStep(ctx context.Context, spanName string, record *domain.MigrationRecord, step func(stepCtx context.Context) (*domain.MigrationResult, error)) (*domain.MigrationResult, error) {
+	var (
+		stepCtx context.Context
+		cancel  context.CancelFunc
+	)
+	if deadline, ok := ctx.Deadline(); ok {
+		stepCtx, cancel = context.WithDeadline(detach(ctx), deadline)
+	} else {
+		stepCtx, cancel = context.WithCancel(detach(ctx))
+	}
+	defer cancel()
+	stepCtx, span := s.tracer.Start(stepCtx, spanName, trace.WithAttributes(
+		tracing.AttrVersion.String(record.Version.String()),
+		tracing.AttrName.String(record.Name),
+	))
+	result, err := step(stepCtx)
+	span.SetAttributes(tracing.ResultAttributes(result)...)
+	tracing.End(span, err)
+	return result, err
+}
+
+// stopped - returns the error of a run stopped by the context before the migration.
+func stopped(name string, err error) error {
+	return domain.NewError(domain.ErrorKindCanceled, fmt.Errorf("Run stopped before migration %s: %w", name, err))
+}
@@ -0,0 +1,63 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newHookEvent - returns the event of the stage with the runner.
+func (s *service) newHookEvent(stage domain.HookStage) *domain.HookEvent {
+	return &domain.HookEvent{
+		Stage:    stage,
+		Runner:   s.runner.Identity,
+		Hostname: s.runner.Hostname,
+	}
+}
+
+// runHooks - runs hooks of the event stage in the order they were added, the first failed hook stops the run.
+func (s *service) runHooks(ctx context.Context, event *domain.HookEvent) error {
+	hooks := s.hooks[event.Stage]
+	if len(hooks) == 0 {
+		return nil
+	}
+	ctx, span := s.tracer.Start(ctx, "runHooks", trace.WithAttributes(
+		tracing.AttrHookStage.String(string(event.Stage)),
+		tracing.AttrHooks.Int(len(hooks)),
+	))
+	logger := s.logger.With(domain.F("stage", event.Stage))
+	if event.Migration != nil {
+		logger = logger.With(domain.F("version", event.Migration.Version), domain.F("name", event.Migration.Name))
+	}
+	var err error
+	for i, hook := range hooks {
+		startTime := time.Now()
+		if err = hook(ctx, event); err != nil {
+			err = domain.Errorf(domain.ErrorKindHook, "Hook %s #%d failed: %w", event.Stage, i+1, err)
+			break
+		}
+		logger.Debug("Hook finished", domain.F("hook", i+1), domain.F("duration_ms", time.Since(startTime).Milliseconds()))
+	}
+	tracing.End(span, err)
+	return err
+}
+
+// runErrorHooks - runs on_error hooks of the failed run, also when the run context is canceled.
+// Errors of on_error hooks are logged since the run already failed.
+func (s *service) runErrorHooks(ctx context.Context, pending []*pendingMigration, report *domain.RunReport, runErr error) {
+	event := s.newHookEvent(domain.HookStageOnError)
+	event.Pending = pendingSummaries(pending)
+	event.Migrations = report.Migrations
+	event.Error = runErr.Error()
+	if n := len(report.Migrations); n > 0 && report.Migrations[n-1].Status == domain.MigrationStatusFailed {
+		event.Result = report.Migrations[n-1]
+		event.Migration = &domain.MigrationSummary{Version: event.Result.Version, Name: event.Result.Name}
+	}
+	if err := s.runHooks(detach(ctx), event); err != nil {
+		s.logger.Error("On-error hook failed", domain.F("error", err))
+	}
+}
@@ -1,25 +1,118 @@
 package migration
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/logging"
+	"dynamodb.data-migration/internal/metrics"
+	"dynamodb.data-migration/internal/notifier"
+	"dynamodb.data-migration/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Commands of notified runs.
 const (
-	statusOK = iota
-	statusError
-	statusExist
+	commandUp   = "up"
+	commandDown = "down"
 )
 
 type service struct {
 	repository  domain.MigrationRepository
 	storage     domain.MigrationStorage
 	queryParser domain.QueryParser
+	runner      domain.Runner
+	lockTTL     time.Duration
+	logger      domain.Logger
+	metrics     domain.Metrics
+	tracer      trace.Tracer
+	notifier    domain.Notifier
+	hooks       map[domain.HookStage][]domain.Hook
+	backuper    domain.TableBackuper
+	unitPrices  domain.UnitPrices
+}
+
+// pendingMigration - pending migration with its parsed queries.
+type pendingMigration struct {
+	migration *domain.Migration
+	record    *domain.MigrationRecord
+	queries   []*domain.DynamoDBQuery
+	// backupBefore - tables backed up before the queries are executed.
+	backupBefore []string
+}
+
+// Option - configures optional service dependencies.
+type Option func(s *service)
+
+// WithRunner - sets the runner metadata recorded in migration records.
+func WithRunner(runner domain.Runner) Option {
+	return func(s *service) {
+		s.runner = runner
+	}
+}
+
+// WithLockTTL - sets the time after which the lock of a crashed run expires.
+func WithLockTTL(ttl time.Duration) Option {
+	return func(s *service) {
+		s.lockTTL = ttl
+	}
+}
+
+// WithLogger - sets the logger, by default info entries are written to stderr as text.
+func WithLogger(logger domain.Logger) Option {
+	return func(s *service) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics - sets the metrics of migration runs, by default they are discarded.
+func WithMetrics(metrics domain.Metrics) Option {
+	return func(s *service) {
+		s.metrics = metrics
+	}
+}
+
+// WithTracer - sets the tracer of migration runs, by default spans are discarded.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(s *service) {
+		s.tracer = tracer
+	}
+}
+
+// WithNotifier - sets the notifier of up and down runs, by default notifications are discarded.
+func WithNotifier(notifier domain.Notifier) Option {
+	return func(s *service) {
+		s.notifier = notifier
+	}
+}
+
+// WithHook - adds a hook run at the stage of up runs, hooks of a stage run in the order they are added.
+func WithHook(stage domain.HookStage, hook domain.Hook) Option {
+	return func(s *service) {
+		s.hooks[stage] = append(s.hooks[stage], hook)
+	}
+}
+
+// WithBackuper - sets the backuper of tables listed in backup_before of migration files,
+// by default such migrations fail since backups cannot be taken.
+func WithBackuper(backuper domain.TableBackuper) Option {
+	return func(s *service) {
+		s.backuper = backuper
+	}
+}
+
+// WithUnitPrices - sets prices used to estimate the cost of migrations, by default domain.DefaultUnitPrices.
+func WithUnitPrices(prices domain.UnitPrices) Option {
+	return func(s *service) {
+		s.unitPrices = prices
+	}
 }
 
 // NewMigrationService creates a service with necessary dependencies.
@@ -27,97 +120,805 @@ func NewMigrationService(
 	repository domain.MigrationRepository,
 	storage domain.MigrationStorage,
 	queryParser domain.QueryParser,
+	options ...Option,
 ) domain.MigrationService {
-	return &service{
+	s := &service{
 		repository:  repository,
 		storage:     storage,
 		queryParser: queryParser,
+		lockTTL:     domain.DefaultLockTTL,
+		logger:      logging.NewLogger(os.Stderr, domain.LogLevelInfo, logging.FormatText),
+		metrics:     metrics.NewNopMetrics(),
+		tracer:      tracing.NewNopTracer(),
+		notifier:    notifier.NewNopNotifier(),
+		hooks:       make(map[domain.HookStage][]domain.Hook),
+		unitPrices:  domain.DefaultUnitPrices(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *service) Migrate(ctx context.Context, options domain.MigrateOptions) (*domain.RunReport, error) {
+	ctx, span := s.tracer.Start(ctx, "Migrate")
+	report := domain.NewRunReport()
+	startTime := time.Now()
+	err := s.withLock(ctx, func(ctx context.Context) error {
+
+		// Get pending migrations.
+		//
+		pending, err := s.getPendingMigrations(ctx, options)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		s.notifyStarted(ctx, commandUp, startTime, pendingSummaries(pending))
+
+		// Run migrations, on_error hooks run if a migration or a hook fails.
+		//
+		if err := s.applyPending(ctx, pending, report); err != nil {
+			s.runErrorHooks(ctx, pending, report, err)
+			return err
+		}
+		return nil
+	})
+	s.notifyFinished(ctx, commandUp, startTime, report, err)
+	span.SetAttributes(tracing.AttrApplied.Int(report.Count(domain.MigrationStatusSucceeded)))
+	tracing.End(span, err)
+	return report, err
+}
+
+// applyPending - runs the pending migrations between the before_all and after_all hooks,
+// the run stops between migrations if the context is canceled.
+func (s *service) applyPending(ctx context.Context, pending []*pendingMigration, report *domain.RunReport) error {
+	beforeAll := s.newHookEvent(domain.HookStageBeforeAll)
+	beforeAll.Pending = pendingSummaries(pending)
+	if err := s.runHooks(ctx, beforeAll); err != nil {
+		s.metrics.MigrationsSkipped(len(pending))
+		return err
+	}
+	for i, p := range pending {
+		if err := ctx.Err(); err != nil {
+			s.metrics.MigrationsSkipped(len(pending) - i)
+			return stopped(p.migration.Name, err)
+		}
+		result, err := s.runStep(ctx, "runMigration", &p.migration.MigrationRecord, func(stepCtx context.Context) (*domain.MigrationResult, error) {
+			return s.runMigration(stepCtx, p)
+		})
+		report.Add(result)
+		s.metrics.MigrationFinished(result)
+		if err != nil {
+			s.metrics.MigrationsSkipped(len(pending) - i - 1)
+			return fmt.Errorf("Migration failed: %s, error: %w", p.migration.Name, err)
+		}
+		s.migrationLogger(&p.migration.MigrationRecord).Info("Migration applied",
+			domain.F("duration_ms", result.DurationMs), domain.F("items_written", result.ItemsWritten),
+			domain.F("estimated_cost", result.EstimatedCost))
+		afterEach := s.newHookEvent(domain.HookStageAfterEach)
+		afterEach.Migration = migrationSummary(&p.migration.MigrationRecord)
+		afterEach.Result = result
+		if err := s.runHooks(ctx, afterEach); err != nil {
+			s.metrics.MigrationsSkipped(len(pending) - i - 1)
+			return err
+		}
 	}
+	afterAll := s.newHookEvent(domain.HookStageAfterAll)
+	afterAll.Migrations = report.Migrations
+	return s.runHooks(ctx, afterAll)
 }
 
-func (s *service) Migrate() (applied int, err error) {
+func (s *service) Plan(ctx context.Context, options domain.MigrateOptions) ([]*domain.PlannedMigration, error) {
+	pending, err := s.getPendingMigrations(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]*domain.PlannedMigration, len(pending))
+	for i, p := range pending {
+		planned := &domain.PlannedMigration{
+			Version: p.migration.Version,
+			Name:    p.migration.Name,
+			Retry:   p.record != nil,
+			Backups: p.backupBefore,
+		}
+		for _, q := range p.queries {
+			if q.CreatesTable() {
+				planned.Tables = append(planned.Tables, q.TableName)
+			}
+			planned.Items += len(q.Data)
+		}
+		plan[i] = planned
+	}
+	return plan, nil
+}
+
+func (s *service) Rollback(ctx context.Context, options domain.RollbackOptions) (*domain.RunReport, error) {
+	report := domain.NewRunReport()
+	if options.Steps < 0 {
+		return report, domain.NewError(domain.ErrorKindUsage, errors.New("Number of steps cannot be negative"))
+	}
+	ctx, span := s.tracer.Start(ctx, "Rollback")
+	startTime := time.Now()
+	err := s.withLock(ctx, func(ctx context.Context) error {
+		records, err := s.repository.GetMigrationRecords(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Select migrations to revert in the reverse order.
+		//
+		var selected []*domain.MigrationRecord
+		for i := len(records) - 1; i >= 0; i-- {
+			record := records[i]
+			if options.Target != nil && record.Version.Compare(*options.Target) <= 0 {
+				break
+			}
+			if options.Steps > 0 && len(selected) >= options.Steps {
+				break
+			}
+			selected = append(selected, record)
+		}
+		if len(selected) > 0 {
+			s.notifyStarted(ctx, commandDown, startTime, recordSummaries(selected))
+		}
 
-	// Get executable migrations.
+		// Revert migrations, the run stops between migrations if the context is canceled.
+		//
+		for _, record := range selected {
+			if err := ctx.Err(); err != nil {
+				return stopped(record.Name, err)
+			}
+			result, err := s.runStep(ctx, "revertMigration", record, func(stepCtx context.Context) (*domain.MigrationResult, error) {
+				return s.revertMigration(stepCtx, record, options)
+			})
+			report.Add(result)
+			s.metrics.MigrationFinished(result)
+			if err != nil {
+				return fmt.Errorf("Rollback failed: %s, error: %w", record.Name, err)
+			}
+			s.migrationLogger(record).Info("Migration reverted")
+		}
+		return nil
+	})
+	s.notifyFinished(ctx, commandDown, startTime, report, err)
+	span.SetAttributes(tracing.AttrReverted.Int(report.Count(domain.MigrationStatusReverted)))
+	tracing.End(span, err)
+	return report, err
+}
+
+func (s *service) Baseline(ctx context.Context, target domain.Version) (baselined int, err error) {
+	err = s.withLock(ctx, func(ctx context.Context) error {
+		baselined, err = s.baseline(ctx, target)
+		return err
+	})
+	return baselined, err
+}
+
+func (s *service) baseline(ctx context.Context, target domain.Version) (baselined int, err error) {
+
+	// Get executable migrations up to the target version.
 	//
-	migrations, err := s.storage.GetExecutableMigrations()
+	migrations, err := s.getSortedMigrations()
+	if err != nil {
+		return baselined, err
+	}
+	migrations, err = selectUpTo(migrations, target)
 	if err != nil {
-		return applied, err
+		return baselined, err
 	}
 
-	// Sort migrations in the correct order.
+	// Create migration records without executing the migrations.
 	//
-	sort.Slice(migrations, func(i, j int) bool {
-		if migrations[i].Version.Major == migrations[j].Version.Major {
-			return migrations[i].Version.Minor < migrations[j].Version.Minor
+	for _, migration := range migrations {
+		if err := ctx.Err(); err != nil {
+			return baselined, stopped(migration.Name, err)
+		}
+		isExist, err := s.repository.IsMigrationRecordExist(ctx, migration.Version)
+		if err != nil {
+			return baselined, fmt.Errorf("Baseline failed: %s, error: %w", migration.Name, err)
 		}
-		return migrations[i].Version.Major < migrations[j].Version.Major
+		if isExist {
+			s.migrationLogger(&migration.MigrationRecord).Info("Migration exists")
+			continue
+		}
+		now := time.Now()
+		migration.Baselined = true
+		migration.SetExecutionTime(now, now)
+		migration.SetRunner(s.runner)
+		if err := s.repository.CreateMigrationRecord(ctx, migration.MigrationRecord); err != nil {
+			return baselined, fmt.Errorf("Baseline failed: %s, error: %w", migration.Name, err)
+		}
+		baselined++
+		s.migrationLogger(&migration.MigrationRecord).Info("Migration baselined")
+	}
+	return baselined, nil
+}
+
+func (s *service) MarkApplied(ctx context.Context, ver domain.Version) error {
+	return s.withLock(ctx, func(ctx context.Context) error {
+		return s.markApplied(ctx, ver)
 	})
+}
+
+func (s *service) markApplied(ctx context.Context, ver domain.Version) error {
+
+	// Find the migration file.
+	//
+	migration, err := s.getMigration(ver)
+	if err != nil {
+		return err
+	}
 
-	// Run migrations.
+	// Create or fix the migration record.
 	//
+	record, err := s.repository.GetMigrationRecord(ctx, ver)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	migration.SetExecutionTime(now, now)
+	migration.SetRunner(s.runner)
+	migration.Status = domain.MigrationStatusSucceeded
+	if record == nil {
+		if err := s.repository.CreateMigrationRecord(ctx, migration.MigrationRecord); err != nil {
+			return err
+		}
+		s.migrationLogger(&migration.MigrationRecord).Info("Audit: migration record created",
+			domain.F("status", migration.Status), domain.F("checksum", migration.Checksum))
+		return nil
+	}
+	if record.IsSucceeded() {
+		return domain.Errorf(domain.ErrorKindMigrationState, "Migration is already applied: %s", ver)
+	}
+	if err := s.repository.UpdateMigrationRecord(ctx, migration.MigrationRecord); err != nil {
+		return err
+	}
+	s.migrationLogger(&migration.MigrationRecord).Info("Audit: migration record updated",
+		domain.F("previous_status", record.Status), domain.F("status", migration.Status), domain.F("checksum", migration.Checksum))
+	return nil
+}
+
+func (s *service) Unmark(ctx context.Context, ver domain.Version) error {
+	return s.withLock(ctx, func(ctx context.Context) error {
+		return s.unmark(ctx, ver)
+	})
+}
+
+func (s *service) unmark(ctx context.Context, ver domain.Version) error {
+	record, err := s.repository.GetMigrationRecord(ctx, ver)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return domain.Errorf(domain.ErrorKindMigrationState, "Migration record does not exist: %s", ver)
+	}
+	if err := s.repository.DeleteMigrationRecord(ctx, ver); err != nil {
+		return err
+	}
+	s.migrationLogger(record).Info("Audit: migration record removed",
+		domain.F("status", record.GetStatus()), domain.F("checksum", record.Checksum))
+	return nil
+}
+
+func (s *service) Repair(ctx context.Context) (repaired int, err error) {
+	err = s.withLock(ctx, func(ctx context.Context) error {
+		repaired, err = s.repair(ctx)
+		return err
+	})
+	return repaired, err
+}
+
+func (s *service) repair(ctx context.Context) (repaired int, err error) {
+
+	// Index migration files by version.
+	//
+	migrations, err := s.getSortedMigrations()
+	if err != nil {
+		return repaired, err
+	}
+	files := make(map[string]*domain.Migration, len(migrations))
 	for _, migration := range migrations {
-		status, err := s.runMigration(migration)
-		if err != nil {
-			return applied, fmt.Errorf("Migration failed: %s, error: %v", migration.Name, err)
+		files[migration.Version.ID()] = migration
+	}
+
+	// Check migration records.
+	//
+	records, err := s.repository.GetMigrationRecords(ctx)
+	if err != nil {
+		return repaired, err
+	}
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return repaired, stopped(record.Name, err)
+		}
+		if !record.IsSucceeded() {
+			if err := s.repository.DeleteMigrationRecord(ctx, record.Version); err != nil {
+				return repaired, err
+			}
+			repaired++
+			s.migrationLogger(record).Info("Audit: migration record removed", domain.F("status", record.Status))
+			continue
+		}
+		file, ok := files[record.Version.ID()]
+		if !ok {
+			s.migrationLogger(record).Warn("Migration file not found, record kept")
+			continue
+		}
+		if record.Checksum == file.Checksum {
+			continue
 		}
-		switch status {
-		case statusOK:
-			applied++
-			log.Println("Migration applied:", migration.Name)
-		case statusExist:
-			log.Println("Migration exists:", migration.Name)
-		default:
-			log.Println("Unknown status code:", status, "migration:", migration.Name)
+		previous := record.Checksum
+		record.Checksum = file.Checksum
+		if err := s.repository.UpdateMigrationRecord(ctx, *record); err != nil {
+			return repaired, err
 		}
+		repaired++
+		s.migrationLogger(record).Info("Audit: migration record checksum updated",
+			domain.F("previous_checksum", previous), domain.F("checksum", record.Checksum))
+	}
+	return repaired, nil
+}
+
+func (s *service) Status(ctx context.Context) ([]*domain.MigrationInfo, error) {
+	migrations, err := s.getSortedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.repository.GetMigrationRecords(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// No errors.
+	// Merge migration files with migration records.
 	//
-	return applied, nil
+	infos := make(map[string]*domain.MigrationInfo, len(migrations)+len(records))
+	for _, migration := range migrations {
+		infos[migration.Version.ID()] = &domain.MigrationInfo{
+			Version:      migration.Version,
+			Name:         migration.Name,
+			FileChecksum: migration.Checksum,
+		}
+	}
+	for _, record := range records {
+		info, ok := infos[record.Version.ID()]
+		if !ok {
+			info = &domain.MigrationInfo{
+				Version: record.Version,
+				Name:    record.Name,
+			}
+			infos[record.Version.ID()] = info
+		}
+		info.Record = record
+		for _, backup := range record.Metadata.Backups {
+			info.RestoreHints = append(info.RestoreHints, backup.RestoreHint())
+		}
+	}
+	result := make([]*domain.MigrationInfo, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version.Less(result[j].Version)
+	})
+	return result, nil
 }
 
-func (s *service) runMigration(m *domain.Migration) (status int, err error) {
+func (s *service) Unlock(ctx context.Context) (*domain.Lock, error) {
+	lock, err := s.repository.DeleteLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lock != nil {
+		s.logger.Info("Audit: migrations lock removed",
+			domain.F("owner", lock.Owner), domain.F("acquired_at", time.Unix(lock.AcquiredAt, 0).UTC()), domain.F("expires_at", time.Unix(lock.ExpiresAt, 0).UTC()))
+	}
+	return lock, nil
+}
+
+// migrationLogger - returns a logger adding the migration version and name to entries.
+func (s *service) migrationLogger(record *domain.MigrationRecord) domain.Logger {
+	return s.logger.With(domain.F("version", record.Version), domain.F("name", record.Name))
+}
 
-	// Nil check.
+// withLock - runs the function while holding the migrations lock, the lock is released even if the context is canceled.
+// The lock is refreshed while the function runs. If a refresh fails, e.g. because another run took over the expired lock,
+// the context of the function is canceled, so no further migrations are started, and the refresh error is returned.
+func (s *service) withLock(ctx context.Context, fn func(ctx context.Context) error) error {
+	startTime := time.Now()
+	lock := domain.NewLock(s.lockOwner(), startTime, s.lockTTL)
+	err := s.repository.AcquireLock(ctx, lock)
+	s.metrics.LockWaited(time.Since(startTime))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(detach(ctx), lockReleaseTimeout)
+		defer cancel()
+		if err := s.repository.ReleaseLock(releaseCtx, lock.Owner); err != nil {
+			s.logger.Error("Cannot release migrations lock", domain.F("owner", lock.Owner), domain.F("error", err))
+		}
+	}()
+	lockCtx, cancel := context.WithCancel(ctx)
+	refreshed := make(chan error, 1)
+	go func() {
+		refreshed <- s.refreshLock(lockCtx, cancel, lock)
+	}()
+	err = fn(lockCtx)
+	cancel()
+	if refreshErr := <-refreshed; refreshErr != nil {
+		return refreshErr
+	}
+	return err
+}
+
+// refreshLock - extends the lock every third of its ttl until the context is done,
+// cancels the run and returns the error if a refresh fails.
+func (s *service) refreshLock(ctx context.Context, cancel context.CancelFunc, lock domain.Lock) error {
+	if s.lockTTL <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(s.lockTTL / lockRefreshesPerTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			lock.ExpiresAt = now.Add(s.lockTTL).Unix()
+			err := s.repository.RefreshLock(ctx, lock)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				// The run finished during the refresh.
+				return nil
+			}
+			s.logger.Error("Cannot refresh migrations lock, stopping the run", domain.F("owner", lock.Owner), domain.F("error", err))
+			cancel()
+			return fmt.Errorf("Migrations lock cannot be refreshed, the run was stopped: %w", err)
+		}
+	}
+}
+
+func (s *service) lockOwner() string {
+	identity := s.runner.Identity
+	if len(identity) == 0 {
+		identity = "unknown"
+	}
+	return fmt.Sprintf("%s@%s/%d", identity, s.runner.Hostname, os.Getpid())
+}
+
+// getPendingMigrations - returns migrations that will be applied with the options.
+func (s *service) getPendingMigrations(ctx context.Context, options domain.MigrateOptions) ([]*pendingMigration, error) {
+
+	// Check options.
 	//
-	if m == nil {
-		return statusError, errors.New("Migration record cannot be nil")
+	if options.Steps < 0 {
+		return nil, domain.NewError(domain.ErrorKindUsage, errors.New("Number of steps cannot be negative"))
 	}
 
-	// Check if the migration record exist.
+	// Get executable migrations in the correct order.
 	//
-	isExist, err := s.repository.IsMigrationRecordExist(m.Version)
+	migrations, err := s.getSortedMigrations()
 	if err != nil {
-		return statusError, err
+		return nil, err
 	}
-	if isExist {
-		// Migration already applied.
-		return statusExist, nil
+
+	// Drop migrations above the target version.
+	//
+	if options.Target != nil {
+		migrations, err = selectUpTo(migrations, *options.Target)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Parse queries.
+	// Check migration records and parse queries.
 	//
-	queries, err := s.queryParser.ParseContent(m.Content)
+	var pending []*pendingMigration
+	for _, migration := range migrations {
+		if options.Steps > 0 && len(pending) >= options.Steps {
+			s.logger.Info("Steps limit reached", domain.F("steps", options.Steps))
+			break
+		}
+		record, err := s.repository.GetMigrationRecord(ctx, migration.Version)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			if record.IsSucceeded() {
+				// Migration already applied.
+				s.migrationLogger(&migration.MigrationRecord).Debug("Migration exists")
+				continue
+			}
+			if !options.AllowFailed {
+				return nil, domain.Errorf(domain.ErrorKindMigrationState,
+					"Migration %s is %s after %d attempt(s), last error: %q; run repair or use --allow-failed to retry",
+					migration.Name, record.Status, record.Attempts, record.Error,
+				)
+			}
+		}
+		parsed, err := s.queryParser.ParseMigration(migration.Content)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse migration: %s, error: %w", migration.Name, err)
+		}
+		if err := parsed.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid migration: %s, error: %w", migration.Name, err)
+		}
+		pending = append(pending, &pendingMigration{
+			migration:    migration,
+			record:       record,
+			queries:      parsed.Queries,
+			backupBefore: parsed.BackupBefore,
+		})
+	}
+	return pending, nil
+}
+
+func (s *service) getMigration(ver domain.Version) (*domain.Migration, error) {
+	migrations, err := s.storage.GetExecutableMigrations()
 	if err != nil {
-		return statusError, err
+		return nil, err
+	}
+	for _, migration := range migrations {
+		if migration.Version.Compare(ver) == 0 {
+			return migration, nil
+		}
 	}
+	return nil, domain.Errorf(domain.ErrorKindStorage, "Migration file not found: %s", ver)
+}
 
-	// Execute migration queries.
+func (s *service) getSortedMigrations() ([]*domain.Migration, error) {
+	migrations, err := s.storage.GetExecutableMigrations()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version.Less(migrations[j].Version)
+	})
+	return migrations, nil
+}
+
+func (s *service) runMigration(ctx context.Context, p *pendingMigration) (*domain.MigrationResult, error) {
+	m := p.migration
+	if p.record != nil {
+		s.migrationLogger(&m.MigrationRecord).Warn("Retrying migration",
+			domain.F("status", p.record.Status), domain.F("attempts", p.record.Attempts))
+	}
+
+	// Run before_each hooks, the migration stays pending if they fail.
 	//
 	startTime := time.Now()
-	if err := s.repository.ExecuteQueries(queries); err != nil {
-		return statusError, err
+	ctx, outcome := s.newMigrationResult(ctx, &m.MigrationRecord, startTime)
+	beforeEach := s.newHookEvent(domain.HookStageBeforeEach)
+	beforeEach.Migration = migrationSummary(&m.MigrationRecord)
+	if err := s.runHooks(ctx, beforeEach); err != nil {
+		return outcome.fail(err), err
 	}
 
-	// Set execution time.
+	// Create the in-progress migration record.
 	//
+	var err error
+	m.Status = domain.MigrationStatusInProgress
+	m.Attempts = 1
+	m.SetExecutionTime(startTime, startTime)
+	m.SetRunner(s.runner)
+	if p.record == nil {
+		err = s.repository.CreateMigrationRecord(ctx, m.MigrationRecord)
+	} else {
+		m.Attempts = p.record.Attempts + 1
+		err = s.repository.UpdateMigrationRecord(ctx, m.MigrationRecord)
+	}
+	if err != nil {
+		return outcome.fail(err), err
+	}
+
+	// Back up tables and record the backups before the queries change them.
+	//
+	if len(p.backupBefore) > 0 {
+		backups, err := s.backupTables(ctx, p)
+		m.Metadata.Backups = backups
+		outcome.Backups = backups
+		if err != nil {
+			return s.recordFailure(ctx, m, startTime, outcome, err)
+		}
+		if err := s.repository.UpdateMigrationRecord(ctx, m.MigrationRecord); err != nil {
+			return outcome.fail(err), err
+		}
+	}
+
+	// Execute migration queries.
+	//
+	result, err := s.repository.ExecuteQueries(ctx, p.queries)
+	result.ConsumedCapacity, result.EstimatedCost = outcome.capacity()
+	m.SetExecutionResult(result)
+	outcome.ExecutionResult = result
+	if err != nil {
+		return s.recordFailure(ctx, m, startTime, outcome, err)
+	}
+
+	// Mark the migration record as succeeded.
+	//
+	m.Status = domain.MigrationStatusSucceeded
+	m.Error = ""
 	m.SetExecutionTime(startTime, time.Now())
+	if err := s.repository.UpdateMigrationRecord(ctx, m.MigrationRecord); err != nil {
+		return outcome.fail(err), err
+	}
+	return outcome.finish(domain.MigrationStatusSucceeded), nil
+}
+
+// backupTables - takes backups of the tables listed in the migration file.
+func (s *service) backupTables(ctx context.Context, p *pendingMigration) ([]domain.TableBackup, error) {
+	if s.backuper == nil {
+		return nil, domain.Errorf(domain.ErrorKindConfig, "Migration backs up tables %s, but backups are not configured", strings.Join(p.backupBefore, ", "))
+	}
+	backups, err := s.backuper.BackupTables(ctx, p.backupBefore, p.migration.Version)
+	if err != nil {
+		return backups, fmt.Errorf("Backup failed: %w", err)
+	}
+	return backups, nil
+}
 
-	// Create migration record.
+// recordFailure - marks the migration record as failed.
+func (s *service) recordFailure(ctx context.Context, m *domain.Migration, startTime time.Time, outcome *migrationResult, err error) (*domain.MigrationResult, error) {
+	m.Status = domain.MigrationStatusFailed
+	m.Error = err.Error()
+	m.SetExecutionTime(startTime, time.Now())
+	if updateErr := s.repository.UpdateMigrationRecord(ctx, m.MigrationRecord); updateErr != nil {
+		err = fmt.Errorf("%w; cannot record the failure: %v", err, updateErr)
+	}
+	return outcome.fail(err), err
+}
+
+func (s *service) revertMigration(ctx context.Context, record *domain.MigrationRecord, options domain.RollbackOptions) (*domain.MigrationResult, error) {
+	ctx, outcome := s.newMigrationResult(ctx, record, time.Now())
+
+	// Check the migration record state.
+	//
+	if !record.IsSucceeded() {
+		err := domain.Errorf(domain.ErrorKindMigrationState, "Migration is %s, run repair or unmark first", record.Status)
+		return outcome.fail(err), err
+	}
+	if record.Baselined {
+		err := domain.NewError(domain.ErrorKindMigrationState, errors.New("Migration was baselined and never executed, use unmark instead"))
+		return outcome.fail(err), err
+	}
+
+	// Find and parse the migration file.
 	//
-	if err := s.repository.CreateMigrationRecord(m.MigrationRecord); err != nil {
-		return statusError, err
+	migration, err := s.getMigration(record.Version)
+	if err != nil {
+		return outcome.fail(err), err
+	}
+	if len(record.Checksum) > 0 && record.Checksum != migration.Checksum {
+		err := domain.NewError(domain.ErrorKindMigrationState, errors.New("Migration file was changed after it was applied, run repair first"))
+		return outcome.fail(err), err
+	}
+	queries, err := s.queryParser.ParseContent(migration.Content)
+	if err != nil {
+		return outcome.fail(err), err
 	}
 
-	return statusOK, nil
+	// Revert queries and remove the migration record.
+	//
+	result, err := s.repository.RevertQueries(ctx, queries, options.DropTables)
+	outcome.ExecutionResult = result
+	if err != nil {
+		return outcome.fail(err), err
+	}
+	s.migrationLogger(record).Debug("Migration queries reverted",
+		domain.F("items_deleted", result.ItemsDeleted), domain.F("tables_deleted", result.TablesDeleted))
+	if err := s.repository.DeleteMigrationRecord(ctx, record.Version); err != nil {
+		return outcome.fail(err), err
+	}
+	return outcome.finish(domain.MigrationStatusReverted), nil
+}
+
+// migrationResult - collects the result of a migration run.
+type migrationResult struct {
+	*domain.MigrationResult
+	startTime time.Time
+	meter     *domain.CapacityMeter
+	prices    domain.UnitPrices
+}
+
+// newMigrationResult - returns the context metering capacity consumed by the migration and its result.
+func (s *service) newMigrationResult(ctx context.Context, record *domain.MigrationRecord, startTime time.Time) (context.Context, *migrationResult) {
+	meter := domain.NewCapacityMeter()
+	return domain.WithCapacityMeter(ctx, meter), &migrationResult{
+		MigrationResult: &domain.MigrationResult{
+			Version:   record.Version,
+			Name:      record.Name,
+			StartTime: startTime.UTC(),
+		},
+		startTime: startTime,
+		meter:     meter,
+		prices:    s.unitPrices,
+	}
+}
+
+// capacity - returns the capacity consumed so far and its estimated cost.
+func (r *migrationResult) capacity() (domain.ConsumedCapacity, float64) {
+	consumed := r.meter.Consumed()
+	return consumed, r.prices.Cost(consumed.Total())
+}
+
+func (r *migrationResult) finish(status domain.MigrationStatus) *domain.MigrationResult {
+	r.Status = status
+	r.DurationMs = time.Since(r.startTime).Milliseconds()
+	r.ConsumedCapacity, r.EstimatedCost = r.capacity()
+	return r.MigrationResult
+}
+
+func (r *migrationResult) fail(err error) *domain.MigrationResult {
+	r.Error = err.Error()
+	return r.finish(domain.MigrationStatusFailed)
+}
+
+// selectUpTo - returns sorted migrations up to and including the target version.
+func selectUpTo(migrations []*domain.Migration, target domain.Version) ([]*domain.Migration, error) {
+	for i, migration := range migrations {
+		if migration.Version.Compare(target) == 0 {
+			return migrations[:i+1], nil
+		}
+	}
+	return nil, domain.Errorf(domain.ErrorKindUsage, "Target version not found: %s", target)
+}
+
+// notifyStarted - notifies the start of a run with its pending migrations.
+func (s *service) notifyStarted(ctx context.Context, command string, startTime time.Time, pending []*domain.MigrationSummary) {
+	s.notify(ctx, &domain.RunNotification{
+		Event:     domain.RunEventStarted,
+		Command:   command,
+		StartTime: startTime.UTC(),
+		Pending:   pending,
+	})
+}
+
+// notifyFinished - notifies the end of a run that failed or applied or reverted migrations.
+func (s *service) notifyFinished(ctx context.Context, command string, startTime time.Time, report *domain.RunReport, err error) {
+	notification := &domain.RunNotification{
+		Event:      domain.RunEventSucceeded,
+		Command:    command,
+		StartTime:  startTime.UTC(),
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Migrations: report.Migrations,
+	}
+	if err != nil {
+		notification.Event = domain.RunEventFailed
+		notification.Error = err.Error()
+	} else if len(report.Migrations) == 0 {
+		return
+	}
+	s.notify(ctx, notification)
+}
+
+// notify - sends the notification with the runner, also when the run context is canceled.
+func (s *service) notify(ctx context.Context, notification *domain.RunNotification) {
+	notification.Runner = s.runner.Identity
+	notification.Hostname = s.runner.Hostname
+	s.notifier.Notify(detach(ctx), notification)
+}
+
+func migrationSummary(record *domain.MigrationRecord) *domain.MigrationSummary {
+	return &domain.MigrationSummary{Version: record.Version, Name: record.Name}
+}
+
+func pendingSummaries(pending []*pendingMigration) []*domain.MigrationSummary {
+	summaries := make([]*domain.MigrationSummary, len(pending))
+	for i, p := range pending {
+		summaries[i] = migrationSummary(&p.migration.MigrationRecord)
+	}
+	return summaries
+}
+
+func recordSummaries(records []*domain.MigrationRecord) []*domain.MigrationSummary {
+	summaries := make([]*domain.MigrationSummary, len(records))
+	for i, record := range records {
+		summaries[i] = migrationSummary(record)
+	}
+	return summaries
 }
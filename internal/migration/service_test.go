@@ -0,0 +1,1070 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/metrics"
+	"dynamodb.data-migration/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type fakeRepository struct {
+	records  map[string]domain.MigrationRecord
+	executed [][]*domain.DynamoDBQuery
+	reverted [][]*domain.DynamoDBQuery
+	failures map[string]error
+	lock     *domain.Lock
+	// refreshes - number of lock refreshes, the lock is guarded by mu as it is refreshed concurrently.
+	refreshes int
+	mu        sync.Mutex
+	// onExecute - called before queries are executed.
+	onExecute func(ctx context.Context)
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		records:  make(map[string]domain.MigrationRecord),
+		failures: make(map[string]error),
+	}
+}
+
+func (r *fakeRepository) ExecuteQueries(ctx context.Context, queries []*domain.DynamoDBQuery) (domain.ExecutionResult, error) {
+	if r.onExecute != nil {
+		r.onExecute(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return domain.ExecutionResult{}, err
+	}
+	if err := r.failures[queries[0].TableName]; err != nil {
+		return domain.ExecutionResult{}, err
+	}
+	r.executed = append(r.executed, queries)
+	return domain.ExecutionResult{ItemsWritten: len(queries)}, nil
+}
+
+func (r *fakeRepository) RevertQueries(ctx context.Context, queries []*domain.DynamoDBQuery, dropTables bool) (domain.ExecutionResult, error) {
+	r.reverted = append(r.reverted, queries)
+	return domain.ExecutionResult{ItemsDeleted: len(queries)}, nil
+}
+
+func (r *fakeRepository) AcquireLock(ctx context.Context, lock domain.Lock) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lock != nil && r.lock.Owner != lock.Owner {
+		return &domain.LockHeldError{Lock: *r.lock}
+	}
+	r.lock = &lock
+	return nil
+}
+
+func (r *fakeRepository) ReleaseLock(ctx context.Context, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lock == nil || r.lock.Owner != owner {
+		return fmt.Errorf("Migrations lock is not held by %s", owner)
+	}
+	r.lock = nil
+	return nil
+}
+
+func (r *fakeRepository) RefreshLock(ctx context.Context, lock domain.Lock) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lock == nil || r.lock.Owner != lock.Owner {
+		return domain.Errorf(domain.ErrorKindLockHeld, "Migrations lock is no longer held by %s", lock.Owner)
+	}
+	r.lock = &lock
+	r.refreshes++
+	return nil
+}
+
+// takeOverLock - replaces the lock as another run would after the lock expired.
+func (r *fakeRepository) takeOverLock(owner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lock := domain.NewLock(owner, time.Now(), time.Hour)
+	r.lock = &lock
+}
+
+func (r *fakeRepository) DeleteLock(ctx context.Context) (*domain.Lock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lock := r.lock
+	r.lock = nil
+	return lock, nil
+}
+
+func (r *fakeRepository) IsMigrationRecordExist(ctx context.Context, ver domain.Version) (bool, error) {
+	_, ok := r.records[ver.ID()]
+	return ok, nil
+}
+
+func (r *fakeRepository) CreateMigrationRecord(ctx context.Context, migrationRecord domain.MigrationRecord) error {
+	r.records[migrationRecord.Version.ID()] = migrationRecord
+	return nil
+}
+
+func (r *fakeRepository) GetMigrationRecord(ctx context.Context, ver domain.Version) (*domain.MigrationRecord, error) {
+	record, ok := r.records[ver.ID()]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (r *fakeRepository) GetMigrationRecords(ctx context.Context) ([]*domain.MigrationRecord, error) {
+	records := make([]*domain.MigrationRecord, 0, len(r.records))
+	for _, record := range r.records {
+		record := record
+		records = append(records, &record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Version.Less(records[j].Version)
+	})
+	return records, nil
+}
+
+func (r *fakeRepository) UpdateMigrationRecord(ctx context.Context, migrationRecord domain.MigrationRecord) error {
+	if _, ok := r.records[migrationRecord.Version.ID()]; !ok {
+		return fmt.Errorf("Migration record does not exist: %s", migrationRecord.Version)
+	}
+	r.records[migrationRecord.Version.ID()] = migrationRecord
+	return nil
+}
+
+func (r *fakeRepository) DeleteMigrationRecord(ctx context.Context, ver domain.Version) error {
+	if _, ok := r.records[ver.ID()]; !ok {
+		return fmt.Errorf("Migration record does not exist: %s", ver)
+	}
+	delete(r.records, ver.ID())
+	return nil
+}
+
+type fakeStorage struct {
+	migrations []*domain.Migration
+}
+
+func (s *fakeStorage) GetExecutableMigrations() ([]*domain.Migration, error) {
+	return s.migrations, nil
+}
+
+func (s *fakeStorage) CreateMigration(name string, content []byte) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+type fakeParser struct {
+	// backupBefore - tables backed up by the migration with the content.
+	backupBefore map[string][]string
+}
+
+func (p *fakeParser) ParseContent(content []byte) ([]*domain.DynamoDBQuery, error) {
+	return []*domain.DynamoDBQuery{{TableName: string(content)}}, nil
+}
+
+func (p *fakeParser) ParseMigration(content []byte) (*domain.DynamoDBMigration, error) {
+	queries, err := p.ParseContent(content)
+	return &domain.DynamoDBMigration{Queries: queries, BackupBefore: p.backupBefore[string(content)]}, err
+}
+
+func newTestMigration(major, minor, patch int) *domain.Migration {
+	ver := domain.Version{Major: major, Minor: minor, Patch: patch}
+	return &domain.Migration{
+		MigrationRecord: domain.MigrationRecord{
+			Version: ver,
+			Name:    ver.String() + "_test.json",
+		},
+		Content: []byte(ver.String()),
+	}
+}
+
+func newTestStorage() *fakeStorage {
+	storage := &fakeStorage{
+		migrations: []*domain.Migration{
+			newTestMigration(1, 1, 0),
+			newTestMigration(1, 0, 10),
+			newTestMigration(1, 0, 2),
+			newTestMigration(1, 0, 1),
+		},
+	}
+	for _, migration := range storage.migrations {
+		migration.Checksum = domain.ComputeChecksum(migration.Content)
+	}
+	return storage
+}
+
+func TestMigrate(t *testing.T) {
+	target := domain.Version{Major: 1, Minor: 0, Patch: 2}
+	unknown := domain.Version{Major: 9, Minor: 9, Patch: 9}
+	tests := []struct {
+		name        string
+		existing    []string
+		options     domain.MigrateOptions
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "Success: all pending migrations in version order",
+			options:  domain.MigrateOptions{},
+			expected: []string{"1.0.1", "1.0.2", "1.0.10", "1.1.0"},
+		},
+		{
+			name:     "Success: up to the target version",
+			options:  domain.MigrateOptions{Target: &target},
+			expected: []string{"1.0.1", "1.0.2"},
+		},
+		{
+			name:     "Success: limited number of steps",
+			existing: []string{"1.0.1"},
+			options:  domain.MigrateOptions{Steps: 2},
+			expected: []string{"1.0.2", "1.0.10"},
+		},
+		{
+			name:     "Success: steps within the target version",
+			options:  domain.MigrateOptions{Target: &target, Steps: 5},
+			expected: []string{"1.0.1", "1.0.2"},
+		},
+		{
+			name:        "Fail: unknown target version",
+			options:     domain.MigrateOptions{Target: &unknown},
+			expectError: true,
+		},
+		{
+			name:        "Fail: negative steps",
+			options:     domain.MigrateOptions{Steps: -1},
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository := newFakeRepository()
+			for _, id := range test.existing {
+				ver, _ := domain.ParseVersion(id)
+				repository.records[id] = domain.MigrationRecord{Version: ver}
+			}
+			service := NewMigrationService(repository, newTestStorage(), &fakeParser{})
+
+			report, err := service.Migrate(context.Background(), test.options)
+			if test.expectError {
+				if err == nil {
+					t.Error("expected error but got nothing")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			actual := make([]string, 0, len(repository.executed))
+			for _, queries := range repository.executed {
+				actual = append(actual, queries[0].TableName)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("actual applied versions: %v do not match expected: %v", actual, test.expected)
+			}
+			if applied := report.Count(domain.MigrationStatusSucceeded); applied != len(test.expected) {
+				t.Errorf("actual applied count: %d does not match expected: %d", applied, len(test.expected))
+			}
+		})
+	}
+}
+
+func TestMigrateFailedMigration(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{})
+	)
+
+	// The failed migration is recorded.
+	repository.failures["1.0.2"] = errors.New("transaction rejected")
+	report, err := service.Migrate(context.Background(), domain.MigrateOptions{})
+	if err == nil {
+		t.Error("expected error but got nothing")
+	}
+	if applied := report.Count(domain.MigrationStatusSucceeded); applied != 1 {
+		t.Errorf("actual applied count: %d does not match expected: 1", applied)
+	}
+	if len(report.Migrations) != 2 || report.Migrations[1].Status != domain.MigrationStatusFailed ||
+		report.Migrations[1].Error != "transaction rejected" {
+		t.Errorf("the failed migration must be reported: %+v", report.Migrations)
+	}
+	record := repository.records["1.0.2"]
+	if record.Status != domain.MigrationStatusFailed || record.Attempts != 1 || record.Error != "transaction rejected" {
+		t.Errorf("unexpected failed migration record: %+v", record)
+	}
+
+	// The run stops at the failed migration.
+	delete(repository.failures, "1.0.2")
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+	if len(repository.executed) != 1 {
+		t.Errorf("migrations after the failed one must not be executed, executed: %d", len(repository.executed))
+	}
+
+	// The failed migration is retried if allowed.
+	report, err = service.Migrate(context.Background(), domain.MigrateOptions{AllowFailed: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if applied := report.Count(domain.MigrationStatusSucceeded); applied != 3 {
+		t.Errorf("actual applied count: %d does not match expected: 3", applied)
+	}
+	record = repository.records["1.0.2"]
+	if record.Status != domain.MigrationStatusSucceeded || record.Attempts != 2 || len(record.Error) > 0 {
+		t.Errorf("unexpected retried migration record: %+v", record)
+	}
+}
+
+func TestMigrateMetrics(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		registry   = metrics.NewRegistry()
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{}, WithMetrics(registry))
+	)
+
+	// The failed migration stops the run, the following pending migrations are skipped.
+	repository.failures["1.0.2"] = errors.New("transaction rejected")
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+	var text strings.Builder
+	if err := registry.WriteText(&text); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, expected := range []string{
+		"migrations_applied_total 1\n",
+		"migrations_failed_total 1\n",
+		"migrations_skipped_total 2\n",
+		`migration_items_written{version="1.0.1",name="1.0.1_test.json"} 1` + "\n",
+		"# TYPE migration_lock_wait_seconds gauge\n",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("metrics must contain %q, actual:\n%s", expected, text.String())
+		}
+	}
+}
+
+func TestMigrateTracing(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		recorder   = tracetest.NewSpanRecorder()
+		provider   = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{},
+			WithTracer(provider.Tracer(tracing.InstrumentationName)))
+	)
+
+	// Every migration is a child span of the run, the failed one records the error.
+	repository.failures["1.0.2"] = errors.New("transaction rejected")
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("actual spans: %d do not match expected: 3", len(spans))
+	}
+	run := spans[2]
+	if run.Name() != "Migrate" || run.Status().Code != codes.Error {
+		t.Errorf("unexpected run span: %s, status: %v", run.Name(), run.Status())
+	}
+	for i, expected := range []struct {
+		version string
+		status  codes.Code
+	}{
+		{version: "1.0.1", status: codes.Unset},
+		{version: "1.0.2", status: codes.Error},
+	} {
+		span := spans[i]
+		attributes := attribute.NewSet(span.Attributes()...)
+		version, _ := attributes.Value(tracing.AttrVersion)
+		if span.Name() != "runMigration" || span.Parent().SpanID() != run.SpanContext().SpanID() ||
+			version.AsString() != expected.version || span.Status().Code != expected.status {
+			t.Errorf("unexpected migration span: %s, version: %s, status: %v", span.Name(), version.AsString(), span.Status())
+		}
+	}
+}
+
+// fakeNotifier - records notified events.
+type fakeNotifier struct {
+	notifications []*domain.RunNotification
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, notification *domain.RunNotification) {
+	n.notifications = append(n.notifications, notification)
+}
+
+func (n *fakeNotifier) events() []domain.RunEvent {
+	events := make([]domain.RunEvent, len(n.notifications))
+	for i, notification := range n.notifications {
+		events[i] = notification.Event
+	}
+	return events
+}
+
+func TestMigrateNotifications(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		notifier   = &fakeNotifier{}
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{},
+			WithNotifier(notifier), WithRunner(domain.Runner{Identity: "ci"}))
+	)
+
+	// The start and the end of the run are notified.
+	repository.failures["1.0.2"] = errors.New("transaction rejected")
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+	if expected := []domain.RunEvent{domain.RunEventStarted, domain.RunEventFailed}; !reflect.DeepEqual(notifier.events(), expected) {
+		t.Errorf("actual events: %v do not match expected: %v", notifier.events(), expected)
+	}
+	if started := notifier.notifications[0]; len(started.Pending) != 4 || started.Command != "up" || started.Runner != "ci" {
+		t.Errorf("unexpected start notification: %+v", started)
+	}
+	if failed := notifier.notifications[1]; len(failed.Migrations) != 2 || len(failed.Error) == 0 {
+		t.Errorf("unexpected failure notification: %+v", failed)
+	}
+
+	// Runs without pending migrations are not notified.
+	delete(repository.failures, "1.0.2")
+	notifier.notifications = nil
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{AllowFailed: true}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if expected := []domain.RunEvent{domain.RunEventStarted, domain.RunEventSucceeded}; !reflect.DeepEqual(notifier.events(), expected) {
+		t.Errorf("actual events: %v do not match expected: %v", notifier.events(), expected)
+	}
+
+	// Rollback is notified with the migrations to revert.
+	notifier.notifications = nil
+	if _, err := service.Rollback(context.Background(), domain.RollbackOptions{Steps: 2}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if started := notifier.notifications[0]; started.Command != "down" || len(started.Pending) != 2 || started.Pending[0].Name != "1.1.0_test.json" {
+		t.Errorf("unexpected rollback start notification: %+v", started)
+	}
+}
+
+// hookRecorder - records hook events as "stage version" lines, the hook fails for the failing stage and version.
+type hookRecorder struct {
+	calls   []string
+	events  []*domain.HookEvent
+	failing string
+}
+
+func (r *hookRecorder) options() []Option {
+	var options []Option
+	for _, stage := range domain.HookStages {
+		options = append(options, WithHook(stage, r.hook))
+	}
+	return options
+}
+
+func (r *hookRecorder) hook(ctx context.Context, event *domain.HookEvent) error {
+	call := string(event.Stage)
+	if event.Migration != nil {
+		call += " " + event.Migration.Version.String()
+	}
+	r.calls = append(r.calls, call)
+	r.events = append(r.events, event)
+	if call == r.failing {
+		return errors.New("consumer cannot be paused")
+	}
+	return nil
+}
+
+func TestMigrateHooks(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name          string
+		failing       string
+		failure       string
+		expectedCalls []string
+		expectedKind  domain.ErrorKind
+		// expectedRecords - versions of migration records after the run.
+		expectedRecords []string
+	}{
+		{
+			name: "Success: hooks run around migrations",
+			expectedCalls: []string{
+				"before_all",
+				"before_each 1.0.1", "after_each 1.0.1",
+				"before_each 1.0.2", "after_each 1.0.2",
+				"before_each 1.0.10", "after_each 1.0.10",
+				"before_each 1.1.0", "after_each 1.1.0",
+				"after_all",
+			},
+			expectedRecords: []string{"1.0.1", "1.0.2", "1.0.10", "1.1.0"},
+		},
+		{
+			name:            "Fail: before_all hook",
+			failing:         "before_all",
+			expectedCalls:   []string{"before_all", "on_error"},
+			expectedKind:    domain.ErrorKindHook,
+			expectedRecords: []string{},
+		},
+		{
+			name:    "Fail: before_each hook keeps the migration pending",
+			failing: "before_each 1.0.2",
+			expectedCalls: []string{
+				"before_all",
+				"before_each 1.0.1", "after_each 1.0.1",
+				"before_each 1.0.2",
+				"on_error 1.0.2",
+			},
+			expectedKind:    domain.ErrorKindHook,
+			expectedRecords: []string{"1.0.1"},
+		},
+		{
+			name:    "Fail: after_each hook stops the run",
+			failing: "after_each 1.0.1",
+			expectedCalls: []string{
+				"before_all",
+				"before_each 1.0.1", "after_each 1.0.1",
+				"on_error",
+			},
+			expectedKind:    domain.ErrorKindHook,
+			expectedRecords: []string{"1.0.1"},
+		},
+		{
+			name:    "Fail: migration",
+			failure: "1.0.2",
+			expectedCalls: []string{
+				"before_all",
+				"before_each 1.0.1", "after_each 1.0.1",
+				"before_each 1.0.2",
+				"on_error 1.0.2",
+			},
+			expectedKind:    domain.ErrorKindInternal,
+			expectedRecords: []string{"1.0.1", "1.0.2"},
+		},
+		{
+			name:    "Fail: on_error hook errors are not returned",
+			failing: "on_error",
+			failure: "1.0.1",
+			expectedCalls: []string{
+				"before_all",
+				"before_each 1.0.1",
+				"on_error 1.0.1",
+			},
+			expectedKind:    domain.ErrorKindInternal,
+			expectedRecords: []string{"1.0.1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				repository = newFakeRepository()
+				recorder   = &hookRecorder{failing: test.failing}
+				service    = NewMigrationService(repository, newTestStorage(), &fakeParser{}, recorder.options()...)
+			)
+			if len(test.failure) > 0 {
+				repository.failures[test.failure] = errors.New("transaction rejected")
+			}
+			_, err := service.Migrate(context.Background(), domain.MigrateOptions{})
+			if kind := domain.ErrorKindOf(err); kind != test.expectedKind {
+				t.Errorf("actual error kind: %q does not match expected: %q, error: %v", kind, test.expectedKind, err)
+			}
+			if !reflect.DeepEqual(recorder.calls, test.expectedCalls) {
+				t.Errorf("actual hook calls: %v do not match expected: %v", recorder.calls, test.expectedCalls)
+			}
+			records := make([]string, 0, len(repository.records))
+			for _, record := range repository.records {
+				records = append(records, record.Version.String())
+			}
+			sort.Slice(records, func(i, j int) bool {
+				return mustVersion(t, records[i]).Less(mustVersion(t, records[j]))
+			})
+			if !reflect.DeepEqual(records, test.expectedRecords) {
+				t.Errorf("actual records: %v do not match expected: %v", records, test.expectedRecords)
+			}
+			if err != nil {
+				onError := recorder.events[len(recorder.events)-1]
+				if len(onError.Pending) != 4 || onError.Error != err.Error() {
+					t.Errorf("unexpected on_error event: %+v", onError)
+				}
+			}
+		})
+	}
+
+	// Hooks do not run if no migration is pending.
+	var (
+		repository = newFakeRepository()
+		recorder   = &hookRecorder{}
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{}, recorder.options()...)
+	)
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	recorder.calls = nil
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(recorder.calls) > 0 {
+		t.Errorf("hooks must not run without pending migrations, calls: %v", recorder.calls)
+	}
+}
+
+func mustVersion(t *testing.T, s string) domain.Version {
+	version, err := domain.ParseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+// fakeBackuper - returns backups of tables, the backup of the failing table fails.
+type fakeBackuper struct {
+	failing string
+}
+
+func (b *fakeBackuper) BackupTables(ctx context.Context, tables []string, version domain.Version) ([]domain.TableBackup, error) {
+	var backups []domain.TableBackup
+	for _, table := range tables {
+		if table == b.failing {
+			return backups, domain.Errorf(domain.ErrorKindAWS, "TableNotFoundException: %s", table)
+		}
+		backups = append(backups, domain.TableBackup{
+			TableName:  table,
+			BackupName: table + "-" + version.String(),
+			BackupArn:  "arn:aws:dynamodb:eu-west-1:123456789012:table/" + table + "/backup/" + version.String(),
+		})
+	}
+	return backups, nil
+}
+
+func TestMigrateBackups(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		parser     = &fakeParser{backupBefore: map[string][]string{"1.0.2": {"users", "roles"}}}
+		backuper   = &fakeBackuper{failing: "roles"}
+		service    = NewMigrationService(repository, newTestStorage(), parser, WithBackuper(backuper))
+	)
+
+	// The plan lists backups.
+	plan, err := service.Plan(context.Background(), domain.MigrateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(plan[1].Backups, []string{"users", "roles"}) {
+		t.Errorf("actual planned backups: %v do not match expected: [users roles]", plan[1].Backups)
+	}
+
+	// A failed backup fails the migration before its queries are executed, created backups are recorded.
+	report, err := service.Migrate(context.Background(), domain.MigrateOptions{})
+	if kind := domain.ErrorKindOf(err); kind != domain.ErrorKindAWS {
+		t.Errorf("actual error kind: %q does not match expected: %q, error: %v", kind, domain.ErrorKindAWS, err)
+	}
+	if len(repository.executed) != 1 {
+		t.Errorf("queries of the migration must not be executed, executed: %d", len(repository.executed))
+	}
+	record := repository.records["1.0.2"]
+	if record.Status != domain.MigrationStatusFailed || len(record.Metadata.Backups) != 1 || len(report.Migrations[1].Backups) != 1 {
+		t.Errorf("unexpected failed migration record: %+v", record)
+	}
+
+	// Backups are recorded before the queries are executed.
+	backuper.failing = ""
+	var recorded []domain.TableBackup
+	repository.onExecute = func(ctx context.Context) {
+		if record := repository.records["1.0.2"]; record.Status == domain.MigrationStatusInProgress {
+			recorded = record.Metadata.Backups
+		}
+	}
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{AllowFailed: true}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(recorded) != 2 || recorded[1].BackupArn != "arn:aws:dynamodb:eu-west-1:123456789012:table/roles/backup/1.0.2" {
+		t.Errorf("backups must be recorded before the queries are executed, recorded: %+v", recorded)
+	}
+
+	// Status shows how to restore the backups.
+	infos, err := service.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "aws dynamodb restore-table-from-backup --target-table-name users-restored --backup-arn arn:aws:dynamodb:eu-west-1:123456789012:table/users/backup/1.0.2"
+	if hints := infos[1].RestoreHints; len(hints) != 2 || hints[0] != expected {
+		t.Errorf("actual restore hints: %v, expected first: %s", hints, expected)
+	}
+}
+
+func TestMigrateBackupsNotConfigured(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		parser     = &fakeParser{backupBefore: map[string][]string{"1.0.1": {"users"}}}
+		service    = NewMigrationService(repository, newTestStorage(), parser)
+	)
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); domain.ErrorKindOf(err) != domain.ErrorKindConfig {
+		t.Errorf("expected config error, got: %v", err)
+	}
+	if len(repository.executed) > 0 {
+		t.Errorf("queries must not be executed without backups, executed: %d", len(repository.executed))
+	}
+}
+
+func TestMigrateCanceled(t *testing.T) {
+	var (
+		repository  = newFakeRepository()
+		service     = NewMigrationService(repository, newTestStorage(), &fakeParser{})
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	defer cancel()
+
+	// The running migration is completed, the run stops before the next one.
+	repository.onExecute = func(context.Context) {
+		cancel()
+	}
+	report, err := service.Migrate(ctx, domain.MigrateOptions{})
+	if kind := domain.ErrorKindOf(err); kind != domain.ErrorKindCanceled {
+		t.Errorf("actual error kind: %q does not match expected: %q, error: %v", kind, domain.ErrorKindCanceled, err)
+	}
+	if applied := report.Count(domain.MigrationStatusSucceeded); applied != 1 || len(report.Migrations) != 1 {
+		t.Errorf("only the running migration must be applied: %+v", report.Migrations)
+	}
+	if record := repository.records["1.0.1"]; !record.IsSucceeded() {
+		t.Errorf("the running migration must be recorded as succeeded: %+v", record)
+	}
+	if repository.lock != nil {
+		t.Errorf("lock must be released, actual: %v", repository.lock)
+	}
+
+	// The expired deadline stops the running migration.
+	repository.onExecute = nil
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if _, err := service.Migrate(ctx, domain.MigrateOptions{}); domain.ErrorKindOf(err) != domain.ErrorKindCanceled {
+		t.Errorf("expected canceled error, actual: %v", err)
+	}
+	if len(repository.executed) != 1 {
+		t.Errorf("migrations must not be executed after the deadline, executed: %d", len(repository.executed))
+	}
+}
+
+func TestMigrateConsumedCapacity(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		prices     = domain.UnitPrices{ReadPerMillion: 1, WritePerMillion: 2}
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{}, WithUnitPrices(prices))
+	)
+
+	// Every migration consumes capacity in its own meter.
+	repository.onExecute = func(ctx context.Context) {
+		meter := domain.CapacityMeterFrom(ctx)
+		meter.Add("users", domain.TableCapacity{ReadUnits: 1, WriteUnits: 2})
+		meter.Add("roles", domain.TableCapacity{WriteUnits: 4})
+	}
+	report, err := service.Migrate(context.Background(), domain.MigrateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := domain.ConsumedCapacity{
+		"users": {ReadUnits: 1, WriteUnits: 2},
+		"roles": {WriteUnits: 4},
+	}
+	for _, result := range report.Migrations {
+		if !reflect.DeepEqual(result.ConsumedCapacity, expected) {
+			t.Errorf("actual capacity: %v does not match expected: %v", result.ConsumedCapacity, expected)
+		}
+		if result.EstimatedCost != 13e-6 {
+			t.Errorf("actual cost: %v does not match expected: %v", result.EstimatedCost, 13e-6)
+		}
+		if record := repository.records[result.Version.ID()]; !reflect.DeepEqual(record.Metadata.ConsumedCapacity, expected) {
+			t.Errorf("capacity must be stored in the migration record: %+v", record)
+		}
+	}
+	total := domain.ConsumedCapacity{
+		"users": {ReadUnits: 4, WriteUnits: 8},
+		"roles": {WriteUnits: 16},
+	}
+	if !reflect.DeepEqual(report.ConsumedCapacity, total) {
+		t.Errorf("actual total capacity: %v does not match expected: %v", report.ConsumedCapacity, total)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	repository := newFakeRepository()
+	repository.records["1.0.1"] = domain.MigrationRecord{Version: domain.Version{Major: 1, Minor: 0, Patch: 1}}
+	storage := &fakeStorage{
+		migrations: []*domain.Migration{
+			newTestMigration(1, 1, 0),
+			newTestMigration(1, 0, 2),
+			newTestMigration(1, 0, 1),
+		},
+	}
+	service := NewMigrationService(repository, storage, &fakeParser{})
+
+	baselined, err := service.Baseline(context.Background(), domain.Version{Major: 1, Minor: 0, Patch: 2})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if baselined != 1 {
+		t.Errorf("actual baselined count: %d does not match expected: 1", baselined)
+	}
+	if len(repository.executed) != 0 {
+		t.Errorf("baseline must not execute migrations, executed: %d", len(repository.executed))
+	}
+	if record, ok := repository.records["1.0.2"]; !ok || !record.Baselined {
+		t.Errorf("migration record 1.0.2 must be created as baselined")
+	}
+	if _, ok := repository.records["1.1.0"]; ok {
+		t.Errorf("migration record 1.1.0 must not be created")
+	}
+}
+
+func TestMarkAppliedAndUnmark(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{})
+		ver        = domain.Version{Major: 1, Minor: 0, Patch: 2}
+	)
+
+	// Mark a failed migration as applied.
+	repository.records[ver.ID()] = domain.MigrationRecord{Version: ver, Status: domain.MigrationStatusFailed}
+	if err := service.MarkApplied(context.Background(), ver); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if record := repository.records[ver.ID()]; !record.IsSucceeded() || len(record.Checksum) == 0 {
+		t.Errorf("migration record must be succeeded with a checksum, actual: %+v", record)
+	}
+	if len(repository.executed) != 0 {
+		t.Errorf("mark-applied must not execute migrations, executed: %d", len(repository.executed))
+	}
+
+	// Applied migrations cannot be marked again.
+	if err := service.MarkApplied(context.Background(), ver); err == nil {
+		t.Error("expected error but got nothing")
+	}
+
+	// Unknown migrations cannot be marked.
+	if err := service.MarkApplied(context.Background(), domain.Version{Major: 9}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+
+	// Unmark the migration.
+	if err := service.Unmark(context.Background(), ver); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, ok := repository.records[ver.ID()]; ok {
+		t.Errorf("migration record must be removed for %v", ver)
+	}
+	if err := service.Unmark(context.Background(), ver); err == nil {
+		t.Error("expected error but got nothing")
+	}
+}
+
+func TestRepair(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		storage    = newTestStorage()
+		service    = NewMigrationService(repository, storage, &fakeParser{})
+	)
+	repository.records["1.0.1"] = domain.MigrationRecord{Version: domain.Version{Major: 1, Minor: 0, Patch: 1}, Checksum: "outdated"}
+	repository.records["1.0.2"] = domain.MigrationRecord{Version: domain.Version{Major: 1, Minor: 0, Patch: 2}, Status: domain.MigrationStatusFailed}
+	repository.records["1.0.10"] = domain.MigrationRecord{Version: domain.Version{Major: 1, Minor: 0, Patch: 10}, Checksum: storage.migrations[1].Checksum}
+
+	repaired, err := service.Repair(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if repaired != 2 {
+		t.Errorf("actual repaired count: %d does not match expected: 2", repaired)
+	}
+	if record := repository.records["1.0.1"]; record.Checksum != domain.ComputeChecksum([]byte("1.0.1")) {
+		t.Errorf("checksum must be recomputed, actual: %s", record.Checksum)
+	}
+	if _, ok := repository.records["1.0.2"]; ok {
+		t.Error("failed migration record must be removed")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		runner     = domain.Runner{AppVersion: "0.1.0", Hostname: "host", Identity: "arn:aws:iam::123456789012:user/test"}
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{}, WithRunner(runner))
+	)
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{Steps: 1}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	repository.records["0.9.0"] = domain.MigrationRecord{Version: domain.Version{Major: 0, Minor: 9, Patch: 0}, Checksum: "removed"}
+
+	infos, err := service.Status(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	actual := make([]string, len(infos))
+	for i, info := range infos {
+		actual[i] = info.Version.ID()
+	}
+	expected := []string{"0.9.0", "1.0.1", "1.0.2", "1.0.10", "1.1.0"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual versions: %v do not match expected: %v", actual, expected)
+	}
+	if infos[0].Record == nil || len(infos[0].FileChecksum) > 0 {
+		t.Errorf("migration without a file must have a record only: %+v", infos[0])
+	}
+	applied := infos[1].Record
+	if applied == nil || applied.Metadata.Runner != runner.Identity || applied.Metadata.AppVersion != runner.AppVersion ||
+		applied.Metadata.Hostname != runner.Hostname || applied.Metadata.ItemsWritten != 1 {
+		t.Errorf("unexpected applied migration record: %+v", applied)
+	}
+	if infos[2].Record != nil {
+		t.Errorf("pending migration must not have a record: %+v", infos[2])
+	}
+}
+
+func TestPlan(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{})
+	)
+	repository.records["1.0.1"] = domain.MigrationRecord{Version: domain.Version{Major: 1, Minor: 0, Patch: 1}}
+	repository.records["1.0.2"] = domain.MigrationRecord{Version: domain.Version{Major: 1, Minor: 0, Patch: 2}, Status: domain.MigrationStatusFailed}
+
+	plan, err := service.Plan(context.Background(), domain.MigrateOptions{Steps: 2, AllowFailed: true})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(plan) != 2 || plan[0].Version.ID() != "1.0.2" || !plan[0].Retry || plan[1].Version.ID() != "1.0.10" || plan[1].Retry {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	if len(repository.executed) != 0 {
+		t.Errorf("plan must not execute migrations, executed: %d", len(repository.executed))
+	}
+	if _, err := service.Plan(context.Background(), domain.MigrateOptions{}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{})
+	)
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	report, err := service.Rollback(context.Background(), domain.RollbackOptions{Target: &domain.Version{Major: 1, Minor: 0, Patch: 2}})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if reverted := report.Count(domain.MigrationStatusReverted); reverted != 2 {
+		t.Errorf("actual reverted count: %d does not match expected: 2", reverted)
+	}
+	actual := make([]string, 0, len(repository.reverted))
+	for _, queries := range repository.reverted {
+		actual = append(actual, queries[0].TableName)
+	}
+	if expected := []string{"1.1.0", "1.0.10"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("actual reverted versions: %v do not match expected: %v", actual, expected)
+	}
+	if _, ok := repository.records["1.0.10"]; ok {
+		t.Error("reverted migration record must be removed")
+	}
+
+	// Baselined migrations cannot be reverted.
+	record := repository.records["1.0.2"]
+	record.Baselined = true
+	repository.records["1.0.2"] = record
+	if _, err := service.Rollback(context.Background(), domain.RollbackOptions{Steps: 1}); err == nil {
+		t.Error("expected error but got nothing")
+	}
+}
+
+func TestLock(t *testing.T) {
+	var (
+		repository = newFakeRepository()
+		service    = NewMigrationService(repository, newTestStorage(), &fakeParser{})
+		held       = domain.NewLock("another", time.Now(), time.Hour)
+	)
+	repository.lock = &held
+
+	_, err := service.Migrate(context.Background(), domain.MigrateOptions{})
+	var lockErr *domain.LockHeldError
+	if !errors.As(err, &lockErr) {
+		t.Errorf("expected lock held error, actual: %v", err)
+	}
+	if len(repository.executed) != 0 {
+		t.Errorf("migrations must not be executed without the lock, executed: %d", len(repository.executed))
+	}
+
+	lock, err := service.Unlock(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if lock == nil || lock.Owner != "another" {
+		t.Errorf("unexpected removed lock: %v", lock)
+	}
+	if _, err := service.Migrate(context.Background(), domain.MigrateOptions{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if repository.lock != nil {
+		t.Errorf("lock must be released, actual: %v", repository.lock)
+	}
+}
+
+func TestLockRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// takeOver - another run takes over the lock during the first migration.
+		takeOver         bool
+		expectedExecuted int
+		expectedKind     domain.ErrorKind
+	}{
+		{
+			name:             "Success: lock is refreshed during a long migration",
+			expectedExecuted: 4,
+		},
+		{
+			name:             "Fail: run is stopped when the lock is taken over",
+			takeOver:         true,
+			expectedExecuted: 1,
+			expectedKind:     domain.ErrorKindLockHeld,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				repository = newFakeRepository()
+				service    = NewMigrationService(repository, newTestStorage(), &fakeParser{}, WithLockTTL(30*time.Millisecond))
+				executions int
+			)
+			repository.onExecute = func(ctx context.Context) {
+				if executions++; executions > 1 {
+					return
+				}
+				if test.takeOver {
+					repository.takeOverLock("another")
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			_, err := service.Migrate(context.Background(), domain.MigrateOptions{})
+			if kind := domain.ErrorKindOf(err); kind != test.expectedKind {
+				t.Errorf("actual error kind: %q does not match expected: %q, error: %v", kind, test.expectedKind, err)
+			}
+			if len(repository.executed) != test.expectedExecuted {
+				t.Errorf("actual executed: %d does not match expected: %d", len(repository.executed), test.expectedExecuted)
+			}
+			if !test.takeOver && repository.refreshes == 0 {
+				t.Error("lock must be refreshed during the run")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// requestTimeout - time to deliver a notification to a webhook.
+const requestTimeout = 10 * time.Second
+
+// webhook - webhook with its parsed payload template.
+type webhook struct {
+	domain.Webhook
+	template *template.Template
+}
+
+type notifier struct {
+	webhooks    []*webhook
+	environment string
+	client      *http.Client
+	logger      domain.Logger
+}
+
+// NewNotifier - constructs a notifier posting to the webhooks, notifications carry the environment name.
+// Returns a config error if a payload template cannot be parsed.
+func NewNotifier(webhooks []domain.Webhook, environment string, client *http.Client, logger domain.Logger) (domain.Notifier, error) {
+	n := &notifier{
+		environment: environment,
+		client:      client,
+		logger:      logger,
+	}
+	for i, config := range webhooks {
+		w := &webhook{Webhook: config}
+		if len(config.Template) > 0 {
+			tmpl, err := template.New(fmt.Sprintf("webhook %d", i+1)).Funcs(templateFuncs).Parse(config.Template)
+			if err != nil {
+				return nil, domain.Errorf(domain.ErrorKindConfig, "Invalid webhook template: %v", err)
+			}
+			w.template = tmpl
+		}
+		n.webhooks = append(n.webhooks, w)
+	}
+	return n, nil
+}
+
+// NewNopNotifier - constructs a notifier discarding all notifications.
+func NewNopNotifier() domain.Notifier {
+	return &notifier{}
+}
+
+func (n *notifier) Notify(ctx context.Context, notification *domain.RunNotification) {
+	notification.Environment = n.environment
+	for _, w := range n.webhooks {
+		if !w.IsNotified(notification.Event) {
+			continue
+		}
+		if err := n.post(ctx, w, notification); err != nil {
+			n.logger.Warn("Cannot send notification", domain.F("webhook", redact(w.URL)),
+				domain.F("event", notification.Event), domain.F("error", err))
+		}
+	}
+}
+
+func (n *notifier) post(ctx context.Context, w *webhook, notification *domain.RunNotification) error {
+	payload, err := w.payload(notification)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The error contains the URL, webhook URLs of chats are secrets.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Webhook responded %s %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// payload - renders the template of the webhook or the payload of its format.
+func (w *webhook) payload(notification *domain.RunNotification) ([]byte, error) {
+	switch {
+	case w.template != nil:
+		var b bytes.Buffer
+		if err := w.template.Execute(&b, notification); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case w.Format == domain.WebhookFormatSlack:
+		return json.Marshal(slackPayload(notification))
+	default:
+		return json.Marshal(notification)
+	}
+}
+
+// redact - returns the scheme and host of the URL, the path and query of chat webhooks contain secrets.
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid URL"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,107 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/logging"
+)
+
+func TestNotify(t *testing.T) {
+	failed := &domain.RunNotification{
+		Event:      domain.RunEventFailed,
+		Command:    "up",
+		Runner:     "ci",
+		StartTime:  time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC),
+		DurationMs: 2500,
+		Migrations: []*domain.MigrationResult{
+			{Version: domain.Version{Major: 1}, Name: "1.0.0_users.json", Status: domain.MigrationStatusSucceeded, DurationMs: 1500},
+			{Version: domain.Version{Major: 1, Patch: 1}, Name: "1.0.1_roles.json", Status: domain.MigrationStatusFailed, DurationMs: 1000, Error: "transaction rejected"},
+		},
+		Error: "Migration failed",
+	}
+
+	// Test.
+	tests := []struct {
+		name         string
+		webhook      domain.Webhook
+		status       int
+		expectedBody string
+		expectedLog  string
+	}{
+		{
+			name:         "Success: slack payload",
+			webhook:      domain.Webhook{Format: domain.WebhookFormatSlack},
+			status:       http.StatusOK,
+			expectedBody: `{"text":"Migrations up failed in prod by ci: 2 migrations in 2.5s","attachments":[{"color":"danger","text":"• ` + "`1.0.0`" + ` 1.0.0_users.json *succeeded* in 1.5s\n• ` + "`1.0.1`" + ` 1.0.1_roles.json *failed* in 1s: transaction rejected\nError: Migration failed"}]}`,
+		},
+		{
+			name:         "Success: generic JSON payload",
+			webhook:      domain.Webhook{Format: domain.WebhookFormatJSON},
+			status:       http.StatusOK,
+			expectedBody: `{"event":"failed","command":"up","environment":"prod","runner":"ci","hostname":"","start_time":"2021-09-01T10:00:00Z","duration_ms":2500,"migrations":[`,
+		},
+		{
+			name:         "Success: custom template",
+			webhook:      domain.Webhook{Template: `{"title":{{json (summary .)}},"failed":{{len .Migrations}}}`},
+			status:       http.StatusOK,
+			expectedBody: `{"title":"Migrations up failed in prod by ci: 2 migrations in 2.5s","failed":2}`,
+		},
+		{
+			name:    "Success: event not notified",
+			webhook: domain.Webhook{Format: domain.WebhookFormatJSON, Events: []domain.RunEvent{domain.RunEventSucceeded}},
+			status:  http.StatusOK,
+		},
+		{
+			name:         "Fail: rejected by the webhook",
+			webhook:      domain.Webhook{Format: domain.WebhookFormatJSON},
+			status:       http.StatusForbidden,
+			expectedBody: `{"event":"failed"`,
+			expectedLog:  "Cannot send notification",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				content, _ := ioutil.ReadAll(r.Body)
+				body = string(content)
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			var logs bytes.Buffer
+			test.webhook.URL = server.URL + "/hooks/secret"
+			n, err := NewNotifier([]domain.Webhook{test.webhook}, "prod", server.Client(),
+				logging.NewLogger(&logs, domain.LogLevelInfo, logging.FormatText))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			notification := *failed
+			n.Notify(context.Background(), &notification)
+			if !strings.HasPrefix(body, test.expectedBody) || (len(test.expectedBody) == 0 && len(body) > 0) {
+				t.Errorf("actual body:\n%s\ndoes not match expected:\n%s", body, test.expectedBody)
+			}
+			if !strings.Contains(logs.String(), test.expectedLog) {
+				t.Errorf("actual logs: %q must contain %q", logs.String(), test.expectedLog)
+			}
+			if strings.Contains(logs.String(), "secret") {
+				t.Errorf("webhook URL path must not be logged: %s", logs.String())
+			}
+		})
+	}
+}
+
+func TestNewNotifierInvalidTemplate(t *testing.T) {
+	webhooks := []domain.Webhook{{URL: "http://localhost/hook", Template: "{{.Missing"}}
+	if _, err := NewNotifier(webhooks, "prod", http.DefaultClient, logging.NewNopLogger()); domain.ErrorKindOf(err) != domain.ErrorKindConfig {
+		t.Errorf("expected config error, actual: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// templateFuncs - functions of webhook templates, json quotes values in JSON payloads.
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		content, err := json.Marshal(value)
+		return string(content), err
+	},
+	"summary": summary,
+}
+
+// slackMessage - message of a Slack incoming webhook, also accepted by Mattermost and Rocket.Chat.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color string `json:"color"`
+	Text  string `json:"text"`
+}
+
+// Colors of Slack attachments.
+const (
+	slackColorStarted   = "#439FE0"
+	slackColorSucceeded = "good"
+	slackColorFailed    = "danger"
+)
+
+func slackPayload(notification *domain.RunNotification) slackMessage {
+	var (
+		color string
+		lines []string
+	)
+	switch notification.Event {
+	case domain.RunEventStarted:
+		color = slackColorStarted
+		for _, pending := range notification.Pending {
+			lines = append(lines, fmt.Sprintf("• `%s` %s", pending.Version, pending.Name))
+		}
+	case domain.RunEventSucceeded:
+		color = slackColorSucceeded
+	default:
+		color = slackColorFailed
+	}
+	for _, result := range notification.Migrations {
+		line := fmt.Sprintf("• `%s` %s *%s* in %s", result.Version, result.Name, result.Status, formatDuration(result.DurationMs))
+		if len(result.Error) > 0 {
+			line += ": " + result.Error
+		}
+		lines = append(lines, line)
+	}
+	if len(notification.Error) > 0 {
+		lines = append(lines, "Error: "+notification.Error)
+	}
+	message := slackMessage{Text: summary(notification)}
+	if len(lines) > 0 {
+		message.Attachments = []slackAttachment{{Color: color, Text: strings.Join(lines, "\n")}}
+	}
+	return message
+}
+
+// summary - returns a one-line summary, e.g. "Migrations up succeeded in prod by ci: 3 migrations in 12.3s".
+func summary(notification *domain.RunNotification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Migrations %s %s in %s", notification.Command, notification.Event, notification.Environment)
+	if len(notification.Runner) > 0 {
+		fmt.Fprintf(&b, " by %s", notification.Runner)
+	}
+	switch notification.Event {
+	case domain.RunEventStarted:
+		fmt.Fprintf(&b, ": %d pending", len(notification.Pending))
+	default:
+		fmt.Fprintf(&b, ": %d migrations in %s", len(notification.Migrations), formatDuration(notification.DurationMs))
+	}
+	return b.String()
+}
+
+func formatDuration(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"dynamodb.data-migration/internal/domain"
+)
+
+type prefixedParser struct {
+	queryParser domain.QueryParser
+	prefix      string
+}
+
+// NewPrefixedQueryParser - constructs a query parser adding the prefix to table names, e.g. for environments sharing an account.
+func NewPrefixedQueryParser(queryParser domain.QueryParser, prefix string) domain.QueryParser {
+	if len(prefix) == 0 {
+		return queryParser
+	}
+	return &prefixedParser{
+		queryParser: queryParser,
+		prefix:      prefix,
+	}
+}
+
+func (p *prefixedParser) ParseContent(content []byte) ([]*domain.DynamoDBQuery, error) {
+	migration, err := p.ParseMigration(content)
+	if err != nil {
+		return nil, err
+	}
+	return migration.Queries, nil
+}
+
+func (p *prefixedParser) ParseMigration(content []byte) (*domain.DynamoDBMigration, error) {
+	migration, err := p.queryParser.ParseMigration(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range migration.Queries {
+		if len(q.TableName) > 0 {
+			q.TableName = p.prefix + q.TableName
+		}
+	}
+	for i, table := range migration.BackupBefore {
+		if len(table) > 0 {
+			migration.BackupBefore[i] = p.prefix + table
+		}
+	}
+	return migration, nil
+}
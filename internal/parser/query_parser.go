@@ -18,22 +18,96 @@ func NewQueryParser() domain.QueryParser {
 }
 
 func (p *parser) ParseContent(content []byte) ([]*domain.DynamoDBQuery, error) {
+	migration, err := p.ParseMigration(content)
+	if err != nil {
+		return nil, err
+	}
+	return migration.Queries, nil
+}
+
+func (p *parser) ParseMigration(content []byte) (*domain.DynamoDBMigration, error) {
+	migration, err := p.parseMigration(content)
+	return migration, domain.NewError(domain.ErrorKindParse, err)
+}
+
+// parseMigration - parses the array of queries or the migration object with the queries and options.
+func (p *parser) parseMigration(content []byte) (*domain.DynamoDBMigration, error) {
 	if len(content) == 0 {
 		return nil, errors.New("Cannot parse empty query content")
 	}
-	var mapSlice []map[string]interface{}
-	if err := json.Unmarshal(content, &mapSlice); err != nil {
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, syntaxError(content, err)
+	}
+	switch value := document.(type) {
+	case []interface{}:
+		queries, err := parseQueries(value, "")
+		if err != nil {
+			return nil, err
+		}
+		return &domain.DynamoDBMigration{Queries: queries}, nil
+	case map[string]interface{}:
+		return parseMigrationObject(value)
+	default:
+		return nil, parseError("", "Expected an array of queries or a migration object, got %s", jsonType(document))
+	}
+}
+
+func parseMigrationObject(m map[string]interface{}) (*domain.DynamoDBMigration, error) {
+	migration := &domain.DynamoDBMigration{QueriesPath: "/" + domain.JSONFieldQueries}
+	for field := range m {
+		switch field {
+		case domain.JSONFieldBackupBefore, domain.JSONFieldQueries:
+		default:
+			// Typos must not skip options, e.g. backups.
+			return nil, parseError("/"+field, "Unknown field, expected %s or %s", domain.JSONFieldBackupBefore, domain.JSONFieldQueries)
+		}
+	}
+	if value, ok := m[domain.JSONFieldBackupBefore]; ok {
+		err := forEach(value, "/"+domain.JSONFieldBackupBefore, func(item interface{}, itemPath string) error {
+			table, ok := convertToString(item)
+			if !ok {
+				return parseError(itemPath, "Expected a table name string, got %s", jsonType(item))
+			}
+			migration.BackupBefore = append(migration.BackupBefore, table)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	list, ok := m[domain.JSONFieldQueries].([]interface{})
+	if !ok {
+		return nil, parseError(migration.QueriesPath, "Expected an array of queries, got %s", jsonType(m[domain.JSONFieldQueries]))
+	}
+	queries, err := parseQueries(list, migration.QueriesPath)
+	if err != nil {
 		return nil, err
 	}
-	result := make([]*domain.DynamoDBQuery, len(mapSlice))
-	for i, m := range mapSlice {
+	migration.Queries = queries
+	return migration, nil
+}
+
+// parseQueries - parses the array of queries, listPath is the JSON pointer of the array.
+func parseQueries(list []interface{}, listPath string) ([]*domain.DynamoDBQuery, error) {
+	result := make([]*domain.DynamoDBQuery, len(list))
+	for i, item := range list {
+		path := fmt.Sprintf("%s/%d", listPath, i)
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, parseError(path, "Expected a query object, got %s", jsonType(item))
+		}
 		tableName, ok := convertToString(m[domain.JSONFieldTableName])
 		if !ok {
-			return nil, errors.New("Cannot parse table name")
+			return nil, parseError(path+"/"+domain.JSONFieldTableName, "Expected a table name string, got %s", jsonType(m[domain.JSONFieldTableName]))
 		}
 		schema := []*domain.DynamoDBSchema{}
-		if _, ok := m[domain.JSONFieldSchema]; ok {
-			err := fillStruct(&schema, m[domain.JSONFieldSchema])
+		if value, ok := m[domain.JSONFieldSchema]; ok {
+			err := forEach(value, path+"/"+domain.JSONFieldSchema, func(item interface{}, itemPath string) error {
+				s, err := parseSchema(item, itemPath)
+				schema = append(schema, s)
+				return err
+			})
 			if err != nil {
 				return nil, err
 			}
@@ -42,7 +116,7 @@ func (p *parser) ParseContent(content []byte) ([]*domain.DynamoDBQuery, error) {
 		if _, ok := m[domain.JSONFieldData]; ok {
 			data, ok = convertToSliceMap(m[domain.JSONFieldData])
 			if !ok {
-				return nil, fmt.Errorf("Cannot parse data for %s", tableName)
+				return nil, parseError(path+"/"+domain.JSONFieldData, "Expected an object or an array of objects for %s", tableName)
 			}
 		}
 		result[i] = &domain.DynamoDBQuery{
@@ -54,6 +128,150 @@ func (p *parser) ParseContent(content []byte) ([]*domain.DynamoDBQuery, error) {
 	return result, nil
 }
 
+func parseSchema(value interface{}, path string) (*domain.DynamoDBSchema, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, parseError(path, "Expected a schema object, got %s", jsonType(value))
+	}
+	schema := &domain.DynamoDBSchema{}
+	if value, ok := m["attribute_definitions"]; ok {
+		err := forEach(value, path+"/attribute_definitions", func(item interface{}, itemPath string) error {
+			definition := &domain.DynamoDBAttributeDefinition{}
+			schema.AttributeDefinitions = append(schema.AttributeDefinitions, definition)
+			return fillStruct(definition, item, itemPath)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := m["key_schema"]; ok {
+		err := forEach(value, path+"/key_schema", func(item interface{}, itemPath string) error {
+			element := &domain.DynamoDBKeySchema{}
+			schema.KeySchema = append(schema.KeySchema, element)
+			return fillStruct(element, item, itemPath)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := m["ttl"]; ok {
+		schema.TTL = &domain.DynamoDBTimeToLive{}
+		if err := fillStruct(schema.TTL, value, path+"/ttl"); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := m["stream_specification"]; ok {
+		schema.StreamSpecification = &domain.DynamoDBStreamSpecification{}
+		if err := fillStruct(schema.StreamSpecification, value, path+"/stream_specification"); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := m["tags"]; ok {
+		tags, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, parseError(path+"/tags", "Expected an object of tag values, got %s", jsonType(value))
+		}
+		schema.Tags = make(map[string]string, len(tags))
+		for key, tag := range tags {
+			if schema.Tags[key], ok = tag.(string); !ok {
+				return nil, parseError(path+"/tags/"+key, "Expected a tag value string, got %s", jsonType(tag))
+			}
+		}
+	}
+	if value, ok := m["sse_specification"]; ok {
+		schema.SSESpecification = &domain.DynamoDBSSESpecification{}
+		if err := fillStruct(schema.SSESpecification, value, path+"/sse_specification"); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if schema.PointInTimeRecovery, err = parseOptionalBool(m, "point_in_time_recovery", path); err != nil {
+		return nil, err
+	}
+	if schema.DeletionProtection, err = parseOptionalBool(m, "deletion_protection", path); err != nil {
+		return nil, err
+	}
+	if value, ok := m["table_class"]; ok {
+		if schema.TableClass, ok = value.(string); !ok {
+			return nil, parseError(path+"/table_class", "Expected a table class string, got %s", jsonType(value))
+		}
+	}
+	return schema, nil
+}
+
+// parseOptionalBool - returns the boolean field of the object, nil if the field is not set.
+func parseOptionalBool(m map[string]interface{}, field, path string) (*bool, error) {
+	value, ok := m[field]
+	if !ok {
+		return nil, nil
+	}
+	enabled, ok := value.(bool)
+	if !ok {
+		return nil, parseError(path+"/"+field, "Expected a boolean, got %s", jsonType(value))
+	}
+	return &enabled, nil
+}
+
+// forEach - calls fn for every element of the JSON array with its JSON pointer.
+func forEach(value interface{}, path string, fn func(item interface{}, itemPath string) error) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return parseError(path, "Expected an array, got %s", jsonType(value))
+	}
+	for i, item := range list {
+		if err := fn(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseError(path, format string, args ...interface{}) error {
+	return &domain.ParseError{
+		Path: path,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+// syntaxError - adds the line and column of a JSON syntax error.
+func syntaxError(content []byte, err error) error {
+	var jsonErr *json.SyntaxError
+	if !errors.As(err, &jsonErr) {
+		return err
+	}
+	line, column := 1, 1
+	for i := int64(0); i < jsonErr.Offset-1 && i < int64(len(content)); i++ {
+		c := content[i]
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Errorf("Invalid JSON at line %d, column %d: %v", line, column, err)
+}
+
+// jsonType - returns the JSON type name of a decoded value.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
 func convertToSliceMap(val interface{}) ([]map[string]interface{}, bool) {
 	if val == nil {
 		return []map[string]interface{}{}, false
@@ -104,15 +322,22 @@ func convertToString(v interface{}) (string, bool) {
 	return stringValue, ok
 }
 
-func fillStruct(any interface{}, m interface{}) error {
+func fillStruct(any interface{}, m interface{}, path string) error {
 	if err := checkIsPointer(any); err != nil {
 		return err
 	}
+	if _, ok := m.(map[string]interface{}); !ok {
+		return parseError(path, "Expected an object, got %s", jsonType(m))
+	}
 	bytes, err := json.Marshal(m)
 	if err != nil {
 		return err
 	}
 	err = json.Unmarshal(bytes, any)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return parseError(path+"/"+typeErr.Field, "Expected %s, got %s", typeErr.Type, typeErr.Value)
+	}
 	return err
 }
 
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"dynamodb.data-migration/internal/domain"
@@ -383,3 +384,76 @@ func TestQueryParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMigration(t *testing.T) {
+	type result struct {
+		backupBefore []string
+		tables       []string
+		queriesPath  string
+	}
+
+	// Test.
+	tests := []struct {
+		name     string
+		content  string
+		prefix   string
+		expected result
+		// expectedError - part of the expected error, empty if no error is expected.
+		expectedError string
+	}{
+		{
+			name:     "Success: list of queries",
+			content:  `[{"table_name": "users"}]`,
+			expected: result{tables: []string{"users"}},
+		},
+		{
+			name:     "Success: object with backups",
+			content:  `{"backup_before": ["users"], "queries": [{"table_name": "users"}]}`,
+			expected: result{backupBefore: []string{"users"}, tables: []string{"users"}, queriesPath: "/queries"},
+		},
+		{
+			name:     "Success: prefixed backups",
+			content:  `{"backup_before": ["users"], "queries": [{"table_name": "users"}]}`,
+			prefix:   "staging_",
+			expected: result{backupBefore: []string{"staging_users"}, tables: []string{"staging_users"}, queriesPath: "/queries"},
+		},
+		{
+			name:          "Fail: unknown field",
+			content:       `{"backup": ["users"], "queries": []}`,
+			expectedError: "/backup",
+		},
+		{
+			name:          "Fail: backup table not a string",
+			content:       `{"backup_before": [1], "queries": []}`,
+			expectedError: "/backup_before/0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queryParser := NewQueryParser()
+			if len(test.prefix) > 0 {
+				queryParser = NewPrefixedQueryParser(queryParser, test.prefix)
+			}
+			migration, err := queryParser.ParseMigration([]byte(test.content))
+			if len(test.expectedError) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("actual error: %v must contain: %s", err, test.expectedError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			actual := result{backupBefore: migration.BackupBefore, queriesPath: migration.QueriesPath}
+			for _, query := range migration.Queries {
+				actual.tables = append(actual.tables, query.TableName)
+			}
+			if len(actual.backupBefore) == 0 {
+				actual.backupBefore = nil
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("actual migration: %+v does not match expected: %+v", actual, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter - token bucket of capacity units refilled at a constant rate, the bucket holds one second of units.
+// Units are taken when they are requested, the balance may become negative and the request waits until the debt is
+// refilled, so requests larger than the bucket, e.g. transactions of 100 items, are paced as well.
+// Methods of a nil limiter do not limit.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	// sleep - waits for the delay, returns the context error if the context is done first.
+	sleep func(ctx context.Context, delay time.Duration) error
+}
+
+// NewLimiter - constructs a full limiter of units per second, returns nil if the rate is not positive.
+func NewLimiter(rate float64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &Limiter{
+		rate:   rate,
+		tokens: rate,
+		last:   time.Now(),
+		now:    time.Now,
+		sleep:  sleep,
+	}
+}
+
+// Wait - takes the units and waits until they are refilled, the units are returned if the context is done first.
+func (l *Limiter) Wait(ctx context.Context, units float64) error {
+	if l == nil {
+		return nil
+	}
+	delay := l.reserve(units)
+	if delay == 0 {
+		return nil
+	}
+	if err := l.sleep(ctx, delay); err != nil {
+		l.Adjust(units, 0)
+		return err
+	}
+	return nil
+}
+
+// Adjust - corrects the taken units by the consumed capacity reported by DynamoDB.
+func (l *Limiter) Adjust(estimated, consumed float64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens = math.Min(l.tokens+estimated-consumed, l.rate)
+}
+
+// reserve - takes the units, returns the time until the debt of the balance is refilled.
+func (l *Limiter) reserve(units float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= units
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) refill() {
+	now := l.now()
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate)
+	l.last = now
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
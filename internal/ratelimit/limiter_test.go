@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock - clock advanced by sleeps of limiters.
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) attach(l *Limiter) {
+	l.now = func() time.Time { return c.now }
+	l.last = c.now
+	l.sleep = func(ctx context.Context, delay time.Duration) error {
+		c.sleeps = append(c.sleeps, delay)
+		c.now = c.now.Add(delay)
+		return ctx.Err()
+	}
+}
+
+func TestLimiter(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name     string
+		rate     float64
+		requests []float64
+		// consumed - consumed capacity reported for the requests, the estimate is used if missing.
+		consumed       []float64
+		expectedSleeps []time.Duration
+	}{
+		{
+			name:     "Success: within the bucket",
+			rate:     10,
+			requests: []float64{4, 6},
+		},
+		{
+			name:           "Success: waits for the refill",
+			rate:           10,
+			requests:       []float64{10, 5},
+			expectedSleeps: []time.Duration{500 * time.Millisecond},
+		},
+		{
+			name:           "Success: large request waits for the debt",
+			rate:           10,
+			requests:       []float64{20, 10},
+			expectedSleeps: []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:           "Success: request larger than the bucket is paced",
+			rate:           10,
+			requests:       []float64{200, 200},
+			expectedSleeps: []time.Duration{19 * time.Second, 20 * time.Second},
+		},
+		{
+			name:           "Success: adapts to the consumed capacity",
+			rate:           10,
+			requests:       []float64{2, 10},
+			consumed:       []float64{12},
+			expectedSleeps: []time.Duration{1200 * time.Millisecond},
+		},
+		{
+			name:     "Success: no limit",
+			rate:     0,
+			requests: []float64{100, 100},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)}
+			limiter := NewLimiter(test.rate)
+			if limiter != nil {
+				clock.attach(limiter)
+			}
+			for i, units := range test.requests {
+				if err := limiter.Wait(context.Background(), units); err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				if i < len(test.consumed) {
+					limiter.Adjust(units, test.consumed[i])
+				}
+			}
+			if !reflect.DeepEqual(clock.sleeps, test.expectedSleeps) {
+				t.Errorf("actual sleeps: %v do not match expected: %v", clock.sleeps, test.expectedSleeps)
+			}
+		})
+	}
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)}
+	limiter := NewLimiter(1)
+	clock.attach(limiter)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx, 1); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := limiter.Wait(ctx, 1); err != context.Canceled {
+		t.Errorf("actual error: %v does not match expected: %v", err, context.Canceled)
+	}
+
+	// Units of the canceled request are returned.
+	clock.now = clock.now.Add(time.Second)
+	if err := limiter.Wait(context.Background(), 1); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if expected := []time.Duration{time.Second}; !reflect.DeepEqual(clock.sleeps, expected) {
+		t.Errorf("actual sleeps: %v do not match expected: %v", clock.sleeps, expected)
+	}
+}
+
+func TestTableLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)}
+	limiter := NewTableLimiter(100, map[string]float64{"users": 10})
+	clock.attach(limiter.global)
+	clock.attach(limiter.tables["users"])
+
+	// The table limit slows writes to the table only.
+	for _, units := range []Units{{"users": 10, "roles": 10}, {"roles": 50}, {"users": 5}} {
+		if err := limiter.Wait(context.Background(), units); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	if expected := []time.Duration{500 * time.Millisecond}; !reflect.DeepEqual(clock.sleeps, expected) {
+		t.Errorf("actual sleeps: %v do not match expected: %v", clock.sleeps, expected)
+	}
+}
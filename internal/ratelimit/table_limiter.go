@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+)
+
+// Units - capacity units by table name.
+type Units map[string]float64
+
+// TableLimiter - limits capacity units per second consumed in all tables and in single tables.
+type TableLimiter struct {
+	global *Limiter
+	tables map[string]*Limiter
+}
+
+// NewTableLimiter - constructs a limiter of units per second, rates that are not positive do not limit.
+func NewTableLimiter(global float64, tables map[string]float64) *TableLimiter {
+	limiter := &TableLimiter{
+		global: NewLimiter(global),
+		tables: make(map[string]*Limiter, len(tables)),
+	}
+	for table, rate := range tables {
+		if tableLimiter := NewLimiter(rate); tableLimiter != nil {
+			limiter.tables[table] = tableLimiter
+		}
+	}
+	return limiter
+}
+
+// Wait - waits until the units of every table are available and takes them.
+func (t *TableLimiter) Wait(ctx context.Context, units Units) error {
+	var total float64
+	for table, tableUnits := range units {
+		total += tableUnits
+		if err := t.tables[table].Wait(ctx, tableUnits); err != nil {
+			return err
+		}
+	}
+	return t.global.Wait(ctx, total)
+}
+
+// Adjust - corrects the taken units by the consumed capacity reported by DynamoDB.
+// Tables without reported capacity are not corrected.
+func (t *TableLimiter) Adjust(estimated, consumed Units) {
+	var estimatedTotal, consumedTotal float64
+	for table, consumedUnits := range consumed {
+		estimatedUnits := estimated[table]
+		t.tables[table].Adjust(estimatedUnits, consumedUnits)
+		estimatedTotal += estimatedUnits
+		consumedTotal += consumedUnits
+	}
+	t.global.Adjust(estimatedTotal, consumedTotal)
+}
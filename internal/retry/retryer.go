@@ -0,0 +1,107 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// ErrorCodes - returns the error codes of a failed request, e.g. the AWS error code and transaction cancellation reasons.
+type ErrorCodes func(err error) []string
+
+// Retryer - retries operations failed with retryable error codes.
+type Retryer struct {
+	policy     domain.RetryPolicy
+	errorCodes ErrorCodes
+	logger     domain.Logger
+	metrics    domain.Metrics
+	// sleep - waits for the delay, returns the context error if the context is done first.
+	sleep func(ctx context.Context, delay time.Duration) error
+	// random - returns a number from 0 to 1 used for jitter.
+	random func() float64
+}
+
+// NewRetryer - constructs a retryer of the policy.
+func NewRetryer(policy domain.RetryPolicy, errorCodes ErrorCodes, logger domain.Logger, metrics domain.Metrics) *Retryer {
+	var (
+		mu  sync.Mutex
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	)
+	return &Retryer{
+		policy:     policy,
+		errorCodes: errorCodes,
+		logger:     logger,
+		metrics:    metrics,
+		sleep:      sleep,
+		random: func() float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			return rnd.Float64()
+		},
+	}
+}
+
+// Do - runs the operation, retries it with backoff while it fails with a retryable error code.
+// Returns the last error if attempts are exhausted or the context is done.
+func (r *Retryer) Do(ctx context.Context, operation string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		codes := r.errorCodes(err)
+		if isThrottled(codes) {
+			r.metrics.RequestThrottled(operation)
+		}
+		if attempt >= r.policy.MaxAttempts {
+			return err
+		}
+		code, ok := r.retryableCode(codes)
+		if !ok {
+			return err
+		}
+		delay := r.policy.Delay(attempt, r.random())
+		r.logger.Warn("Retrying request",
+			domain.F("operation", operation),
+			domain.F("attempt", attempt),
+			domain.F("code", code),
+			domain.F("delay", delay),
+		)
+		r.metrics.RequestRetried(operation, code)
+		if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (r *Retryer) retryableCode(codes []string) (string, bool) {
+	for _, code := range codes {
+		if r.policy.IsRetryable(code) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+func isThrottled(codes []string) bool {
+	for _, code := range codes {
+		if domain.IsThrottlingCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/logging"
+	"dynamodb.data-migration/internal/metrics"
+)
+
+// codeError - error with an AWS error code.
+type codeError struct {
+	code string
+}
+
+func (e *codeError) Error() string {
+	return e.code
+}
+
+// fakeMetrics - counts retries and throttles, other measurements are discarded.
+type fakeMetrics struct {
+	domain.Metrics
+	retries   []string
+	throttles int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{Metrics: metrics.NewNopMetrics()}
+}
+
+func (m *fakeMetrics) RequestRetried(operation, code string) {
+	m.retries = append(m.retries, code)
+}
+
+func (m *fakeMetrics) RequestThrottled(operation string) {
+	m.throttles++
+}
+
+func errorCodes(err error) []string {
+	var codeErr *codeError
+	if errors.As(err, &codeErr) {
+		return []string{codeErr.code}
+	}
+	return nil
+}
+
+func TestRetryer(t *testing.T) {
+	policy := domain.RetryPolicy{
+		MaxAttempts:    3,
+		BaseDelay:      100 * time.Millisecond,
+		MaxDelay:       150 * time.Millisecond,
+		RetryableCodes: []string{domain.RetryCodeTransactionConflict, domain.RetryCodeThrottlingException},
+	}
+	conflict := &codeError{code: domain.RetryCodeTransactionConflict}
+	throttling := &codeError{code: domain.RetryCodeThrottlingException}
+	validation := &codeError{code: "ValidationException"}
+
+	// Test.
+	tests := []struct {
+		name             string
+		errors           []error
+		expectedErr      error
+		expectedAttempts int
+		expectedDelays   []time.Duration
+		// expectedThrottles - number of throttled attempts recorded in metrics.
+		expectedThrottles int
+	}{
+		{
+			name:             "Success: no retries",
+			errors:           []error{nil},
+			expectedAttempts: 1,
+		},
+		{
+			name:             "Success: retried conflict",
+			errors:           []error{conflict, conflict, nil},
+			expectedAttempts: 3,
+			expectedDelays:   []time.Duration{100 * time.Millisecond, 150 * time.Millisecond},
+		},
+		{
+			name:              "Success: retried throttling",
+			errors:            []error{throttling, nil},
+			expectedAttempts:  2,
+			expectedDelays:    []time.Duration{100 * time.Millisecond},
+			expectedThrottles: 1,
+		},
+		{
+			name:              "Fail: throttled attempts exhausted",
+			errors:            []error{throttling, throttling, throttling},
+			expectedErr:       throttling,
+			expectedAttempts:  3,
+			expectedDelays:    []time.Duration{100 * time.Millisecond, 150 * time.Millisecond},
+			expectedThrottles: 3,
+		},
+		{
+			name:             "Fail: attempts exhausted",
+			errors:           []error{conflict, conflict, conflict},
+			expectedErr:      conflict,
+			expectedAttempts: 3,
+			expectedDelays:   []time.Duration{100 * time.Millisecond, 150 * time.Millisecond},
+		},
+		{
+			name:             "Fail: not retryable error",
+			errors:           []error{validation},
+			expectedErr:      validation,
+			expectedAttempts: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				attempts int
+				delays   []time.Duration
+				recorded = newFakeMetrics()
+				retryer  = NewRetryer(policy, errorCodes, logging.NewNopLogger(), recorded)
+			)
+			retryer.sleep = func(ctx context.Context, delay time.Duration) error {
+				delays = append(delays, delay)
+				return nil
+			}
+			err := retryer.Do(context.Background(), "test", func() error {
+				attempts++
+				return test.errors[attempts-1]
+			})
+			if err != test.expectedErr {
+				t.Errorf("actual error: %v does not match expected: %v", err, test.expectedErr)
+			}
+			if attempts != test.expectedAttempts {
+				t.Errorf("actual attempts: %d do not match expected: %d", attempts, test.expectedAttempts)
+			}
+			if !reflect.DeepEqual(delays, test.expectedDelays) {
+				t.Errorf("actual delays: %v do not match expected: %v", delays, test.expectedDelays)
+			}
+			if len(recorded.retries) != len(test.expectedDelays) || recorded.throttles != test.expectedThrottles {
+				t.Errorf("actual retries: %v, throttles: %d do not match expected: %d, %d",
+					recorded.retries, recorded.throttles, len(test.expectedDelays), test.expectedThrottles)
+			}
+		})
+	}
+}
+
+func TestRetryerCanceled(t *testing.T) {
+	var (
+		attempts    int
+		conflict    = &codeError{code: domain.RetryCodeTransactionConflict}
+		retryer     = NewRetryer(domain.DefaultRetryPolicy(), errorCodes, logging.NewNopLogger(), metrics.NewNopMetrics())
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	cancel()
+	err := retryer.Do(ctx, "test", func() error {
+		attempts++
+		return conflict
+	})
+	if err != conflict || attempts != 1 {
+		t.Errorf("retries must stop when the context is done, error: %v, attempts: %d", err, attempts)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := domain.RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second, Jitter: 0.5}
+
+	// Test.
+	tests := []struct {
+		name     string
+		attempt  int
+		random   float64
+		expected time.Duration
+	}{
+		{name: "Success: base delay", attempt: 1, random: 0, expected: time.Second},
+		{name: "Success: exponential backoff", attempt: 2, random: 0, expected: 2 * time.Second},
+		{name: "Success: max delay", attempt: 5, random: 0, expected: 3 * time.Second},
+		{name: "Success: full jitter", attempt: 2, random: 1, expected: time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := policy.Delay(test.attempt, test.random); actual != test.expected {
+				t.Errorf("actual delay: %v does not match expected: %v", actual, test.expected)
+			}
+		})
+	}
+}
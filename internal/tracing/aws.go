@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler names of the session instrumentation.
+const (
+	startHandlerName = "tracing.StartSpan"
+	endHandlerName   = "tracing.EndSpan"
+)
+
+// spanKey - context key of the span of an AWS request, the context may also carry parent spans.
+type spanKey struct{}
+
+// InstrumentSession - adds handlers tracing every AWS request of clients created from the session.
+// Spans are children of the span in the request context, SDK retries are counted in the span.
+func InstrumentSession(sess *session.Session, tracer trace.Tracer) {
+	sess.Handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: startHandlerName,
+		Fn: func(r *request.Request) {
+			attributes := []attribute.KeyValue{
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCServiceKey.String(r.ClientInfo.ServiceID),
+				semconv.RPCMethodKey.String(r.Operation.Name),
+			}
+			if table := tableName(r.Params); len(table) > 0 {
+				attributes = append(attributes, AttrTables.StringSlice([]string{table}))
+			}
+			ctx, span := tracer.Start(r.Context(), r.ClientInfo.ServiceID+"."+r.Operation.Name,
+				trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+			r.SetContext(context.WithValue(ctx, spanKey{}, span))
+		},
+	})
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: endHandlerName,
+		Fn: func(r *request.Request) {
+			span, ok := r.Context().Value(spanKey{}).(trace.Span)
+			if !ok {
+				return
+			}
+			span.SetAttributes(attribute.Int("aws.retry_count", r.RetryCount))
+			if len(r.RequestID) > 0 {
+				span.SetAttributes(attribute.String("aws.request_id", r.RequestID))
+			}
+			if r.HTTPResponse != nil {
+				span.SetAttributes(semconv.HTTPStatusCodeKey.Int(r.HTTPResponse.StatusCode))
+			}
+			End(span, r.Error)
+		},
+	})
+}
+
+// tableName - returns the TableName field of the request input, empty if there is none.
+func tableName(params interface{}) string {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	field := v.Elem().FieldByName("TableName")
+	if !field.IsValid() || field.Type() != reflect.TypeOf((*string)(nil)) {
+		return ""
+	}
+	return aws.StringValue(field.Interface().(*string))
+}
@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"dynamodb.data-migration/internal/domain"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName - name of the tracer of the migration tool.
+const InstrumentationName = "dynamodb.data-migration"
+
+// Span attributes.
+const (
+	AttrVersion       = attribute.Key("migration.version")
+	AttrName          = attribute.Key("migration.name")
+	AttrStatus        = attribute.Key("migration.status")
+	AttrTablesCreated = attribute.Key("migration.tables_created")
+	AttrItemsWritten  = attribute.Key("migration.items_written")
+	AttrTablesDeleted = attribute.Key("migration.tables_deleted")
+	AttrItemsDeleted  = attribute.Key("migration.items_deleted")
+	AttrApplied       = attribute.Key("migrations.applied")
+	AttrReverted      = attribute.Key("migrations.reverted")
+	AttrTables        = attribute.Key("aws.dynamodb.table_names")
+	AttrHookStage     = attribute.Key("migration.hook.stage")
+	AttrHooks         = attribute.Key("migration.hooks")
+)
+
+// NewNopTracer - returns a tracer discarding all spans.
+func NewNopTracer() trace.Tracer {
+	return trace.NewNoopTracerProvider().Tracer(InstrumentationName)
+}
+
+// NewTracerProvider - constructs the provider exporting spans with OTLP over HTTP, spans are discarded if the endpoint
+// is not set. Headers are also read from OTEL_EXPORTER_OTLP_HEADERS. The returned shutdown flushes buffered spans.
+func NewTracerProvider(ctx context.Context, export domain.TracingExport, appVersion string) (trace.TracerProvider, func(context.Context) error, error) {
+	if len(export.Endpoint) == 0 {
+		return trace.NewNoopTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+	endpoint, err := url.Parse(export.Endpoint)
+	if err != nil {
+		return nil, nil, domain.NewError(domain.ErrorKindConfig, err)
+	}
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(path.Join("/", endpoint.Path, "v1/traces")),
+	}
+	if endpoint.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if len(export.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(export.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, nil, domain.Errorf(domain.ErrorKindConfig, "Cannot create OTLP exporter: %v", err)
+	}
+	serviceName := export.ServiceName
+	if len(serviceName) == 0 {
+		serviceName = domain.DefaultTracingServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(appVersion),
+		)),
+	)
+	return provider, provider.Shutdown, nil
+}
+
+// WithParentFromEnv - returns the context with the remote parent span of the TRACEPARENT environment variable,
+// so the spans of a run are linked to the deploy that triggered it.
+func WithParentFromEnv(ctx context.Context) context.Context {
+	carrier := propagation.HeaderCarrier{}
+	for _, key := range []string{"traceparent", "tracestate"} {
+		if value, ok := os.LookupEnv(strings.ToUpper(key)); ok {
+			carrier.Set(key, value)
+		}
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
+
+// End - records the error in the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ResultAttributes - returns attributes of a migration result.
+func ResultAttributes(result *domain.MigrationResult) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttrStatus.String(string(result.Status)),
+		AttrTablesCreated.Int(result.TablesCreated),
+		AttrItemsWritten.Int(result.ItemsWritten),
+		AttrTablesDeleted.Int(result.TablesDeleted),
+		AttrItemsDeleted.Int(result.ItemsDeleted),
+	}
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awsDynamodb "github.com/aws/aws-sdk-go/service/dynamodb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrumentSession(t *testing.T) {
+
+	// Test.
+	tests := []struct {
+		name           string
+		status         int
+		body           string
+		expectedStatus codes.Code
+	}{
+		{
+			name:           "Success: request traced",
+			status:         http.StatusOK,
+			body:           `{"Table":{"TableName":"users"}}`,
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "Fail: error recorded",
+			status:         http.StatusBadRequest,
+			body:           `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceNotFoundException","message":"Table not found"}`,
+			expectedStatus: codes.Error,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+			sess := session.Must(session.NewSession(&aws.Config{
+				Region:      aws.String("eu-west-1"),
+				Endpoint:    aws.String(server.URL),
+				Credentials: credentials.NewStaticCredentials("key", "secret", ""),
+				MaxRetries:  aws.Int(0),
+			}))
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			InstrumentSession(sess, provider.Tracer(InstrumentationName))
+
+			// The span of the AWS call is a child of the span in the context.
+			ctx, parent := provider.Tracer(InstrumentationName).Start(context.Background(), "parent")
+			_, _ = awsDynamodb.New(sess).DescribeTableWithContext(ctx, &awsDynamodb.DescribeTableInput{
+				TableName: aws.String("users"),
+			})
+			parent.End()
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("actual spans: %d do not match expected: 2", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != "DynamoDB.DescribeTable" || span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("unexpected span: %s, parent: %s", span.Name(), span.Parent().SpanID())
+			}
+			if span.Status().Code != test.expectedStatus {
+				t.Errorf("actual status: %v does not match expected: %v", span.Status().Code, test.expectedStatus)
+			}
+			attributes := attribute.NewSet(span.Attributes()...)
+			if tables, _ := attributes.Value(AttrTables); len(tables.AsStringSlice()) != 1 || tables.AsStringSlice()[0] != "users" {
+				t.Errorf("actual tables: %v do not match expected: [users]", tables.AsStringSlice())
+			}
+			if status, _ := attributes.Value("http.status_code"); status.AsInt64() != int64(test.status) {
+				t.Errorf("actual HTTP status: %d does not match expected: %d", status.AsInt64(), test.status)
+			}
+		})
+	}
+}
+
+func TestWithParentFromEnv(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if err := os.Setenv("TRACEPARENT", traceparent); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("TRACEPARENT")
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, span := provider.Tracer(InstrumentationName).Start(WithParentFromEnv(context.Background()), "up")
+	span.End()
+	if traceID := span.SpanContext().TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("the span must continue the trace of TRACEPARENT, actual trace: %s", traceID)
+	}
+}
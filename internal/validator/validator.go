@@ -0,0 +1,302 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"dynamodb.data-migration/internal/domain"
+)
+
+// Tag limits of DynamoDB tables.
+const (
+	maxTags           = 50
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+type validator struct {
+	queryParser domain.QueryParser
+}
+
+// NewMigrationValidator - constructs a new migration validator.
+func NewMigrationValidator(queryParser domain.QueryParser) domain.MigrationValidator {
+	return &validator{
+		queryParser: queryParser,
+	}
+}
+
+// tableKey - key attributes of a table and their types.
+type tableKey struct {
+	names []string
+	types map[string]string
+}
+
+// issues - collects issues of a migration file.
+type issues struct {
+	migration string
+	list      []*domain.ValidationIssue
+}
+
+func (i *issues) add(path, format string, args ...interface{}) {
+	i.list = append(i.list, &domain.ValidationIssue{
+		Migration: i.migration,
+		Path:      path,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate - checks migrations in the version order, tables defined by earlier migrations are known to later ones.
+func (v *validator) Validate(migrations []*domain.Migration) []*domain.ValidationIssue {
+	sorted := make([]*domain.Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Version.Less(sorted[j].Version)
+	})
+
+	var (
+		result   []*domain.ValidationIssue
+		versions = make(map[domain.Version]string)
+		tables   = make(map[string]*tableKey)
+	)
+	for _, migration := range sorted {
+		found := &issues{migration: migration.Name}
+		if other, ok := versions[migration.Version]; ok {
+			found.add("", "Version %s is also used by %s", migration.Version, other)
+		} else {
+			versions[migration.Version] = migration.Name
+		}
+		v.validateMigration(migration, tables, found)
+		result = append(result, found.list...)
+	}
+	return result
+}
+
+func (v *validator) validateMigration(migration *domain.Migration, tables map[string]*tableKey, found *issues) {
+	parsed, err := v.queryParser.ParseMigration(migration.Content)
+	if err != nil {
+		var parseErr *domain.ParseError
+		if errors.As(err, &parseErr) {
+			found.add(parseErr.Path, "%v", parseErr.Err)
+		} else {
+			found.add("", "%v", err)
+		}
+		return
+	}
+	if err := parsed.Validate(); err != nil {
+		found.add("/"+domain.JSONFieldBackupBefore, "%v", err)
+	}
+	var (
+		transactionItems int
+		itemKeys         = make(map[string]string)
+	)
+	for i, q := range parsed.Queries {
+		path := fmt.Sprintf("%s/%d", parsed.QueriesPath, i)
+		if err := q.Validate(); err != nil {
+			found.add(path, "%v", err)
+			continue
+		}
+		for j, schema := range q.Schema {
+			schemaPath := fmt.Sprintf("%s/schema/%d", path, j)
+			if schema.TTL != nil && len(schema.TTL.AttributeName) == 0 {
+				found.add(schemaPath+"/ttl/attribute_name", "Time to Live attribute name required")
+			}
+			if schema.StreamSpecification != nil {
+				validateStreamSpecification(schema.StreamSpecification, schemaPath+"/stream_specification", found)
+			}
+			if schema.SSESpecification != nil {
+				validateSSESpecification(schema.SSESpecification, schemaPath+"/sse_specification", found)
+			}
+			validateTags(schema.Tags, schemaPath+"/tags", found)
+			switch schema.TableClass {
+			case "", domain.TableClassStandard, domain.TableClassStandardInfrequentAccess:
+			default:
+				found.add(schemaPath+"/table_class", "Table class %q, expected STANDARD or STANDARD_INFREQUENT_ACCESS", schema.TableClass)
+			}
+			if !schema.CreatesTable() {
+				if !schema.HasSettings() {
+					found.add(schemaPath, "Schema must define key_schema to create the table or settings to update it")
+				}
+				continue
+			}
+			if key := validateSchema(schema, schemaPath, found); key != nil {
+				tables[q.TableName] = key
+			}
+		}
+		key := tables[q.TableName]
+		for j, item := range q.Data {
+			itemPath := fmt.Sprintf("%s/data/%d", path, j)
+			transactionItems++
+			if len(item) == 0 {
+				found.add(itemPath, "Item cannot be empty")
+				continue
+			}
+			if key == nil {
+				// The table is created outside of the migrations, its key is unknown.
+				continue
+			}
+			id, ok := validateItemKey(item, key, itemPath, found)
+			if !ok {
+				continue
+			}
+			id = q.TableName + "/" + id
+			if other, ok := itemKeys[id]; ok {
+				found.add(itemPath, "Item has the same key as %s, a transaction cannot write one item twice", other)
+			} else {
+				itemKeys[id] = itemPath
+			}
+		}
+	}
+	if transactionItems > domain.MaxTransactionItems {
+		found.add("", "Migration writes %d items, a transaction is limited to %d items, split the migration",
+			transactionItems, domain.MaxTransactionItems)
+	}
+}
+
+// validateSchema - checks the key schema, returns the table key or nil if the schema is invalid.
+func validateSchema(schema *domain.DynamoDBSchema, path string, found *issues) *tableKey {
+	valid := true
+	definitions := make(map[string]string)
+	for i, definition := range schema.AttributeDefinitions {
+		switch definition.AttributeType {
+		case domain.AttributeTypeString, domain.AttributeTypeNumber, domain.AttributeTypeBinary:
+		default:
+			found.add(fmt.Sprintf("%s/attribute_definitions/%d/type", path, i),
+				"Attribute %s has type %q, expected S, N or B", definition.AttributeName, definition.AttributeType)
+			valid = false
+		}
+		definitions[definition.AttributeName] = definition.AttributeType
+	}
+
+	key := &tableKey{types: make(map[string]string)}
+	var hashKeys, rangeKeys int
+	for i, element := range schema.KeySchema {
+		elementPath := fmt.Sprintf("%s/key_schema/%d", path, i)
+		switch element.KeyType {
+		case domain.KeyTypeHash:
+			hashKeys++
+		case domain.KeyTypeRange:
+			rangeKeys++
+		default:
+			found.add(elementPath+"/type", "Key %s has type %q, expected HASH or RANGE", element.AttributeName, element.KeyType)
+			valid = false
+		}
+		attributeType, ok := definitions[element.AttributeName]
+		if !ok {
+			found.add(elementPath+"/name", "Key attribute %s is missing in attribute_definitions", element.AttributeName)
+			valid = false
+		}
+		key.names = append(key.names, element.AttributeName)
+		key.types[element.AttributeName] = attributeType
+	}
+	if hashKeys != 1 {
+		found.add(path+"/key_schema", "Key schema must have exactly one HASH key, got %d", hashKeys)
+		valid = false
+	}
+	if rangeKeys > 1 {
+		found.add(path+"/key_schema", "Key schema can have at most one RANGE key, got %d", rangeKeys)
+		valid = false
+	}
+	for i, definition := range schema.AttributeDefinitions {
+		if _, ok := key.types[definition.AttributeName]; !ok {
+			found.add(fmt.Sprintf("%s/attribute_definitions/%d/name", path, i),
+				"Attribute %s is not used by the key schema", definition.AttributeName)
+			valid = false
+		}
+	}
+	if !valid {
+		return nil
+	}
+	return key
+}
+
+// validateStreamSpecification - checks the view type of an enabled stream.
+func validateStreamSpecification(spec *domain.DynamoDBStreamSpecification, path string, found *issues) {
+	switch spec.ViewType {
+	case domain.StreamViewTypeKeysOnly, domain.StreamViewTypeNewImage, domain.StreamViewTypeOldImage, domain.StreamViewTypeNewAndOldImages:
+	case "":
+		if spec.Enabled {
+			found.add(path+"/view_type", "Stream view type required when the stream is enabled")
+		}
+	default:
+		found.add(path+"/view_type", "Stream view type %q, expected KEYS_ONLY, NEW_IMAGE, OLD_IMAGE or NEW_AND_OLD_IMAGES", spec.ViewType)
+	}
+}
+
+// validateSSESpecification - checks the encryption type, only KMS encryption has a key.
+func validateSSESpecification(spec *domain.DynamoDBSSESpecification, path string, found *issues) {
+	switch spec.Type {
+	case domain.SSETypeKMS:
+	case domain.SSETypeAWSOwned:
+		if len(spec.KMSKeyID) > 0 {
+			found.add(path+"/kms_key_id", "KMS key cannot be set for AWS_OWNED encryption")
+		}
+	default:
+		found.add(path+"/type", "Encryption type %q, expected AWS_OWNED or KMS", spec.Type)
+	}
+}
+
+// validateTags - checks the tag limits of DynamoDB, tags prefixed with aws: are reserved.
+func validateTags(tags map[string]string, path string, found *issues) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > maxTags {
+		found.add(path, "Table can have at most %d tags, got %d", maxTags, len(keys))
+	}
+	for _, key := range keys {
+		switch {
+		case len(key) == 0 || len(key) > maxTagKeyLength:
+			found.add(path, "Tag key %q must have 1 to %d characters", key, maxTagKeyLength)
+		case strings.HasPrefix(key, "aws:"):
+			found.add(path+"/"+key, "Tag key %s uses the reserved prefix aws:", key)
+		case len(tags[key]) > maxTagValueLength:
+			found.add(path+"/"+key, "Tag value of %s can have at most %d characters", key, maxTagValueLength)
+		}
+	}
+}
+
+// validateItemKey - checks key attributes of the item, returns the item key as a string.
+func validateItemKey(item map[string]interface{}, key *tableKey, path string, found *issues) (string, bool) {
+	valid := true
+	parts := make([]string, 0, len(key.names))
+	for _, name := range key.names {
+		value, ok := item[name]
+		if !ok {
+			found.add(path, "Item is missing the key attribute %s", name)
+			valid = false
+			continue
+		}
+		if actual := attributeTypeOf(value); actual != key.types[name] {
+			found.add(path+"/"+name, "Key attribute %s must have type %s, got %s", name, key.types[name], actual)
+			valid = false
+			continue
+		}
+		parts = append(parts, fmt.Sprint(value))
+	}
+	return strings.Join(parts, "/"), valid
+}
+
+// attributeTypeOf - returns the DynamoDB type of a JSON value, binary values cannot be written from JSON.
+func attributeTypeOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return domain.AttributeTypeString
+	case float64, json.Number:
+		return domain.AttributeTypeNumber
+	case bool:
+		return "BOOL"
+	case nil:
+		return "NULL"
+	case []interface{}:
+		return "L"
+	case map[string]interface{}:
+		return "M"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
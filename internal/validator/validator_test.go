@@ -0,0 +1,315 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"dynamodb.data-migration/internal/domain"
+	"dynamodb.data-migration/internal/parser"
+)
+
+func TestValidate(t *testing.T) {
+	usersSchema := `{
+		"attribute_definitions": [
+			{"name": "id", "type": "S"},
+			{"name": "created", "type": "N"}
+		],
+		"key_schema": [
+			{"name": "id", "type": "HASH"},
+			{"name": "created", "type": "RANGE"}
+		]
+	}`
+	manyItems := make([]string, domain.MaxTransactionItems+1)
+	for i := range manyItems {
+		manyItems[i] = fmt.Sprintf(`{"id": "%d"}`, i)
+	}
+
+	// Test.
+	type migrationFile struct {
+		version domain.Version
+		content string
+	}
+	tests := []struct {
+		name       string
+		migrations []migrationFile
+		expected   []string
+	}{
+		{
+			name: "Success: schema and data",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "users", "schema": [` + usersSchema + `], "data": [{"id": "1", "created": 1}, {"id": "1", "created": 2}]}]`,
+				},
+			},
+		},
+		{
+			name: "Success: data for a table created by an earlier migration",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1, Minor: 1},
+					content: `[{"table_name": "users", "data": [{"id": "2", "created": 1}]}]`,
+				},
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "users", "schema": [` + usersSchema + `]}]`,
+				},
+			},
+		},
+		{
+			name: "Success: data for a table created outside of the migrations",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "external", "data": [{"name": true}]}]`,
+				},
+			},
+		},
+		{
+			name: "Failure: invalid key schema",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "users", "schema": [{
+						"attribute_definitions": [{"name": "id", "type": "X"}, {"name": "unused", "type": "S"}],
+						"key_schema": [{"name": "id", "type": "RANGE"}, {"name": "missing", "type": "RANGE"}]
+					}]}]`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0/schema/0/attribute_definitions/0/type: Attribute id has type \"X\", expected S, N or B",
+				"1.0.0.json: /0/schema/0/key_schema/1/name: Key attribute missing is missing in attribute_definitions",
+				"1.0.0.json: /0/schema/0/key_schema: Key schema must have exactly one HASH key, got 0",
+				"1.0.0.json: /0/schema/0/key_schema: Key schema can have at most one RANGE key, got 2",
+				"1.0.0.json: /0/schema/0/attribute_definitions/1/name: Attribute unused is not used by the key schema",
+			},
+		},
+		{
+			name: "Failure: invalid item keys",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "users", "schema": [` + usersSchema + `], "data": [
+						{"id": "1"},
+						{"id": 1, "created": 1},
+						{"id": "1", "created": 1},
+						{"id": "1", "created": 1},
+						{}
+					]}]`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0/data/0: Item is missing the key attribute created",
+				"1.0.0.json: /0/data/1/id: Key attribute id must have type S, got N",
+				"1.0.0.json: /0/data/3: Item has the same key as /0/data/2, a transaction cannot write one item twice",
+				"1.0.0.json: /0/data/4: Item cannot be empty",
+			},
+		},
+		{
+			name: "Failure: duplicate versions, parse errors and invalid queries",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": ""}]`,
+				},
+				{
+					version: domain.Version{Major: 1},
+					content: `{`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0: Table name required",
+				"1.0.0.json: Version 1.0.0 is also used by 1.0.0.json",
+				"1.0.0.json: Invalid JSON at line 1, column 1: unexpected end of JSON input",
+			},
+		},
+		{
+			name: "Failure: parse errors with JSON pointers",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "users", "schema": [{"attribute_definitions": [{"name": 1}]}]}]`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 1},
+					content: `[{"table_name": "users"}, {"table_name": 1}]`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 2},
+					content: `[{"table_name": "users", "data": "item"}]`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0/schema/0/attribute_definitions/0/name: Expected string, got number",
+				"1.1.0.json: /1/table_name: Expected a table name string, got number",
+				"1.2.0.json: /0/data: Expected an object or an array of objects for users",
+			},
+		},
+		{
+			name: "Success: migration object with backups",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `{"backup_before": ["users"], "queries": [{"table_name": "users", "data": [{"id": "1"}]}]}`,
+				},
+			},
+		},
+		{
+			name: "Failure: migration object",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `{"backup_before": ["users", "users"], "queries": [{"table_name": "users"}]}`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 1},
+					content: `{"backup": ["users"], "queries": []}`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 2},
+					content: `{"backup_before": [1], "queries": []}`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /backup_before: Table users is backed up twice",
+				"1.0.0.json: /queries/0: Either schema or data must be specified",
+				"1.1.0.json: /backup: Unknown field, expected backup_before or queries",
+				"1.2.0.json: /backup_before/0: Expected a table name string, got number",
+			},
+		},
+		{
+			name: "Success: time to live of a created and an existing table",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[
+						{"table_name": "sessions", "schema": [{"attribute_definitions": [{"name": "id", "type": "S"}], "key_schema": [{"name": "id", "type": "HASH"}], "ttl": {"attribute_name": "expires_at", "enabled": true}}]},
+						{"table_name": "audit", "schema": [{"ttl": {"attribute_name": "expires_at", "enabled": true}}]}
+					]`,
+				},
+			},
+		},
+		{
+			name: "Failure: invalid time to live",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "audit", "schema": [{"ttl": {"attribute_name": "", "enabled": true}}, {}]}]`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 1},
+					content: `[{"table_name": "audit", "schema": [{"ttl": {"attribute_name": "expires_at", "enabled": "yes"}}]}]`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0/schema/0/ttl/attribute_name: Time to Live attribute name required",
+				"1.0.0.json: /0/schema/1: Schema must define key_schema to create the table or settings to update it",
+				"1.1.0.json: /0/schema/0/ttl/enabled: Expected bool, got string",
+			},
+		},
+		{
+			name: "Success: streams of a created and an existing table",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[
+						{"table_name": "orders", "schema": [{"attribute_definitions": [{"name": "id", "type": "S"}], "key_schema": [{"name": "id", "type": "HASH"}], "stream_specification": {"enabled": true, "view_type": "NEW_AND_OLD_IMAGES"}}]},
+						{"table_name": "audit", "schema": [{"stream_specification": {"enabled": false}}]}
+					]`,
+				},
+			},
+		},
+		{
+			name: "Failure: invalid stream specification",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "audit", "schema": [{"stream_specification": {"enabled": true}}, {"stream_specification": {"enabled": true, "view_type": "ALL"}}]}]`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0/schema/0/stream_specification/view_type: Stream view type required when the stream is enabled",
+				`1.0.0.json: /0/schema/1/stream_specification/view_type: Stream view type "ALL", expected KEYS_ONLY, NEW_IMAGE, OLD_IMAGE or NEW_AND_OLD_IMAGES`,
+			},
+		},
+		{
+			name: "Success: tags, encryption and point-in-time recovery",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[
+						{"table_name": "orders", "schema": [{"attribute_definitions": [{"name": "id", "type": "S"}], "key_schema": [{"name": "id", "type": "HASH"}],
+							"tags": {"team": "payments"}, "sse_specification": {"type": "KMS", "kms_key_id": "1234abcd-12ab-34cd-56ef-1234567890ab"}, "point_in_time_recovery": true,
+							"deletion_protection": true, "table_class": "STANDARD_INFREQUENT_ACCESS"}]},
+						{"table_name": "audit", "schema": [{"tags": {"compliance": "pci"}, "sse_specification": {"type": "AWS_OWNED"}}]}
+					]`,
+				},
+			},
+		},
+		{
+			name: "Failure: invalid tags and encryption",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "audit", "schema": [
+						{"tags": {"aws:team": "payments", "owner": "` + strings.Repeat("x", 257) + `"}, "sse_specification": {"type": "AWS_OWNED", "kms_key_id": "key"}},
+						{"sse_specification": {"type": "CUSTOMER"}, "table_class": "ARCHIVE"}
+					]}]`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 1},
+					content: `[{"table_name": "audit", "schema": [{"tags": {"team": 1}, "point_in_time_recovery": "yes"}]}]`,
+				},
+				{
+					version: domain.Version{Major: 1, Minor: 2},
+					content: `[{"table_name": "audit", "schema": [{"deletion_protection": "yes"}]}]`,
+				},
+			},
+			expected: []string{
+				"1.0.0.json: /0/schema/0/sse_specification/kms_key_id: KMS key cannot be set for AWS_OWNED encryption",
+				"1.0.0.json: /0/schema/0/tags/aws:team: Tag key aws:team uses the reserved prefix aws:",
+				"1.0.0.json: /0/schema/0/tags/owner: Tag value of owner can have at most 256 characters",
+				`1.0.0.json: /0/schema/1/sse_specification/type: Encryption type "CUSTOMER", expected AWS_OWNED or KMS`,
+				`1.0.0.json: /0/schema/1/table_class: Table class "ARCHIVE", expected STANDARD or STANDARD_INFREQUENT_ACCESS`,
+				"1.1.0.json: /0/schema/0/tags/team: Expected a tag value string, got number",
+				"1.2.0.json: /0/schema/0/deletion_protection: Expected a boolean, got string",
+			},
+		},
+		{
+			name: "Failure: transaction limit",
+			migrations: []migrationFile{
+				{
+					version: domain.Version{Major: 1},
+					content: `[{"table_name": "external", "data": [` + strings.Join(manyItems, ",") + `]}]`,
+				},
+			},
+			expected: []string{
+				fmt.Sprintf("1.0.0.json: Migration writes %d items, a transaction is limited to %d items, split the migration",
+					domain.MaxTransactionItems+1, domain.MaxTransactionItems),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var migrations []*domain.Migration
+			for _, file := range tt.migrations {
+				migrations = append(migrations, &domain.Migration{
+					MigrationRecord: domain.MigrationRecord{
+						Version: file.version,
+						Name:    file.version.String() + ".json",
+					},
+					Content: []byte(file.content),
+				})
+			}
+			var actual []string
+			for _, issue := range NewMigrationValidator(parser.NewQueryParser()).Validate(migrations) {
+				actual = append(actual, issue.String())
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("Validate() = %q, expected %q", actual, tt.expected)
+			}
+		})
+	}
+}